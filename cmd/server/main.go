@@ -13,17 +13,23 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/dukerupert/wantok/internal/attachments"
 	"github.com/dukerupert/wantok/internal/auth"
 	"github.com/dukerupert/wantok/internal/cleanup"
 	"github.com/dukerupert/wantok/internal/database"
 	"github.com/dukerupert/wantok/internal/email"
+	"github.com/dukerupert/wantok/internal/expiry"
 	"github.com/dukerupert/wantok/internal/handlers"
+	"github.com/dukerupert/wantok/internal/linkpreview"
+	"github.com/dukerupert/wantok/internal/presence"
 	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/scheduler"
 	"github.com/dukerupert/wantok/internal/store"
 	"golang.org/x/term"
 	_ "modernc.org/sqlite"
@@ -55,6 +61,21 @@ type AppConfig struct {
 
 	// Base URL for email links
 	BaseURL string
+
+	// Realtime broker: "local" (single process) or "sqlite" (shared between processes)
+	HubBroker string
+
+	// How long senders can edit a message; zero disables editing
+	EditWindow time.Duration
+
+	// Largest file that can be attached to a message, in bytes
+	MaxAttachmentSize int64
+
+	// Days messages are kept unless their conversation overrides it; zero keeps them forever
+	RetentionDays int64
+
+	// Fetch previews of links in messages
+	LinkPreviews bool
 }
 
 func getenv(target string, list []string) string {
@@ -70,14 +91,19 @@ func getenv(target string, list []string) string {
 func loadConfig(args []string) AppConfig {
 	// defaults
 	cfg := AppConfig{
-		DatabasePath:  "wantok.db",
-		Host:          "localhost",
-		ListenAddr:    "8080",
-		SessionSecret: "PaxRomana",
-		SessionMaxAge: 3600,
-		SecureCookies: true, // Default to secure (production)
-		SMTPPort:      587,
-		SMTPTLS:       true,
+		DatabasePath:      "wantok.db",
+		Host:              "localhost",
+		ListenAddr:        "8080",
+		SessionSecret:     "PaxRomana",
+		SessionMaxAge:     3600,
+		SecureCookies:     true, // Default to secure (production)
+		SMTPPort:          587,
+		SMTPTLS:           true,
+		HubBroker:         "local",
+		EditWindow:        15 * time.Minute,
+		MaxAttachmentSize: 10 << 20,
+		RetentionDays:     30,
+		LinkPreviews:      true,
 	}
 
 	path := getenv("DATABASE_PATH", args)
@@ -149,6 +175,45 @@ func loadConfig(args []string) AppConfig {
 
 	cfg.BaseURL = getenv("BASE_URL", args)
 
+	broker := getenv("HUB_BROKER", args)
+	if broker != "" {
+		cfg.HubBroker = broker
+	}
+
+	editWindow := getenv("MESSAGE_EDIT_WINDOW", args)
+	if editWindow != "" {
+		minutes, err := strconv.Atoi(editWindow)
+		if err != nil || minutes < 0 {
+			slog.Info("Invalid message edit window", "type", "lifecycle", "value", editWindow)
+		} else {
+			cfg.EditWindow = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	maxAttachment := getenv("MAX_ATTACHMENT_SIZE", args)
+	if maxAttachment != "" {
+		megabytes, err := strconv.Atoi(maxAttachment)
+		if err != nil || megabytes <= 0 {
+			slog.Info("Invalid max attachment size", "type", "lifecycle", "value", maxAttachment)
+		} else {
+			cfg.MaxAttachmentSize = int64(megabytes) << 20
+		}
+	}
+
+	retention := getenv("MESSAGE_RETENTION", args)
+	if retention != "" {
+		days, err := strconv.ParseInt(retention, 10, 64)
+		if err != nil || days < 0 {
+			slog.Info("Invalid message retention", "type", "lifecycle", "value", retention)
+		} else {
+			cfg.RetentionDays = days
+		}
+	}
+
+	if getenv("LINK_PREVIEWS", args) == "false" {
+		cfg.LinkPreviews = false
+	}
+
 	return cfg
 }
 
@@ -231,7 +296,7 @@ func promptPassword(prompt string) (string, error) {
 }
 
 func run(ctx context.Context, w io.Writer, args []string) error {
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 	cfg := loadConfig(args)
 	db, err := database.New(cfg.DatabasePath)
@@ -245,6 +310,25 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	handlers.SecureCookies = cfg.SecureCookies
 	slog.Info("cookie security configured", "type", "lifecycle", "secure", cfg.SecureCookies)
 
+	handlers.EditWindow = cfg.EditWindow
+	slog.Info("message editing configured", "type", "lifecycle", "window", cfg.EditWindow)
+
+	handlers.RetentionDays = cfg.RetentionDays
+	slog.Info("message retention configured", "type", "lifecycle", "days", cfg.RetentionDays)
+
+	if cfg.LinkPreviews {
+		handlers.LinkPreviews = linkpreview.New()
+	}
+	slog.Info("link previews configured", "type", "lifecycle", "enabled", cfg.LinkPreviews)
+
+	// Attachments are stored next to the database
+	files, err := attachments.New(filepath.Join(filepath.Dir(cfg.DatabasePath), "attachments"))
+	if err != nil {
+		return fmt.Errorf("attachment storage: %w", err)
+	}
+	handlers.MaxAttachmentSize = cfg.MaxAttachmentSize
+	slog.Info("attachments configured", "type", "lifecycle", "max_size", cfg.MaxAttachmentSize)
+
 	// Create email mailer
 	mailer := email.New(email.Config{
 		Provider:            email.Provider(cfg.EmailProvider),
@@ -263,15 +347,40 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	}
 
 	// Create and start WebSocket hub
-	hub := realtime.NewHub()
+	var broker realtime.Broker
+	switch cfg.HubBroker {
+	case "sqlite":
+		broker = realtime.NewSQLiteBroker(queries)
+	default:
+		broker = realtime.NewLocalBroker()
+	}
+	defer broker.Stop()
+	slog.Info("hub broker configured", "type", "lifecycle", "broker", cfg.HubBroker)
+
+	hub := realtime.NewHub(queries, broker)
 	go hub.Run()
 
+	// Start presence tracking (online/away/offline)
+	tracker := presence.New(queries, hub)
+	tracker.Start()
+	defer tracker.Stop()
+
 	// Start cleanup service (runs every hour)
-	cleaner := cleanup.New(queries, time.Hour)
+	cleaner := cleanup.New(queries, files, cfg.RetentionDays, handlers.NotifyExpired(queries, hub), time.Hour)
 	cleaner.Start()
 	defer cleaner.Stop()
 
-	srv := handlers.NewServer(queries, hub, mailer)
+	// Start deleting disappearing messages (checks every second)
+	expirer := expiry.New(queries, handlers.NotifyDisappeared(queries, hub), time.Second)
+	expirer.Start()
+	defer expirer.Stop()
+
+	// Start sending scheduled messages (checks every 30 seconds)
+	sched := scheduler.New(queries, handlers.SendScheduled(queries, hub), 30*time.Second)
+	sched.Start()
+	defer sched.Stop()
+
+	srv := handlers.NewServer(queries, hub, tracker, mailer, files)
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(cfg.Host, cfg.ListenAddr),
 		Handler: srv,
@@ -296,6 +405,12 @@ func run(ctx context.Context, w io.Writer, args []string) error {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer shutdownCancel()
 
+	// Close realtime connections first: hijacked WebSockets are not tracked by
+	// httpServer.Shutdown, and open event streams would otherwise hold it up
+	if err := hub.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to drain realtime clients", "type", "lifecycle", "error", err)
+	}
+
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("shutdown error: %w", err)
 	}
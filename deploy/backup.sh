@@ -24,7 +24,16 @@ gzip "$BACKUP_FILE"
 
 echo "Backup created: ${BACKUP_FILE}.gz"
 
+# Archive attachment files, which are stored next to the database
+DATA_DIR="$(dirname "$DB_PATH")"
+if [ -d "$DATA_DIR/attachments" ]; then
+    ATTACHMENTS_FILE="$BACKUP_DIR/wantok_attachments_$TIMESTAMP.tar.gz"
+    tar -czf "$ATTACHMENTS_FILE" -C "$DATA_DIR" attachments
+    echo "Backup created: $ATTACHMENTS_FILE"
+fi
+
 # Remove backups older than retention period
 find "$BACKUP_DIR" -name "wantok_*.db.gz" -type f -mtime +$RETENTION_DAYS -delete
+find "$BACKUP_DIR" -name "wantok_attachments_*.tar.gz" -type f -mtime +$RETENTION_DAYS -delete
 
 echo "Cleanup complete. Retained backups from last $RETENTION_DAYS days."
@@ -0,0 +1,352 @@
+package attachments
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, so a small upload
+	// can't expand into gigabytes of memory.
+	MaxPixels = 40_000_000
+
+	// MaxFrames bounds the number of frames in an animated GIF. Their
+	// pixels together also count against MaxPixels.
+	MaxFrames = 1000
+
+	// ThumbnailSize is the longest side of a thumbnail in pixels.
+	ThumbnailSize = 320
+)
+
+var (
+	ErrImageInvalid  = errors.New("image could not be read")
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+	ErrTooManyFrames = errors.New("animation has too many frames")
+)
+
+// Image is an uploaded image re-encoded without its metadata.
+type Image struct {
+	Data      []byte
+	Thumbnail []byte // Encoded as ThumbnailType of the image's type
+	Width     int
+	Height    int
+}
+
+// IsImage reports whether ProcessImage accepts contentType.
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+// ThumbnailType returns the type of the thumbnails ProcessImage renders
+// for images of contentType.
+func ThumbnailType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// ProcessImage decodes an image and encodes it again, which drops EXIF and
+// any other metadata, and renders a thumbnail. JPEGs are turned upright
+// first, since dropping EXIF also drops their orientation tag.
+func ProcessImage(data []byte, contentType string) (*Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrImageInvalid
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrImageInvalid
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	var out bytes.Buffer
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrImageInvalid
+		}
+		img = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: 90})
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrImageInvalid
+		}
+		err = png.Encode(&out, img)
+	case "image/gif":
+		// Keep every frame so animations still play. DecodeAll allocates
+		// each frame, so check their sizes before decoding any of them.
+		frames, pixels, ok := gifFrames(data)
+		if !ok {
+			return nil, ErrImageInvalid
+		}
+		if frames > MaxFrames {
+			return nil, ErrTooManyFrames
+		}
+		if pixels > MaxPixels {
+			return nil, ErrImageTooLarge
+		}
+		var g *gif.GIF
+		g, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(g.Image) == 0 {
+			return nil, ErrImageInvalid
+		}
+		canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+		draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+		img = canvas
+		err = gif.EncodeAll(&out, g)
+	default:
+		return nil, ErrImageInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Image{
+		Data:   out.Bytes(),
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	var thumb bytes.Buffer
+	if ThumbnailType(contentType) == "image/jpeg" {
+		err = jpeg.Encode(&thumb, thumbnail(img), &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&thumb, thumbnail(img))
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Thumbnail = thumb.Bytes()
+
+	return result, nil
+}
+
+// thumbnail scales img down to fit within ThumbnailSize, averaging the
+// source pixels that make up each thumbnail pixel. Small images keep their size.
+func thumbnail(img image.Image) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	tw, th := w, h
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			tw, th = ThumbnailSize, max(1, h*ThumbnailSize/w)
+		} else {
+			tw, th = max(1, w*ThumbnailSize/h), ThumbnailSize
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// orient turns img upright according to an EXIF orientation value (1-8).
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		// Orientations 5-8 swap width and height
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Rotated 90° clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Rotated 90° counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// gifFrames walks the blocks of a GIF without decoding them, counting its
+// frames and the pixels they add up to. Stops counting once past MaxFrames.
+// ok is false if the GIF is malformed.
+func gifFrames(data []byte) (frames int, pixels int64, ok bool) {
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return 0, 0, false
+	}
+
+	// Skip the header, logical screen descriptor and global color table
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // Extension
+			if i+2 > len(data) {
+				return 0, 0, false
+			}
+			i += 2
+		case 0x2C: // Image descriptor
+			if i+10 > len(data) {
+				return 0, 0, false
+			}
+			w := int64(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int64(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+			frames++
+			pixels += w * h
+			if frames > MaxFrames {
+				return frames, pixels, true
+			}
+			i += 10
+			if flags&0x80 != 0 {
+				// Local color table
+				i += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size
+			i++
+		case 0x3B: // Trailer
+			return frames, pixels, true
+		default:
+			return 0, 0, false
+		}
+
+		// Skip the data sub-blocks, up to the empty one that ends them
+		for {
+			if i >= len(data) {
+				return 0, 0, false
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG.
+// Returns 1 (upright) when there is none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the marker segments up to the start of the image data
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + size
+	}
+	return 1
+}
+
+// exifOrientation finds the orientation tag in the first IFD of a TIFF header.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value < 1 || value > 8 {
+				return 1
+			}
+			return value
+		}
+	}
+	return 1
+}
@@ -5,22 +5,39 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/dukerupert/wantok/internal/attachments"
 	"github.com/dukerupert/wantok/internal/store"
 )
 
+// orphanGracePeriod protects newly stored files: uploads are stored before
+// the message that refers to them is created.
+const orphanGracePeriod = time.Hour
+
+// ExpiredFunc is called with messages deleted for being past their retention
+// period, to scrub other copies of them and tell clients they are gone.
+type ExpiredFunc func(ctx context.Context, expired []store.DeleteOldMessagesRow)
+
 // Cleaner handles periodic cleanup of expired data.
 type Cleaner struct {
-	queries  *store.Queries
-	interval time.Duration
-	stop     chan struct{}
+	queries       *store.Queries
+	files         *attachments.Store
+	retentionDays int64
+	expired       ExpiredFunc
+	interval      time.Duration
+	stop          chan struct{}
 }
 
-// New creates a new Cleaner with the specified interval.
-func New(queries *store.Queries, interval time.Duration) *Cleaner {
+// New creates a new Cleaner with the specified interval. Messages are kept
+// for retentionDays unless their conversation overrides it; zero keeps them
+// forever.
+func New(queries *store.Queries, files *attachments.Store, retentionDays int64, expired ExpiredFunc, interval time.Duration) *Cleaner {
 	return &Cleaner{
-		queries:  queries,
-		interval: interval,
-		stop:     make(chan struct{}),
+		queries:       queries,
+		files:         files,
+		retentionDays: retentionDays,
+		expired:       expired,
+		interval:      interval,
+		stop:          make(chan struct{}),
 	}
 }
 
@@ -67,13 +84,19 @@ func (c *Cleaner) runCleanup() {
 		}
 	}
 
-	// Delete old messages (30+ days)
-	msgResult, err := c.queries.DeleteOldMessages(ctx)
+	// Delete messages past their retention period, along with their attachments
+	c.deleteOldMessages(ctx)
+
+	// Delete stored files no message refers to any more
+	c.deleteOrphanedFiles(ctx)
+
+	// Delete delivered realtime events (7+ days, keeping each user's newest)
+	evResult, err := c.queries.DeleteOldUserEvents(ctx)
 	if err != nil {
-		slog.Error("failed to delete old messages", "type", "cleanup", "error", err)
+		slog.Error("failed to delete old user events", "type", "cleanup", "error", err)
 	} else {
-		if count, _ := msgResult.RowsAffected(); count > 0 {
-			slog.Info("deleted old messages", "type", "cleanup", "count", count)
+		if count, _ := evResult.RowsAffected(); count > 0 {
+			slog.Info("deleted old user events", "type", "cleanup", "count", count)
 		}
 	}
 
@@ -96,4 +119,61 @@ func (c *Cleaner) runCleanup() {
 			slog.Info("deleted expired magic links", "type", "cleanup", "count", count)
 		}
 	}
+
+	// Delete cached link previews (7+ days, unless a message still shows them)
+	lpResult, err := c.queries.DeleteStaleLinkPreviews(ctx)
+	if err != nil {
+		slog.Error("failed to delete stale link previews", "type", "cleanup", "error", err)
+	} else {
+		if count, _ := lpResult.RowsAffected(); count > 0 {
+			slog.Info("deleted stale link previews", "type", "cleanup", "count", count)
+		}
+	}
+}
+
+// deleteOldMessages removes messages past their retention period.
+func (c *Cleaner) deleteOldMessages(ctx context.Context) {
+	expired, err := c.queries.DeleteOldMessages(ctx, c.retentionDays)
+	if err != nil {
+		slog.Error("failed to delete old messages", "type", "cleanup", "error", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+	slog.Info("deleted old messages", "type", "cleanup", "count", len(expired))
+	c.expired(ctx, expired)
+}
+
+// deleteOrphanedFiles removes stored attachment files that are no longer
+// referenced, once they are older than orphanGracePeriod.
+func (c *Cleaner) deleteOrphanedFiles(ctx context.Context) {
+	hashes, err := c.queries.ListAttachmentHashes(ctx)
+	if err != nil {
+		slog.Error("failed to list attachment hashes", "type", "cleanup", "error", err)
+		return
+	}
+	inUse := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		inUse[hash] = true
+	}
+
+	cutoff := time.Now().Add(-orphanGracePeriod)
+	var count int
+	err = c.files.Walk(func(hash string, modTime time.Time) {
+		if inUse[hash] || modTime.After(cutoff) {
+			return
+		}
+		if err := c.files.Remove(hash); err != nil {
+			slog.Error("failed to delete orphaned file", "type", "cleanup", "hash", hash, "error", err)
+			return
+		}
+		count++
+	})
+	if err != nil {
+		slog.Error("failed to scan attachment files", "type", "cleanup", "error", err)
+	}
+	if count > 0 {
+		slog.Info("deleted orphaned files", "type", "cleanup", "count", count)
+	}
 }
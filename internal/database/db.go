@@ -21,7 +21,26 @@ func New(path string) (*sql.DB, error) {
 		return nil, err
 	}
 	// setup database
+	migrate(db)
 
+	return db, nil
+}
+
+// NewMemory opens an empty in-memory database with the schema applied, for
+// tests. Every connection to :memory: gets a database of its own, so the
+// pool is limited to one connection.
+func NewMemory() (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	migrate(db)
+
+	return db, nil
+}
+
+func migrate(db *sql.DB) {
 	goose.SetBaseFS(embedMigrations)
 
 	if err := goose.SetDialect("sqlite"); err != nil {
@@ -31,6 +50,4 @@ func New(path string) (*sql.DB, error) {
 	if err := goose.Up(db, "migrations"); err != nil {
 		panic(err)
 	}
-
-	return db, nil
 }
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Set when a user's last connected client disconnects
+ALTER TABLE users ADD COLUMN last_seen_at TEXT;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN last_seen_at;
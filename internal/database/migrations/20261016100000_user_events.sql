@@ -0,0 +1,17 @@
+-- +goose Up
+-- Durable per-user event log for realtime delivery.
+-- seq increases per user so clients can resume after a reconnect.
+CREATE TABLE user_events (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (user_id, seq)
+);
+
+CREATE INDEX idx_user_events_created_at ON user_events(created_at);
+
+-- +goose Down
+DROP INDEX idx_user_events_created_at;
+DROP TABLE user_events;
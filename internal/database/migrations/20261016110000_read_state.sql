@@ -0,0 +1,17 @@
+-- +goose Up
+-- Per-user, per-conversation pointers into the other participant's messages
+CREATE TABLE conversation_reads (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    other_user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_delivered_message_id INTEGER NOT NULL DEFAULT 0,
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (user_id, other_user_id)
+);
+
+-- Whether the user shares read receipts with the people they talk to
+ALTER TABLE users ADD COLUMN read_receipts INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN read_receipts;
+DROP TABLE conversation_reads;
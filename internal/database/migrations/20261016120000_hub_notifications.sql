@@ -0,0 +1,17 @@
+-- +goose Up
+-- Realtime messages shared between server processes by the SQLite hub broker.
+-- Rows are short-lived; each process polls for rows written by the others.
+CREATE TABLE hub_notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    node_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL DEFAULT 0,
+    data TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_hub_notifications_created_at ON hub_notifications(created_at);
+
+-- +goose Down
+DROP INDEX idx_hub_notifications_created_at;
+DROP TABLE hub_notifications;
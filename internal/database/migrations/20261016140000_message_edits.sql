@@ -0,0 +1,18 @@
+-- +goose Up
+-- Set when the sender last edited the message
+ALTER TABLE messages ADD COLUMN edited_at TEXT;
+
+-- Earlier contents of edited messages, oldest first
+CREATE TABLE message_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    replaced_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_message_revisions_message_id ON message_revisions(message_id);
+
+-- +goose Down
+DROP INDEX idx_message_revisions_message_id;
+DROP TABLE message_revisions;
+ALTER TABLE messages DROP COLUMN edited_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Set when the sender deletes a message for everyone. The row stays as a
+-- tombstone with empty content so ordering and event replay still work.
+ALTER TABLE messages ADD COLUMN deleted_at TEXT;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN deleted_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- The message this one replies to. Deliberately not a foreign key: the parent
+-- may expire before the reply, and the reply should still show it quoted one.
+ALTER TABLE messages ADD COLUMN reply_to_id INTEGER;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN reply_to_id;
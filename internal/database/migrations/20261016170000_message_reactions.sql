@@ -0,0 +1,12 @@
+-- +goose Up
+-- One row per user per emoji on a message; reacting again removes the row
+CREATE TABLE message_reactions (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+-- +goose Down
+DROP TABLE message_reactions;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- One file per message. hash and thumbnail_hash name content-addressed files
+-- in the attachments directory, which several rows may share.
+CREATE TABLE attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL UNIQUE REFERENCES messages(id) ON DELETE CASCADE,
+    hash TEXT NOT NULL,
+    thumbnail_hash TEXT,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER,
+    height INTEGER,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+-- +goose Down
+DROP TABLE attachments;
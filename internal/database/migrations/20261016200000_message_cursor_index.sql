@@ -0,0 +1,8 @@
+-- +goose Up
+-- Pages of a direct conversation are read by id from each side of the pair.
+-- Group pages use idx_messages_conversation_id, which is already ordered by
+-- id within a group since every index ends with the rowid.
+CREATE INDEX idx_messages_participants ON messages(sender_id, recipient_id, id);
+
+-- +goose Down
+DROP INDEX idx_messages_participants;
@@ -0,0 +1,259 @@
+-- +goose Up
+-- One row per user per conversation they can see, so the conversation list is
+-- a single indexed read. A direct conversation has a row for each participant
+-- (other_user_id); a group has a row for each member (conversation_id).
+-- last_message holds the start of the newest message's content, enough for a
+-- preview. The triggers keep rows in step as messages are sent, edited,
+-- deleted and expire, as members join and leave, and as users read.
+CREATE TABLE conversation_summaries (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    other_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+    last_message_id INTEGER,
+    last_message TEXT NOT NULL DEFAULT '',
+    last_message_deleted_at TEXT,
+    last_message_at TEXT NOT NULL,
+    unread_count INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK ((other_user_id IS NULL) <> (conversation_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_conversation_summaries_direct ON conversation_summaries(user_id, other_user_id) WHERE other_user_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_conversation_summaries_group ON conversation_summaries(conversation_id, user_id) WHERE conversation_id IS NOT NULL;
+CREATE INDEX idx_conversation_summaries_list ON conversation_summaries(user_id, last_message_at, last_message_id);
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_direct_insert AFTER INSERT ON messages
+WHEN new.recipient_id IS NOT NULL BEGIN
+    INSERT OR IGNORE INTO conversation_summaries (user_id, other_user_id, last_message_at)
+    VALUES (new.sender_id, new.recipient_id, new.created_at),
+           (new.recipient_id, new.sender_id, new.created_at);
+    UPDATE conversation_summaries SET
+        last_message_id = new.id,
+        last_message = substr(new.content, 1, 100),
+        last_message_deleted_at = new.deleted_at,
+        last_message_at = new.created_at,
+        unread_count = unread_count + (user_id = new.recipient_id),
+        updated_at = datetime('now')
+    WHERE (user_id = new.sender_id AND other_user_id = new.recipient_id)
+       OR (user_id = new.recipient_id AND other_user_id = new.sender_id);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_group_insert AFTER INSERT ON messages
+WHEN new.conversation_id IS NOT NULL BEGIN
+    UPDATE conversation_summaries SET
+        last_message_id = new.id,
+        last_message = substr(new.content, 1, 100),
+        last_message_deleted_at = new.deleted_at,
+        last_message_at = new.created_at,
+        unread_count = unread_count + (user_id != new.sender_id),
+        updated_at = datetime('now')
+    WHERE conversation_id = new.conversation_id;
+END;
+-- +goose StatementEnd
+
+-- Edits and unsends change the preview but not the conversation's place in the list
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_message_update AFTER UPDATE OF content, deleted_at ON messages BEGIN
+    UPDATE conversation_summaries SET
+        last_message = substr(new.content, 1, 100),
+        last_message_deleted_at = new.deleted_at,
+        updated_at = datetime('now')
+    WHERE last_message_id = new.id
+      AND (conversation_id = new.conversation_id
+           OR (user_id = new.sender_id AND other_user_id = new.recipient_id)
+           OR (user_id = new.recipient_id AND other_user_id = new.sender_id));
+END;
+-- +goose StatementEnd
+
+-- A direct conversation leaves the list once none of its messages remain
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_direct_delete AFTER DELETE ON messages
+WHEN old.recipient_id IS NOT NULL BEGIN
+    DELETE FROM conversation_summaries
+    WHERE ((user_id = old.sender_id AND other_user_id = old.recipient_id)
+        OR (user_id = old.recipient_id AND other_user_id = old.sender_id))
+      AND NOT EXISTS (
+          SELECT 1 FROM messages m
+          WHERE (m.sender_id = old.sender_id AND m.recipient_id = old.recipient_id)
+             OR (m.sender_id = old.recipient_id AND m.recipient_id = old.sender_id)
+      );
+    UPDATE conversation_summaries SET
+        unread_count = unread_count - 1,
+        updated_at = datetime('now')
+    WHERE user_id = old.recipient_id
+      AND other_user_id = old.sender_id
+      AND old.id > COALESCE((
+          SELECT r.last_read_message_id FROM conversation_reads r
+          WHERE r.user_id = old.recipient_id AND r.other_user_id = old.sender_id
+      ), 0);
+    UPDATE conversation_summaries SET
+        (last_message_id, last_message, last_message_deleted_at, last_message_at) = (
+            SELECT m.id, substr(m.content, 1, 100), m.deleted_at, m.created_at
+            FROM messages m
+            WHERE (m.sender_id = old.sender_id AND m.recipient_id = old.recipient_id)
+               OR (m.sender_id = old.recipient_id AND m.recipient_id = old.sender_id)
+            ORDER BY m.id DESC
+            LIMIT 1
+        ),
+        updated_at = datetime('now')
+    WHERE last_message_id = old.id
+      AND ((user_id = old.sender_id AND other_user_id = old.recipient_id)
+        OR (user_id = old.recipient_id AND other_user_id = old.sender_id));
+END;
+-- +goose StatementEnd
+
+-- A group with nothing left since the member joined falls back to when they joined
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_group_delete AFTER DELETE ON messages
+WHEN old.conversation_id IS NOT NULL BEGIN
+    UPDATE conversation_summaries SET
+        unread_count = unread_count - 1,
+        updated_at = datetime('now')
+    WHERE conversation_id = old.conversation_id
+      AND user_id != old.sender_id
+      AND old.id > (
+          SELECT MAX(cm.joined_after_message_id, cm.last_read_message_id)
+          FROM conversation_members cm
+          WHERE cm.conversation_id = old.conversation_id
+            AND cm.user_id = conversation_summaries.user_id
+      );
+    UPDATE conversation_summaries SET
+        (last_message_id, last_message, last_message_deleted_at, last_message_at) = (
+            SELECT lm.id, COALESCE(substr(lm.content, 1, 100), ''), lm.deleted_at, COALESCE(lm.created_at, cm.joined_at)
+            FROM conversation_members cm
+            LEFT JOIN messages lm ON lm.id = (
+                SELECT MAX(m.id) FROM messages m
+                WHERE m.conversation_id = cm.conversation_id AND m.id > cm.joined_after_message_id
+            )
+            WHERE cm.conversation_id = old.conversation_id
+              AND cm.user_id = conversation_summaries.user_id
+        ),
+        updated_at = datetime('now')
+    WHERE conversation_id = old.conversation_id
+      AND last_message_id = old.id;
+END;
+-- +goose StatementEnd
+
+-- New members start with nothing to read; messages from before they joined stay hidden
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_member_insert AFTER INSERT ON conversation_members BEGIN
+    INSERT INTO conversation_summaries (user_id, conversation_id, last_message_at)
+    VALUES (new.user_id, new.conversation_id, new.joined_at);
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_member_delete AFTER DELETE ON conversation_members BEGIN
+    DELETE FROM conversation_summaries
+    WHERE conversation_id = old.conversation_id AND user_id = old.user_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_member_read AFTER UPDATE OF last_read_message_id ON conversation_members BEGIN
+    UPDATE conversation_summaries SET
+        unread_count = (
+            SELECT COUNT(*) FROM messages m
+            WHERE m.conversation_id = new.conversation_id
+              AND m.id > new.joined_after_message_id
+              AND m.id > new.last_read_message_id
+              AND m.sender_id != new.user_id
+        ),
+        updated_at = datetime('now')
+    WHERE conversation_id = new.conversation_id AND user_id = new.user_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_direct_read_insert AFTER INSERT ON conversation_reads BEGIN
+    UPDATE conversation_summaries SET
+        unread_count = (
+            SELECT COUNT(*) FROM messages m
+            WHERE m.sender_id = new.other_user_id
+              AND m.recipient_id = new.user_id
+              AND m.id > new.last_read_message_id
+        ),
+        updated_at = datetime('now')
+    WHERE user_id = new.user_id AND other_user_id = new.other_user_id;
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER conversation_summaries_direct_read_update AFTER UPDATE OF last_read_message_id ON conversation_reads BEGIN
+    UPDATE conversation_summaries SET
+        unread_count = (
+            SELECT COUNT(*) FROM messages m
+            WHERE m.sender_id = new.other_user_id
+              AND m.recipient_id = new.user_id
+              AND m.id > new.last_read_message_id
+        ),
+        updated_at = datetime('now')
+    WHERE user_id = new.user_id AND other_user_id = new.other_user_id;
+END;
+-- +goose StatementEnd
+
+-- Summarise the conversations that existed before the table did
+INSERT INTO conversation_summaries (user_id, other_user_id, last_message_id, last_message, last_message_deleted_at, last_message_at, unread_count)
+SELECT
+    p.user_id,
+    p.other_user_id,
+    lm.id,
+    substr(lm.content, 1, 100),
+    lm.deleted_at,
+    lm.created_at,
+    (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.sender_id = p.other_user_id
+          AND m.recipient_id = p.user_id
+          AND m.id > COALESCE((
+              SELECT r.last_read_message_id FROM conversation_reads r
+              WHERE r.user_id = p.user_id AND r.other_user_id = p.other_user_id
+          ), 0)
+    )
+FROM (
+    SELECT user_id, other_user_id, MAX(id) AS last_message_id
+    FROM (
+        SELECT sender_id AS user_id, recipient_id AS other_user_id, id FROM messages WHERE recipient_id IS NOT NULL
+        UNION ALL
+        SELECT recipient_id, sender_id, id FROM messages WHERE recipient_id IS NOT NULL
+    )
+    GROUP BY user_id, other_user_id
+) p
+JOIN messages lm ON lm.id = p.last_message_id;
+
+INSERT INTO conversation_summaries (user_id, conversation_id, last_message_id, last_message, last_message_deleted_at, last_message_at, unread_count)
+SELECT
+    cm.user_id,
+    cm.conversation_id,
+    lm.id,
+    COALESCE(substr(lm.content, 1, 100), ''),
+    lm.deleted_at,
+    COALESCE(lm.created_at, cm.joined_at),
+    (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = cm.conversation_id
+          AND m.id > cm.joined_after_message_id
+          AND m.id > cm.last_read_message_id
+          AND m.sender_id != cm.user_id
+    )
+FROM conversation_members cm
+LEFT JOIN messages lm ON lm.id = (
+    SELECT MAX(m.id) FROM messages m
+    WHERE m.conversation_id = cm.conversation_id AND m.id > cm.joined_after_message_id
+);
+
+-- +goose Down
+DROP TRIGGER conversation_summaries_direct_read_update;
+DROP TRIGGER conversation_summaries_direct_read_insert;
+DROP TRIGGER conversation_summaries_member_read;
+DROP TRIGGER conversation_summaries_member_delete;
+DROP TRIGGER conversation_summaries_member_insert;
+DROP TRIGGER conversation_summaries_group_delete;
+DROP TRIGGER conversation_summaries_direct_delete;
+DROP TRIGGER conversation_summaries_message_update;
+DROP TRIGGER conversation_summaries_group_insert;
+DROP TRIGGER conversation_summaries_direct_insert;
+DROP TABLE conversation_summaries;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- Messages waiting to be sent at send_at, a UTC timestamp in the same format
+-- as datetime('now'). The scheduler removes each row as it sends the message.
+CREATE TABLE scheduled_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sender_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    recipient_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    send_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK ((recipient_id IS NULL) <> (conversation_id IS NULL))
+);
+
+CREATE INDEX idx_scheduled_messages_sender_id ON scheduled_messages(sender_id);
+CREATE INDEX idx_scheduled_messages_send_at ON scheduled_messages(send_at);
+
+-- +goose Down
+DROP INDEX idx_scheduled_messages_send_at;
+DROP INDEX idx_scheduled_messages_sender_id;
+DROP TABLE scheduled_messages;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Each user's unsent text per conversation, so it follows them between devices.
+-- A direct conversation is keyed by other_user_id, a group by conversation_id.
+CREATE TABLE drafts (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    other_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK ((other_user_id IS NULL) <> (conversation_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_drafts_direct ON drafts(user_id, other_user_id) WHERE other_user_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_drafts_group ON drafts(user_id, conversation_id) WHERE conversation_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_drafts_group;
+DROP INDEX idx_drafts_direct;
+DROP TABLE drafts;
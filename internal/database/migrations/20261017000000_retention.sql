@@ -0,0 +1,26 @@
+-- +goose Up
+-- Set on messages kept out of automatic cleanup, such as those under legal hold
+ALTER TABLE messages ADD COLUMN keep INTEGER NOT NULL DEFAULT 0;
+
+-- Per-conversation overrides of the instance's message retention. A direct
+-- conversation has one row for the pair, with the lower user ID in user_id;
+-- a group is keyed by conversation_id. retention_days is NULL to use the
+-- instance default, and keep holds every message in the conversation.
+CREATE TABLE conversation_settings (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    other_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+    retention_days INTEGER,
+    keep INTEGER NOT NULL DEFAULT 0,
+    updated_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK ((conversation_id IS NULL) = (user_id IS NOT NULL AND other_user_id IS NOT NULL AND user_id < other_user_id))
+);
+
+CREATE UNIQUE INDEX idx_conversation_settings_direct ON conversation_settings(user_id, other_user_id) WHERE conversation_id IS NULL;
+CREATE UNIQUE INDEX idx_conversation_settings_group ON conversation_settings(conversation_id) WHERE conversation_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_conversation_settings_group;
+DROP INDEX idx_conversation_settings_direct;
+DROP TABLE conversation_settings;
+ALTER TABLE messages DROP COLUMN keep;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Seconds after sending that new messages in the conversation disappear; NULL keeps them
+ALTER TABLE conversation_settings ADD COLUMN disappear_seconds INTEGER;
+
+-- Set at insert from the conversation's timer
+ALTER TABLE messages ADD COLUMN expires_at TEXT;
+
+CREATE INDEX idx_messages_expires_at ON messages(expires_at) WHERE expires_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_messages_expires_at;
+ALTER TABLE messages DROP COLUMN expires_at;
+ALTER TABLE conversation_settings DROP COLUMN disappear_seconds;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Chosen by the sending client so a retried send returns the original message
+ALTER TABLE messages ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX idx_messages_client_id ON messages(sender_id, client_id) WHERE client_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_messages_client_id;
+ALTER TABLE messages DROP COLUMN client_id;
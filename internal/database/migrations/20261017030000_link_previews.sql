@@ -0,0 +1,22 @@
+-- +goose Up
+-- Open Graph metadata fetched for links in messages, shared by every message
+-- with the same link. Pages that couldn't be previewed are kept with an empty
+-- title so they aren't fetched again straight away.
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    fetched_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+-- The link previewed for the message, set once the preview is fetched
+ALTER TABLE messages ADD COLUMN preview_url TEXT;
+
+CREATE INDEX idx_messages_preview_url ON messages(preview_url) WHERE preview_url IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_messages_preview_url;
+ALTER TABLE messages DROP COLUMN preview_url;
+DROP TABLE link_previews;
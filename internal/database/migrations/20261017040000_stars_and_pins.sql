@@ -0,0 +1,23 @@
+-- +goose Up
+-- Messages a user has starred for themselves
+CREATE TABLE message_stars (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (user_id, message_id)
+);
+
+CREATE INDEX idx_message_stars_message ON message_stars(message_id);
+
+-- Messages pinned for everyone in their conversation. Pinned messages are
+-- exempt from the retention period until they are unpinned.
+CREATE TABLE message_pins (
+    message_id INTEGER PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    pinned_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+-- +goose Down
+DROP TABLE message_pins;
+DROP INDEX idx_message_stars_message;
+DROP TABLE message_stars;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Set while the scheduler sends a due message; the row is removed once the
+-- message is stored. A message that can never be sent is kept with
+-- failed_at and the reason in failure, for its sender to edit or cancel.
+ALTER TABLE scheduled_messages ADD COLUMN claimed_at TEXT;
+ALTER TABLE scheduled_messages ADD COLUMN failed_at TEXT;
+ALTER TABLE scheduled_messages ADD COLUMN failure TEXT;
+
+-- +goose Down
+ALTER TABLE scheduled_messages DROP COLUMN failure;
+ALTER TABLE scheduled_messages DROP COLUMN failed_at;
+ALTER TABLE scheduled_messages DROP COLUMN claimed_at;
@@ -0,0 +1,18 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (message_id, hash, thumbnail_hash, filename, content_type, size, width, height)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments
+WHERE id = ?;
+
+-- name: DeleteMessageAttachment :exec
+DELETE FROM attachments
+WHERE message_id = ?;
+
+-- name: ListAttachmentHashes :many
+-- Every stored file still in use, as an original or a thumbnail.
+SELECT hash FROM attachments
+UNION
+SELECT thumbnail_hash FROM attachments WHERE thumbnail_hash IS NOT NULL;
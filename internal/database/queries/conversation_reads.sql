@@ -0,0 +1,26 @@
+-- name: MarkConversationDelivered :one
+INSERT INTO conversation_reads (user_id, other_user_id, last_delivered_message_id)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, other_user_id) DO UPDATE SET
+    last_delivered_message_id = MAX(last_delivered_message_id, excluded.last_delivered_message_id),
+    updated_at = datetime('now')
+RETURNING *;
+
+-- name: MarkConversationRead :one
+-- Reading a message also counts as delivering it.
+INSERT INTO conversation_reads (user_id, other_user_id, last_delivered_message_id, last_read_message_id)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (user_id, other_user_id) DO UPDATE SET
+    last_delivered_message_id = MAX(last_delivered_message_id, excluded.last_delivered_message_id),
+    last_read_message_id = MAX(last_read_message_id, excluded.last_read_message_id),
+    updated_at = datetime('now')
+RETURNING *;
+
+-- name: GetConversationRead :one
+SELECT * FROM conversation_reads
+WHERE user_id = ? AND other_user_id = ?;
+
+-- name: GetLatestMessageIDFrom :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) AS id
+FROM messages
+WHERE sender_id = ? AND recipient_id = ?;
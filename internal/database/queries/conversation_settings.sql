@@ -0,0 +1,53 @@
+-- name: GetDirectSettings :one
+-- user_id must be the lower of the two user IDs.
+SELECT retention_days, keep, disappear_seconds FROM conversation_settings
+WHERE user_id = ? AND other_user_id = ? AND conversation_id IS NULL;
+
+-- name: GetGroupSettings :one
+SELECT retention_days, keep, disappear_seconds FROM conversation_settings
+WHERE conversation_id = ?;
+
+-- name: SetDirectRetention :exec
+-- user_id must be the lower of the two user IDs.
+INSERT INTO conversation_settings (user_id, other_user_id, retention_days)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, other_user_id) WHERE conversation_id IS NULL DO UPDATE SET
+    retention_days = excluded.retention_days,
+    updated_at = datetime('now');
+
+-- name: SetGroupRetention :exec
+INSERT INTO conversation_settings (conversation_id, retention_days)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) WHERE conversation_id IS NOT NULL DO UPDATE SET
+    retention_days = excluded.retention_days,
+    updated_at = datetime('now');
+
+-- name: SetDirectKeep :exec
+-- user_id must be the lower of the two user IDs.
+INSERT INTO conversation_settings (user_id, other_user_id, keep)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, other_user_id) WHERE conversation_id IS NULL DO UPDATE SET
+    keep = excluded.keep,
+    updated_at = datetime('now');
+
+-- name: SetDirectTimer :exec
+-- user_id must be the lower of the two user IDs.
+INSERT INTO conversation_settings (user_id, other_user_id, disappear_seconds)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, other_user_id) WHERE conversation_id IS NULL DO UPDATE SET
+    disappear_seconds = excluded.disappear_seconds,
+    updated_at = datetime('now');
+
+-- name: SetGroupTimer :exec
+INSERT INTO conversation_settings (conversation_id, disappear_seconds)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) WHERE conversation_id IS NOT NULL DO UPDATE SET
+    disappear_seconds = excluded.disappear_seconds,
+    updated_at = datetime('now');
+
+-- name: SetGroupKeep :exec
+INSERT INTO conversation_settings (conversation_id, keep)
+VALUES (?, ?)
+ON CONFLICT (conversation_id) WHERE conversation_id IS NOT NULL DO UPDATE SET
+    keep = excluded.keep,
+    updated_at = datetime('now');
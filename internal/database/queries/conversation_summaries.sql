@@ -0,0 +1,18 @@
+-- name: ListConversationSummaries :many
+-- The user's direct and group conversations, most recent activity first.
+-- last_message_id is NULL for a group with nothing sent since the user joined.
+SELECT
+    s.other_user_id,
+    s.conversation_id,
+    CAST(COALESCE(u.display_name, c.name) AS TEXT) AS display_name,
+    u.last_seen_at AS other_user_last_seen_at,
+    s.last_message_id,
+    s.last_message,
+    s.last_message_deleted_at,
+    s.last_message_at,
+    s.unread_count
+FROM conversation_summaries s
+LEFT JOIN users u ON u.id = s.other_user_id
+LEFT JOIN conversations c ON c.id = s.conversation_id
+WHERE s.user_id = ?
+ORDER BY s.last_message_at DESC, s.last_message_id DESC;
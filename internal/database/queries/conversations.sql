@@ -0,0 +1,157 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (name, created_by)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: GetConversation :one
+SELECT * FROM conversations
+WHERE id = ?;
+
+-- name: DeleteConversation :exec
+DELETE FROM conversations
+WHERE id = ?;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_after_message_id)
+VALUES (?, ?, (SELECT COALESCE(MAX(id), 0) FROM messages))
+ON CONFLICT (conversation_id, user_id) DO NOTHING;
+
+-- name: RemoveConversationMember :execresult
+DELETE FROM conversation_members
+WHERE conversation_id = ? AND user_id = ?;
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = ? AND user_id = ?;
+
+-- name: ListConversationMembers :many
+SELECT
+    cm.user_id,
+    cm.joined_at,
+    u.display_name
+FROM conversation_members cm
+JOIN users u ON u.id = cm.user_id
+WHERE cm.conversation_id = ?
+ORDER BY cm.joined_at, u.display_name;
+
+-- name: ListConversationMemberIDs :many
+SELECT user_id FROM conversation_members
+WHERE conversation_id = ?;
+
+-- name: ListGroupMessageViewerIDs :many
+-- Members who joined before the message was sent.
+SELECT user_id FROM conversation_members
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND joined_after_message_id < sqlc.arg(message_id);
+
+-- name: CreateGroupMessage :one
+-- expires_at comes from the group's disappearing-message timer, if set.
+-- Returns no row if the sender already used client_id; see GetSentMessage.
+INSERT INTO messages (sender_id, conversation_id, content, reply_to_id, client_id, expires_at)
+VALUES (
+    sqlc.arg(sender_id),
+    sqlc.arg(conversation_id),
+    sqlc.arg(content),
+    sqlc.arg(reply_to_id),
+    sqlc.arg(client_id),
+    (
+        SELECT datetime('now', printf('+%d seconds', s.disappear_seconds))
+        FROM conversation_settings s
+        WHERE s.conversation_id = sqlc.arg(conversation_id)
+          AND s.disappear_seconds IS NOT NULL
+    )
+)
+ON CONFLICT (sender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: GetGroupMessages :many
+-- Only messages sent since the member joined are visible, including as quoted replies.
+-- Returns up to limit messages with ids between after_id and before_id, newest first.
+-- reactions is a JSON array of {emoji, user_ids}, grouped by emoji in the order first used.
+-- starred is 1 if user_id starred the message.
+SELECT
+    m.id,
+    m.sender_id,
+    m.conversation_id,
+    m.content,
+    m.created_at,
+    m.edited_at,
+    m.deleted_at,
+    m.reply_to_id,
+    m.expires_at,
+    u.display_name AS sender_display_name,
+    p.content AS reply_content,
+    p.deleted_at AS reply_deleted_at,
+    pu.display_name AS reply_sender_display_name,
+    a.id AS attachment_id,
+    a.filename AS attachment_filename,
+    a.content_type AS attachment_content_type,
+    a.size AS attachment_size,
+    a.width AS attachment_width,
+    a.height AS attachment_height,
+    a.thumbnail_hash AS attachment_thumbnail_hash,
+    lp.url AS preview_url,
+    lp.title AS preview_title,
+    lp.description AS preview_description,
+    lp.image_url AS preview_image_url,
+    lp.site_name AS preview_site_name,
+    pin.pinned_at,
+    CAST(st.message_id IS NOT NULL AS INTEGER) AS starred,
+    CAST(COALESCE((
+        SELECT json_group_array(json_object('emoji', r.emoji, 'user_ids', json(r.user_ids)))
+        FROM (
+            SELECT emoji, json_group_array(user_id) AS user_ids
+            FROM message_reactions
+            WHERE message_id = m.id
+            GROUP BY emoji
+            ORDER BY MIN(rowid)
+        ) r
+    ), '[]') AS TEXT) AS reactions
+FROM messages m
+JOIN users u ON m.sender_id = u.id
+JOIN conversation_members cm ON cm.conversation_id = m.conversation_id
+LEFT JOIN messages p ON p.id = m.reply_to_id AND p.id > cm.joined_after_message_id
+LEFT JOIN users pu ON pu.id = p.sender_id
+LEFT JOIN attachments a ON a.message_id = m.id
+LEFT JOIN link_previews lp ON lp.url = m.preview_url AND lp.title <> ''
+LEFT JOIN message_pins pin ON pin.message_id = m.id
+LEFT JOIN message_stars st ON st.message_id = m.id AND st.user_id = sqlc.arg(user_id)
+WHERE cm.user_id = sqlc.arg(user_id)
+  AND cm.conversation_id = sqlc.arg(conversation_id)
+  AND m.id > cm.joined_after_message_id
+  AND m.id < sqlc.arg(before_id)
+  AND m.id > sqlc.arg(after_id)
+ORDER BY m.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: MarkGroupRead :exec
+UPDATE conversation_members
+SET last_read_message_id = sqlc.arg(last_read_message_id)
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND user_id = sqlc.arg(user_id)
+  AND last_read_message_id < sqlc.arg(last_read_message_id);
+
+-- name: GetLatestGroupMessageID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) AS id
+FROM messages
+WHERE conversation_id = ?;
+
+-- name: SharesConversation :one
+-- Reports whether two users are in a group together or have a direct conversation.
+SELECT CAST(EXISTS (
+    SELECT 1 FROM conversation_members a
+    JOIN conversation_members b ON b.conversation_id = a.conversation_id
+    WHERE a.user_id = sqlc.arg(user_id) AND b.user_id = sqlc.arg(other_user_id)
+) OR EXISTS (
+    SELECT 1 FROM conversation_summaries
+    WHERE user_id = sqlc.arg(user_id) AND other_user_id = sqlc.arg(other_user_id)
+) AS INTEGER) AS shared;
+
+-- name: ListContactIDs :many
+-- Users who are in a group with user_id or have a direct conversation with them.
+SELECT b.user_id FROM conversation_members a
+JOIN conversation_members b ON b.conversation_id = a.conversation_id
+WHERE a.user_id = sqlc.arg(user_id) AND b.user_id <> sqlc.arg(user_id)
+UNION
+SELECT other_user_id FROM conversation_summaries
+WHERE user_id = sqlc.arg(user_id) AND other_user_id IS NOT NULL;
@@ -0,0 +1,35 @@
+-- name: SaveDirectDraft :exec
+INSERT INTO drafts (user_id, other_user_id, content)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, other_user_id) WHERE other_user_id IS NOT NULL DO UPDATE SET
+    content = excluded.content,
+    updated_at = datetime('now');
+
+-- name: SaveGroupDraft :exec
+INSERT INTO drafts (user_id, conversation_id, content)
+VALUES (?, ?, ?)
+ON CONFLICT (user_id, conversation_id) WHERE conversation_id IS NOT NULL DO UPDATE SET
+    content = excluded.content,
+    updated_at = datetime('now');
+
+-- name: GetDirectDraft :one
+SELECT content FROM drafts
+WHERE user_id = ? AND other_user_id = ?;
+
+-- name: GetGroupDraft :one
+SELECT content FROM drafts
+WHERE user_id = ? AND conversation_id = ?;
+
+-- name: DeleteDirectDraft :execresult
+DELETE FROM drafts
+WHERE user_id = ? AND other_user_id = ?;
+
+-- name: DeleteGroupDraft :execresult
+DELETE FROM drafts
+WHERE user_id = ? AND conversation_id = ?;
+
+-- name: ListDrafts :many
+-- The user's drafts, most recently changed first.
+SELECT * FROM drafts
+WHERE user_id = ?
+ORDER BY updated_at DESC;
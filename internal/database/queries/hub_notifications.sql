@@ -0,0 +1,31 @@
+-- name: CreateHubNotification :exec
+INSERT INTO hub_notifications (node_id, user_id, seq, data)
+VALUES (?, ?, ?, ?);
+
+-- name: ListHubNotificationsAfter :many
+SELECT * FROM hub_notifications
+WHERE id > ? AND node_id != ?
+ORDER BY id
+LIMIT ?;
+
+-- name: GetLatestHubNotificationID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) AS id
+FROM hub_notifications;
+
+-- name: DeleteOldHubNotifications :execresult
+DELETE FROM hub_notifications
+WHERE created_at < datetime('now', '-5 minutes');
+
+-- name: ScrubMessageNotifications :exec
+-- Removes a deleted message's text from notifications not yet pruned.
+UPDATE hub_notifications
+SET data = json_remove(json_set(data, '$.payload.content', '', '$.payload.deleted', json('true')), '$.payload.content_html', '$.payload.edited_at', '$.payload.preview')
+WHERE json_extract(data, '$.type') IN ('message', 'edited', 'message_updated', 'pinned')
+  AND json_extract(data, '$.payload.id') = CAST(sqlc.arg(message_id) AS INTEGER);
+
+-- name: ScrubReplyNotifications :exec
+-- Removes a deleted message's text from notifications for replies that quote it.
+UPDATE hub_notifications
+SET data = json_remove(json_set(data, '$.payload.reply_to.deleted', json('true')), '$.payload.reply_to.snippet')
+WHERE json_extract(data, '$.type') IN ('message', 'edited')
+  AND json_extract(data, '$.payload.reply_to.id') = CAST(sqlc.arg(message_id) AS INTEGER);
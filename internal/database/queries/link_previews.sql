@@ -0,0 +1,21 @@
+-- name: GetLinkPreview :one
+SELECT * FROM link_previews
+WHERE url = ?;
+
+-- name: SaveLinkPreview :exec
+INSERT INTO link_previews (url, title, description, image_url, site_name)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (url) DO UPDATE SET
+    title = excluded.title,
+    description = excluded.description,
+    image_url = excluded.image_url,
+    site_name = excluded.site_name,
+    fetched_at = datetime('now');
+
+-- name: DeleteStaleLinkPreviews :execresult
+-- Deletes previews fetched over a week ago that no message shows.
+DELETE FROM link_previews
+WHERE fetched_at < datetime('now', '-7 days')
+  AND NOT EXISTS (
+      SELECT 1 FROM messages WHERE messages.preview_url = link_previews.url
+  );
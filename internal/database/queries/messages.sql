@@ -1,36 +1,204 @@
 -- name: CreateMessage :one
-INSERT INTO messages (sender_id, recipient_id, content)
-VALUES (?, ?, ?)
+-- expires_at comes from the conversation's disappearing-message timer, if set.
+-- Returns no row if the sender already used client_id; see GetSentMessage.
+INSERT INTO messages (sender_id, recipient_id, content, reply_to_id, client_id, expires_at)
+VALUES (
+    sqlc.arg(sender_id),
+    sqlc.arg(recipient_id),
+    sqlc.arg(content),
+    sqlc.arg(reply_to_id),
+    sqlc.arg(client_id),
+    (
+        SELECT datetime('now', printf('+%d seconds', s.disappear_seconds))
+        FROM conversation_settings s
+        WHERE s.conversation_id IS NULL
+          AND s.user_id = min(sqlc.arg(sender_id), sqlc.arg(recipient_id))
+          AND s.other_user_id = max(sqlc.arg(sender_id), sqlc.arg(recipient_id))
+          AND s.disappear_seconds IS NOT NULL
+    )
+)
+ON CONFLICT (sender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING
 RETURNING *;
 
 -- name: GetConversationMessages :many
+-- Returns up to limit messages with ids between after_id and before_id, newest first.
+-- reactions is a JSON array of {emoji, user_ids}, grouped by emoji in the order first used.
+-- starred is 1 if user_id starred the message.
 SELECT
     m.id,
     m.sender_id,
     m.recipient_id,
     m.content,
     m.created_at,
-    u.display_name AS sender_display_name
+    m.edited_at,
+    m.deleted_at,
+    m.reply_to_id,
+    m.expires_at,
+    u.display_name AS sender_display_name,
+    p.content AS reply_content,
+    p.deleted_at AS reply_deleted_at,
+    pu.display_name AS reply_sender_display_name,
+    a.id AS attachment_id,
+    a.filename AS attachment_filename,
+    a.content_type AS attachment_content_type,
+    a.size AS attachment_size,
+    a.width AS attachment_width,
+    a.height AS attachment_height,
+    a.thumbnail_hash AS attachment_thumbnail_hash,
+    lp.url AS preview_url,
+    lp.title AS preview_title,
+    lp.description AS preview_description,
+    lp.image_url AS preview_image_url,
+    lp.site_name AS preview_site_name,
+    pin.pinned_at,
+    CAST(st.message_id IS NOT NULL AS INTEGER) AS starred,
+    CAST(COALESCE((
+        SELECT json_group_array(json_object('emoji', r.emoji, 'user_ids', json(r.user_ids)))
+        FROM (
+            SELECT emoji, json_group_array(user_id) AS user_ids
+            FROM message_reactions
+            WHERE message_id = m.id
+            GROUP BY emoji
+            ORDER BY MIN(rowid)
+        ) r
+    ), '[]') AS TEXT) AS reactions
 FROM messages m
 JOIN users u ON m.sender_id = u.id
-WHERE (m.sender_id = ? AND m.recipient_id = ?)
-   OR (m.sender_id = ? AND m.recipient_id = ?)
-ORDER BY m.created_at DESC
-LIMIT ?
-OFFSET ?;
+LEFT JOIN messages p ON p.id = m.reply_to_id
+LEFT JOIN users pu ON pu.id = p.sender_id
+LEFT JOIN attachments a ON a.message_id = m.id
+LEFT JOIN link_previews lp ON lp.url = m.preview_url AND lp.title <> ''
+LEFT JOIN message_pins pin ON pin.message_id = m.id
+LEFT JOIN message_stars st ON st.message_id = m.id AND st.user_id = sqlc.arg(user_id)
+WHERE ((m.sender_id = sqlc.arg(user_id) AND m.recipient_id = sqlc.arg(other_user_id))
+    OR (m.sender_id = sqlc.arg(other_user_id) AND m.recipient_id = sqlc.arg(user_id)))
+  AND m.id < sqlc.arg(before_id)
+  AND m.id > sqlc.arg(after_id)
+ORDER BY m.id DESC
+LIMIT sqlc.arg(limit);
 
--- name: GetRecentMessagePerUser :many
+-- name: GetSentMessage :one
+-- Finds the message a sender stored with a client-generated ID, for replying
+-- to a retried send the same way as the original.
 SELECT
-    m.*,
-    u.display_name AS other_user_display_name
+    m.id,
+    m.sender_id,
+    m.recipient_id,
+    m.conversation_id,
+    m.content,
+    m.created_at,
+    m.edited_at,
+    m.deleted_at,
+    m.reply_to_id,
+    m.expires_at,
+    p.content AS reply_content,
+    p.deleted_at AS reply_deleted_at,
+    pu.display_name AS reply_sender_display_name,
+    a.id AS attachment_id,
+    a.filename AS attachment_filename,
+    a.content_type AS attachment_content_type,
+    a.size AS attachment_size,
+    a.width AS attachment_width,
+    a.height AS attachment_height,
+    a.thumbnail_hash AS attachment_thumbnail_hash,
+    lp.url AS preview_url,
+    lp.title AS preview_title,
+    lp.description AS preview_description,
+    lp.image_url AS preview_image_url,
+    lp.site_name AS preview_site_name
 FROM messages m
-JOIN users u ON u.id = CASE
-    WHEN m.sender_id = ? THEN m.recipient_id
-    ELSE m.sender_id
-END
-WHERE m.sender_id = ? OR m.recipient_id = ?
-ORDER BY m.created_at DESC;
-
--- name: DeleteOldMessages :execresult
+LEFT JOIN messages p ON p.id = m.reply_to_id
+LEFT JOIN users pu ON pu.id = p.sender_id
+LEFT JOIN attachments a ON a.message_id = m.id
+LEFT JOIN link_previews lp ON lp.url = m.preview_url AND lp.title <> ''
+WHERE m.sender_id = ? AND m.client_id = ?;
+
+-- name: DeleteMessage :exec
+DELETE FROM messages
+WHERE id = ?;
+
+-- name: GetMessage :one
+SELECT * FROM messages WHERE id = ?;
+
+-- name: UpdateMessageContent :one
+-- Clears the link preview, which is fetched again for the new text. Only
+-- matches while the message still has previous_content and isn't deleted,
+-- so an edit based on a stale read changes nothing.
+UPDATE messages
+SET content = sqlc.arg(content), edited_at = datetime('now'), preview_url = NULL
+WHERE id = sqlc.arg(id)
+  AND content = sqlc.arg(previous_content)
+  AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateMessageRevision :exec
+INSERT INTO message_revisions (message_id, content)
+VALUES (?, ?);
+
+-- name: ListMessageRevisions :many
+SELECT content, replaced_at
+FROM message_revisions
+WHERE message_id = ?
+ORDER BY id;
+
+-- name: DeleteMessageContent :one
+-- Leaves a tombstone: the row keeps its place but loses its text.
+UPDATE messages
+SET content = '', edited_at = NULL, deleted_at = datetime('now'), preview_url = NULL
+WHERE id = ?
+RETURNING *;
+
+-- name: DeleteMessageRevisions :exec
+DELETE FROM message_revisions
+WHERE message_id = ?;
+
+-- name: DeleteOldMessages :many
+-- Deletes messages older than their conversation's retention period, or
+-- default_days for conversations without one. A period of zero days keeps
+-- messages forever, as do the keep flags on messages and conversations.
+-- Pinned messages are kept until they are unpinned.
+DELETE FROM messages
+WHERE id IN (
+    SELECT m.id FROM messages m
+    LEFT JOIN conversation_settings s
+        ON s.conversation_id = m.conversation_id
+        OR (s.conversation_id IS NULL
+            AND s.user_id = min(m.sender_id, m.recipient_id)
+            AND s.other_user_id = max(m.sender_id, m.recipient_id))
+    WHERE m.keep = 0
+      AND COALESCE(s.keep, 0) = 0
+      AND COALESCE(s.retention_days, CAST(sqlc.arg(default_days) AS INTEGER)) > 0
+      AND m.created_at < datetime('now', printf('-%d days', COALESCE(s.retention_days, CAST(sqlc.arg(default_days) AS INTEGER))))
+      AND NOT EXISTS (SELECT 1 FROM message_pins pin WHERE pin.message_id = m.id)
+)
+RETURNING id, sender_id, recipient_id, conversation_id;
+
+-- name: DeleteExpiredMessages :many
+-- Deletes messages whose disappearing-message timer has run out, unless they
+-- or their conversation are on hold.
 DELETE FROM messages
-WHERE created_at < datetime('now', '-30 days');
\ No newline at end of file
+WHERE expires_at <= datetime('now')
+  AND keep = 0
+  AND NOT EXISTS (
+      SELECT 1 FROM conversation_settings s
+      WHERE s.keep = 1
+        AND (s.conversation_id = messages.conversation_id
+             OR (s.conversation_id IS NULL
+                 AND s.user_id = min(messages.sender_id, messages.recipient_id)
+                 AND s.other_user_id = max(messages.sender_id, messages.recipient_id)))
+  )
+RETURNING id, sender_id, recipient_id, conversation_id;
+
+-- name: SetMessageKeep :execresult
+UPDATE messages
+SET keep = ?
+WHERE id = ?;
+
+-- name: SetMessagePreview :execresult
+-- Links a message to its fetched preview, unless it was deleted or edited
+-- meanwhile: content must still be the text the link was found in.
+UPDATE messages
+SET preview_url = sqlc.arg(preview_url)
+WHERE id = sqlc.arg(id)
+  AND deleted_at IS NULL
+  AND content = sqlc.arg(content);
\ No newline at end of file
@@ -0,0 +1,60 @@
+-- name: PinMessage :execresult
+-- Pinning a message again keeps who pinned it first.
+INSERT INTO message_pins (message_id, pinned_by)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinMessage :execresult
+DELETE FROM message_pins
+WHERE message_id = ?;
+
+-- name: GetMessagePin :one
+SELECT
+    pin.message_id,
+    pin.pinned_by,
+    pin.pinned_at,
+    u.display_name AS pinned_by_display_name
+FROM message_pins pin
+LEFT JOIN users u ON u.id = pin.pinned_by
+WHERE pin.message_id = ?;
+
+-- name: ListPinnedMessages :many
+-- Pinned messages between two users, most recently pinned first.
+SELECT
+    m.id,
+    m.sender_id,
+    m.content,
+    m.created_at,
+    u.display_name AS sender_display_name,
+    pin.pinned_by,
+    pin.pinned_at,
+    pu.display_name AS pinned_by_display_name
+FROM message_pins pin
+JOIN messages m ON m.id = pin.message_id
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN users pu ON pu.id = pin.pinned_by
+WHERE ((m.sender_id = sqlc.arg(user_id) AND m.recipient_id = sqlc.arg(other_user_id))
+    OR (m.sender_id = sqlc.arg(other_user_id) AND m.recipient_id = sqlc.arg(user_id)))
+ORDER BY pin.pinned_at DESC, m.id DESC;
+
+-- name: ListGroupPinnedMessages :many
+-- Pinned messages in a group, most recently pinned first. Messages from
+-- before the user joined are left out.
+SELECT
+    m.id,
+    m.sender_id,
+    m.content,
+    m.created_at,
+    u.display_name AS sender_display_name,
+    pin.pinned_by,
+    pin.pinned_at,
+    pu.display_name AS pinned_by_display_name
+FROM message_pins pin
+JOIN messages m ON m.id = pin.message_id
+JOIN conversation_members cm ON cm.conversation_id = m.conversation_id
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN users pu ON pu.id = pin.pinned_by
+WHERE cm.conversation_id = sqlc.arg(conversation_id)
+  AND cm.user_id = sqlc.arg(user_id)
+  AND m.id > cm.joined_after_message_id
+ORDER BY pin.pinned_at DESC, m.id DESC;
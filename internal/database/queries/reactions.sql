@@ -0,0 +1,25 @@
+-- name: AddReaction :exec
+INSERT INTO message_reactions (message_id, user_id, emoji)
+VALUES (?, ?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveReaction :execresult
+DELETE FROM message_reactions
+WHERE message_id = ? AND user_id = ? AND emoji = ?;
+
+-- name: DeleteMessageReactions :exec
+DELETE FROM message_reactions
+WHERE message_id = ?;
+
+-- name: GetMessageReactions :one
+-- Same JSON shape as the reactions column of GetConversationMessages.
+SELECT CAST(COALESCE((
+    SELECT json_group_array(json_object('emoji', r.emoji, 'user_ids', json(r.user_ids)))
+    FROM (
+        SELECT emoji, json_group_array(user_id) AS user_ids
+        FROM message_reactions
+        WHERE message_id = ?
+        GROUP BY emoji
+        ORDER BY MIN(rowid)
+    ) r
+), '[]') AS TEXT) AS reactions;
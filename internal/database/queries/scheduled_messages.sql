@@ -0,0 +1,56 @@
+-- name: CreateScheduledMessage :one
+INSERT INTO scheduled_messages (sender_id, recipient_id, conversation_id, content, send_at)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListScheduledMessages :many
+-- The sender's messages that aren't being sent, soonest first. Includes
+-- messages that failed to send.
+SELECT * FROM scheduled_messages
+WHERE sender_id = ? AND claimed_at IS NULL
+ORDER BY send_at, id;
+
+-- name: GetScheduledMessage :one
+SELECT * FROM scheduled_messages
+WHERE id = ? AND sender_id = ? AND claimed_at IS NULL;
+
+-- name: UpdateScheduledMessage :one
+-- Also clears a failure, so the scheduler tries the message again.
+UPDATE scheduled_messages
+SET content = ?, send_at = ?, failed_at = NULL, failure = NULL
+WHERE id = ? AND sender_id = ? AND claimed_at IS NULL
+RETURNING *;
+
+-- name: DeleteScheduledMessage :execresult
+DELETE FROM scheduled_messages
+WHERE id = ? AND sender_id = ? AND claimed_at IS NULL;
+
+-- name: ClaimDueScheduledMessages :many
+-- Marks and returns the messages that are due, so that each is claimed by
+-- exactly one process even when several share the database. A claim older
+-- than five minutes is taken to belong to a process that stopped mid-send.
+UPDATE scheduled_messages
+SET claimed_at = datetime('now')
+WHERE send_at <= datetime('now')
+  AND failed_at IS NULL
+  AND (claimed_at IS NULL OR claimed_at <= datetime('now', '-5 minutes'))
+RETURNING *;
+
+-- name: CompleteScheduledMessage :exec
+-- Removes a claimed message once it has been sent.
+DELETE FROM scheduled_messages
+WHERE id = ?;
+
+-- name: ReleaseScheduledMessage :exec
+-- Drops the claim on a message that couldn't be sent for now, so it is
+-- tried again.
+UPDATE scheduled_messages
+SET claimed_at = NULL
+WHERE id = ?;
+
+-- name: FailScheduledMessage :exec
+-- Keeps a claimed message that can never be sent, with the reason, for its
+-- sender to see.
+UPDATE scheduled_messages
+SET claimed_at = NULL, failed_at = datetime('now'), failure = ?
+WHERE id = ?;
@@ -0,0 +1,41 @@
+-- name: SearchMessages :many
+-- Matches in conversations the user takes part in, newest first. Group
+-- messages sent before the user joined and deleted messages are left out.
+-- Matched terms in snippet are wrapped in the control characters 0x02 and 0x03.
+SELECT
+    m.id,
+    m.sender_id,
+    m.recipient_id,
+    m.conversation_id,
+    m.created_at,
+    u.display_name AS sender_display_name,
+    c.name AS group_name,
+    ou.display_name AS other_user_display_name,
+    CAST(snippet(messages_fts, 0, char(2), char(3), '...', 16) AS TEXT) AS snippet
+FROM messages_fts
+JOIN messages m ON m.id = messages_fts.rowid
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.user_id = sqlc.arg(user_id)
+LEFT JOIN conversations c ON c.id = m.conversation_id
+LEFT JOIN users ou ON ou.id = CASE WHEN m.sender_id = sqlc.arg(user_id) THEN m.recipient_id ELSE m.sender_id END
+WHERE messages_fts.content MATCH sqlc.arg(query)
+  AND m.deleted_at IS NULL
+  AND (
+    (m.conversation_id IS NULL AND (m.sender_id = sqlc.arg(user_id) OR m.recipient_id = sqlc.arg(user_id)))
+    OR (cm.user_id IS NOT NULL AND m.id > cm.joined_after_message_id)
+  )
+ORDER BY m.id DESC
+LIMIT sqlc.arg(limit);
+
+-- name: CountConversationMessagesSince :one
+-- How many of the newest messages between two users reach back to message_id.
+SELECT COUNT(*) FROM messages
+WHERE ((sender_id = sqlc.arg(user_id) AND recipient_id = sqlc.arg(other_user_id))
+    OR (sender_id = sqlc.arg(other_user_id) AND recipient_id = sqlc.arg(user_id)))
+  AND id >= sqlc.arg(message_id);
+
+-- name: CountGroupMessagesSince :one
+-- How many of the newest messages in a group reach back to message_id.
+SELECT COUNT(*) FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+  AND id >= sqlc.arg(message_id);
@@ -0,0 +1,37 @@
+-- name: StarMessage :exec
+INSERT INTO message_stars (user_id, message_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnstarMessage :exec
+DELETE FROM message_stars
+WHERE user_id = ? AND message_id = ?;
+
+-- name: ListStarredMessages :many
+-- Messages the user starred that they can still see, most recently starred
+-- first. Deleted messages and group messages from before the user joined are
+-- left out.
+SELECT
+    m.id,
+    m.sender_id,
+    m.recipient_id,
+    m.conversation_id,
+    m.content,
+    m.created_at,
+    u.display_name AS sender_display_name,
+    c.name AS group_name,
+    ou.display_name AS other_user_display_name,
+    s.created_at AS starred_at
+FROM message_stars s
+JOIN messages m ON m.id = s.message_id
+JOIN users u ON u.id = m.sender_id
+LEFT JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.user_id = s.user_id
+LEFT JOIN conversations c ON c.id = m.conversation_id
+LEFT JOIN users ou ON ou.id = CASE WHEN m.sender_id = s.user_id THEN m.recipient_id ELSE m.sender_id END
+WHERE s.user_id = ?
+  AND m.deleted_at IS NULL
+  AND (
+    (m.conversation_id IS NULL AND (m.sender_id = s.user_id OR m.recipient_id = s.user_id))
+    OR (cm.user_id IS NOT NULL AND m.id > cm.joined_after_message_id)
+  )
+ORDER BY s.created_at DESC, m.id DESC;
@@ -0,0 +1,40 @@
+-- name: CreateUserEvent :one
+INSERT INTO user_events (user_id, seq, type, payload)
+VALUES (
+    ?,
+    (SELECT COALESCE(MAX(e.seq), 0) + 1 FROM user_events e WHERE e.user_id = ?),
+    ?,
+    ?
+)
+RETURNING *;
+
+-- name: ListUserEventsAfter :many
+SELECT * FROM user_events
+WHERE user_id = ? AND seq > ?
+ORDER BY seq
+LIMIT ?;
+
+-- name: GetLatestUserEventSeq :one
+SELECT CAST(COALESCE(MAX(seq), 0) AS INTEGER) AS seq
+FROM user_events
+WHERE user_id = ?;
+
+-- name: DeleteOldUserEvents :execresult
+-- Keeps each user's newest event so sequence numbers never restart.
+DELETE FROM user_events
+WHERE created_at < datetime('now', '-7 days')
+  AND seq < (SELECT MAX(e.seq) FROM user_events e WHERE e.user_id = user_events.user_id);
+
+-- name: ScrubMessageEvents :exec
+-- Replaces a deleted message's text in stored events so replays show the tombstone.
+UPDATE user_events
+SET payload = json_remove(json_set(payload, '$.content', '', '$.deleted', json('true')), '$.content_html', '$.edited_at', '$.preview')
+WHERE type IN ('message', 'edited', 'message_updated', 'pinned')
+  AND json_extract(payload, '$.id') = CAST(sqlc.arg(message_id) AS INTEGER);
+
+-- name: ScrubReplyEvents :exec
+-- Removes a deleted message's text from stored events for replies that quote it.
+UPDATE user_events
+SET payload = json_remove(json_set(payload, '$.reply_to.deleted', json('true')), '$.reply_to.snippet')
+WHERE type IN ('message', 'edited')
+  AND json_extract(payload, '$.reply_to.id') = CAST(sqlc.arg(message_id) AS INTEGER);
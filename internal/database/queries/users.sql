@@ -37,4 +37,10 @@ VALUES (?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateUserEmail :exec
-UPDATE users SET email = ? WHERE id = ?;
\ No newline at end of file
+UPDATE users SET email = ? WHERE id = ?;
+
+-- name: UpdateUserLastSeen :exec
+UPDATE users SET last_seen_at = datetime('now') WHERE id = ?;
+
+-- name: UpdateUserReadReceipts :exec
+UPDATE users SET read_receipts = ? WHERE id = ?;
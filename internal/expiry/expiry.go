@@ -0,0 +1,78 @@
+package expiry
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// ExpiredFunc is called with messages deleted because their disappearing-message
+// timer ran out, to scrub other copies of them and tell clients they are gone.
+type ExpiredFunc func(ctx context.Context, expired []store.DeleteExpiredMessagesRow)
+
+// Worker deletes disappearing messages once their timer runs out. It checks
+// far more often than the hourly cleanup so messages vanish on time.
+type Worker struct {
+	queries  *store.Queries
+	expired  ExpiredFunc
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// New creates a new Worker that checks for expired messages every interval.
+func New(queries *store.Queries, expired ExpiredFunc, interval time.Duration) *Worker {
+	return &Worker{
+		queries:  queries,
+		expired:  expired,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins the expiry loop in a goroutine.
+func (w *Worker) Start() {
+	go w.run()
+}
+
+// Stop signals the expiry loop to stop.
+func (w *Worker) Stop() {
+	close(w.stop)
+}
+
+func (w *Worker) run() {
+	slog.Info("expiry service started", "type", "lifecycle", "interval", w.interval.String())
+
+	// Delete anything that expired while the server was down
+	w.deleteExpired()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.deleteExpired()
+		case <-w.stop:
+			slog.Info("expiry service stopped", "type", "lifecycle")
+			return
+		}
+	}
+}
+
+// deleteExpired removes every message whose timer has run out.
+func (w *Worker) deleteExpired() {
+	ctx := context.Background()
+
+	expired, err := w.queries.DeleteExpiredMessages(ctx)
+	if err != nil {
+		slog.Error("failed to delete expired messages", "type", "expiry", "error", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+	slog.Info("deleted expired messages", "type", "expiry", "count", len(expired))
+	w.expired(ctx, expired)
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dukerupert/wantok/internal/attachments"
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+// MaxAttachmentSize is the largest file, in bytes, that can be attached to a message.
+var MaxAttachmentSize int64 = 10 << 20
+
+// attachmentTypes lists the non-image types that can be attached, as detected
+// from the file's content. Office documents are detected as application/zip.
+// Images are limited to the types attachments.ProcessImage can clean.
+var attachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
+// attachmentPreview stands in for a message's text when it only has a file.
+const attachmentPreview = "Attachment"
+
+var (
+	errAttachmentTooLarge = errors.New("attachment is too large")
+	errAttachmentEmpty    = errors.New("attachment is empty")
+	errAttachmentType     = errors.New("this type of file can't be attached")
+	errBadForm            = errors.New("bad request")
+)
+
+// AttachmentItem describes a file attached to a message.
+type AttachmentItem struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int64  `json:"width,omitempty"`  // Set for images
+	Height       int64  `json:"height,omitempty"` // Set for images
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"` // Set for images
+}
+
+// upload is a stored file waiting to be attached to a new message.
+type upload struct {
+	Hash          string
+	ThumbnailHash string
+	Name          string
+	ContentType   string
+	Size          int64
+	Width         int
+	Height        int
+}
+
+// HandleGetAttachment downloads a file attached to a message the current user can see.
+// Route: GET /attachments/{attachmentID}
+func HandleGetAttachment(queries *store.Queries, files *attachments.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveAttachment(w, r, queries, files, false)
+	}
+}
+
+// HandleGetAttachmentThumbnail downloads the thumbnail of an attached image.
+// Route: GET /attachments/{attachmentID}/thumbnail
+func HandleGetAttachmentThumbnail(queries *store.Queries, files *attachments.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveAttachment(w, r, queries, files, true)
+	}
+}
+
+// serveAttachment writes an attachment, or its thumbnail, if the current user
+// can see its message. Anything else is reported as not found.
+func serveAttachment(w http.ResponseWriter, r *http.Request, queries *store.Queries, files *attachments.Store, thumbnail bool) {
+	ctx := r.Context()
+	user := auth.GetUser(ctx)
+
+	attachmentID, err := strconv.ParseInt(r.PathValue("attachmentID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	att, err := queries.GetAttachment(ctx, attachmentID)
+	if err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	msg, err := getVisibleMessage(ctx, queries, user.ID, att.MessageID)
+	if err != nil || msg.DeletedAt.Valid {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	hash, contentType := att.Hash, att.ContentType
+	if thumbnail {
+		if !att.ThumbnailHash.Valid {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		hash, contentType = att.ThumbnailHash.String, attachments.ThumbnailType(att.ContentType)
+	}
+
+	f, err := files.Open(hash)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to open attachment", "type", "request", "attachment_id", att.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	if strings.HasPrefix(contentType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+	disposition := "attachment"
+	if attachments.IsImage(att.ContentType) {
+		disposition = "inline"
+	}
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": att.Filename}); value != "" {
+		disposition = value
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Revalidate every time so unsending a message also stops cached copies being served
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", `"`+hash+`"`)
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// parseSendForm parses the form of a send request. Requests carrying a file
+// are multipart; the "file" part is stored and returned. Returns a nil upload
+// when there is no file.
+func parseSendForm(w http.ResponseWriter, r *http.Request, files *attachments.Store) (*upload, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		if err := r.ParseForm(); err != nil {
+			return nil, errBadForm
+		}
+		return nil, nil
+	}
+
+	// Leave room for the other fields and the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, MaxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, errAttachmentTooLarge
+		}
+		return nil, errBadForm
+	}
+
+	file, header, err := r.FormFile("file")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errBadForm
+	}
+	defer file.Close()
+
+	if header.Size > MaxAttachmentSize {
+		return nil, errAttachmentTooLarge
+	}
+	if header.Size == 0 {
+		return nil, errAttachmentEmpty
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return storeUpload(files, data, header.Filename)
+}
+
+// storeUpload checks an uploaded file's type from its content and stores it.
+// Images are stored re-encoded, without their metadata, along with a thumbnail.
+func storeUpload(files *attachments.Store, data []byte, filename string) (*upload, error) {
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	file := &upload{
+		Name:        cleanFilename(filename),
+		ContentType: contentType,
+	}
+
+	if attachments.IsImage(contentType) {
+		img, err := attachments.ProcessImage(data, contentType)
+		if err != nil {
+			if !errors.Is(err, attachments.ErrImageInvalid) && !errors.Is(err, attachments.ErrImageTooLarge) && !errors.Is(err, attachments.ErrTooManyFrames) {
+				slog.Error("failed to process image", "type", "request", "error", err)
+			}
+			return nil, err
+		}
+		data = img.Data
+		file.Width, file.Height = img.Width, img.Height
+
+		file.ThumbnailHash, err = files.Put(img.Thumbnail)
+		if err != nil {
+			slog.Error("failed to store thumbnail", "type", "request", "error", err)
+			return nil, err
+		}
+	} else if !attachmentTypes[contentType] {
+		return nil, errAttachmentType
+	}
+
+	hash, err := files.Put(data)
+	if err != nil {
+		slog.Error("failed to store attachment", "type", "request", "error", err)
+		return nil, err
+	}
+	file.Hash = hash
+	file.Size = int64(len(data))
+	return file, nil
+}
+
+// writeUploadError maps an error from parseSendForm to a response.
+func writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBadForm):
+		http.Error(w, "Bad request", http.StatusBadRequest)
+	case errors.Is(err, errAttachmentTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errAttachmentType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, errAttachmentEmpty), errors.Is(err, attachments.ErrImageInvalid), errors.Is(err, attachments.ErrImageTooLarge),
+		errors.Is(err, attachments.ErrTooManyFrames):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+	}
+}
+
+// attachFile records an upload as the attachment of a newly created message.
+// If that fails the message is removed, so it isn't delivered without its file.
+func attachFile(ctx context.Context, queries *store.Queries, messageID int64, file *upload) (*AttachmentItem, error) {
+	att, err := queries.CreateAttachment(ctx, store.CreateAttachmentParams{
+		MessageID:     messageID,
+		Hash:          file.Hash,
+		ThumbnailHash: sql.NullString{String: file.ThumbnailHash, Valid: file.ThumbnailHash != ""},
+		Filename:      file.Name,
+		ContentType:   file.ContentType,
+		Size:          file.Size,
+		Width:         sql.NullInt64{Int64: int64(file.Width), Valid: file.Width > 0},
+		Height:        sql.NullInt64{Int64: int64(file.Height), Valid: file.Height > 0},
+	})
+	if err != nil {
+		slog.Error("failed to create attachment", "type", "request", "message_id", messageID, "error", err)
+		if err := queries.DeleteMessage(ctx, messageID); err != nil {
+			slog.Error("failed to remove message without attachment", "type", "request", "message_id", messageID, "error", err)
+		}
+		return nil, err
+	}
+
+	return newAttachmentItem(
+		sql.NullInt64{Int64: att.ID, Valid: true},
+		sql.NullString{String: att.Filename, Valid: true},
+		sql.NullString{String: att.ContentType, Valid: true},
+		sql.NullInt64{Int64: att.Size, Valid: true},
+		att.Width,
+		att.Height,
+		att.ThumbnailHash,
+	), nil
+}
+
+// newAttachmentItem builds an attachment from the attachment columns of the
+// message queries. Returns nil if the message has no attachment.
+func newAttachmentItem(id sql.NullInt64, filename, contentType sql.NullString, size, width, height sql.NullInt64, thumbnailHash sql.NullString) *AttachmentItem {
+	if !id.Valid {
+		return nil
+	}
+
+	url := "/attachments/" + strconv.FormatInt(id.Int64, 10)
+	item := &AttachmentItem{
+		ID:          id.Int64,
+		Name:        filename.String,
+		ContentType: contentType.String,
+		Size:        size.Int64,
+		Width:       width.Int64,
+		Height:      height.Int64,
+		URL:         url,
+	}
+	if thumbnailHash.Valid {
+		item.ThumbnailURL = url + "/thumbnail"
+	}
+	return item
+}
+
+// props converts the attachment for rendering with partials.Message.
+func (a *AttachmentItem) props() *partials.AttachmentProps {
+	if a == nil {
+		return nil
+	}
+	return &partials.AttachmentProps{
+		Name:         a.Name,
+		Size:         formatSize(a.Size),
+		URL:          a.URL,
+		ThumbnailURL: a.ThumbnailURL,
+	}
+}
+
+// formatSize describes a file size for display, e.g. "1.2 MB".
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%d KB", size>>10)
+	default:
+		return fmt.Sprintf("%d bytes", size)
+	}
+}
+
+// cleanFilename keeps the last element of an uploaded file's name, without
+// control characters, so it is safe to show and to send back in headers.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if utf8.RuneCountInString(name) > 255 {
+		name = string([]rune(name)[:255])
+	}
+	return name
+}
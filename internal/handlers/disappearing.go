@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/expiry"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// Limits on a conversation's disappearing-message timer, in seconds.
+const (
+	minDisappearSeconds = 30
+	maxDisappearSeconds = 7 * 24 * 60 * 60
+)
+
+var errTimerInvalid = errors.New("seconds must be a whole number from 30 to 604800, or blank to turn the timer off")
+
+// TimerItem is a conversation's disappearing-message timer, for JSON API
+// responses and the payload of "timer" messages. At most one of UserID and
+// ConversationID is set in events.
+type TimerItem struct {
+	UserID         int64  `json:"user_id,omitempty"` // Other participant of a direct conversation
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Seconds        int64  `json:"seconds"`               // Zero when messages don't disappear
+	SetBy          int64  `json:"set_by,omitempty"`      // User who changed the timer; only in events
+	SetByName      string `json:"set_by_name,omitempty"` // Their display name
+}
+
+// HandleGetTimer returns the disappearing-message timer of the conversation with another user.
+// Route: GET /conversations/{userID}/timer
+func HandleGetTimer(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil || otherUserID == user.ID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, otherUserID); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		writeTimer(w, TimerItem{UserID: otherUserID, Seconds: getDirectTimer(ctx, queries, user.ID, otherUserID)})
+	}
+}
+
+// HandleSetTimer sets how long after sending new messages to another user disappear.
+// Both participants are told through a "timer" event.
+// Route: POST /conversations/{userID}/timer
+// Form field: seconds; blank or 0 turns the timer off.
+func HandleSetTimer(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil || otherUserID == user.ID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, otherUserID); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		seconds, err := parseTimer(r.FormValue("seconds"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		low, high := directPair(user.ID, otherUserID)
+		err = queries.SetDirectTimer(ctx, store.SetDirectTimerParams{
+			UserID:           low,
+			OtherUserID:      high,
+			DisappearSeconds: seconds,
+		})
+		if err != nil {
+			slog.Error("failed to set timer", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("timer set", "type", "request", "user_id", user.ID, "other_user_id", otherUserID, "seconds", seconds.Int64)
+
+		item := TimerItem{Seconds: seconds.Int64, SetBy: user.ID, SetByName: user.DisplayName}
+		forOther := item
+		forOther.UserID = user.ID
+		hub.Publish(ctx, otherUserID, &realtime.Message{Type: "timer", Payload: forOther})
+		item.UserID = otherUserID
+		hub.Publish(ctx, user.ID, &realtime.Message{Type: "timer", Payload: item})
+
+		writeTimer(w, item)
+	}
+}
+
+// HandleGetGroupTimer returns a group's disappearing-message timer.
+// Route: GET /groups/{groupID}/timer
+func HandleGetGroupTimer(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		writeTimer(w, TimerItem{ConversationID: groupID, Seconds: getGroupTimer(ctx, queries, groupID)})
+	}
+}
+
+// HandleSetGroupTimer sets how long after sending new group messages disappear.
+// Every member is told through a "timer" event.
+// Route: POST /groups/{groupID}/timer
+// Form field: seconds; blank or 0 turns the timer off.
+func HandleSetGroupTimer(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		seconds, err := parseTimer(r.FormValue("seconds"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = queries.SetGroupTimer(ctx, store.SetGroupTimerParams{
+			ConversationID:   sql.NullInt64{Int64: groupID, Valid: true},
+			DisappearSeconds: seconds,
+		})
+		if err != nil {
+			slog.Error("failed to set group timer", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("group timer set", "type", "request", "user_id", user.ID, "group_id", groupID, "seconds", seconds.Int64)
+
+		item := TimerItem{ConversationID: groupID, Seconds: seconds.Int64, SetBy: user.ID, SetByName: user.DisplayName}
+		memberIDs, err := queries.ListConversationMemberIDs(ctx, groupID)
+		if err != nil {
+			slog.Error("failed to list group members", "type", "request", "group_id", groupID, "error", err)
+		}
+		for _, memberID := range memberIDs {
+			hub.Publish(ctx, memberID, &realtime.Message{Type: "timer", Payload: item})
+		}
+
+		writeTimer(w, item)
+	}
+}
+
+// NotifyDisappeared returns the expiry worker's ExpiredFunc.
+func NotifyDisappeared(queries *store.Queries, hub *realtime.Hub) expiry.ExpiredFunc {
+	return func(ctx context.Context, expired []store.DeleteExpiredMessagesRow) {
+		msgs := make([]expiredMessage, len(expired))
+		for i, row := range expired {
+			msgs[i] = expiredMessage(row)
+		}
+		publishExpired(ctx, queries, hub, msgs)
+	}
+}
+
+// getDirectTimer returns the disappearing-message timer of a direct
+// conversation in seconds, or zero if it has none.
+func getDirectTimer(ctx context.Context, queries *store.Queries, userID, otherUserID int64) int64 {
+	low, high := directPair(userID, otherUserID)
+	settings, err := queries.GetDirectSettings(ctx, store.GetDirectSettingsParams{
+		UserID:      low,
+		OtherUserID: high,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to get conversation settings", "type", "request", "error", err)
+	}
+	return settings.DisappearSeconds.Int64
+}
+
+// getGroupTimer returns a group's disappearing-message timer in seconds, or zero if it has none.
+func getGroupTimer(ctx context.Context, queries *store.Queries, groupID int64) int64 {
+	settings, err := queries.GetGroupSettings(ctx, sql.NullInt64{Int64: groupID, Valid: true})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to get group settings", "type", "request", "error", err)
+	}
+	return settings.DisappearSeconds.Int64
+}
+
+// writeTimer writes a disappearing-message timer as JSON.
+func writeTimer(w http.ResponseWriter, item TimerItem) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		slog.Error("failed to encode timer", "type", "request", "error", err)
+	}
+}
+
+// parseTimer parses a seconds form value. Blank or zero turns the timer off.
+func parseTimer(value string) (sql.NullInt64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return sql.NullInt64{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < minDisappearSeconds || seconds > maxDisappearSeconds {
+		return sql.NullInt64{}, errTimerInvalid
+	}
+	return sql.NullInt64{Int64: seconds, Valid: true}, nil
+}
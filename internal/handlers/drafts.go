@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/validate"
+)
+
+// DraftItem is a user's unsent text for one conversation, for JSON API
+// responses and the payload of "draft" messages. Exactly one of RecipientID
+// and ConversationID is set. An empty Content means the draft was cleared.
+type DraftItem struct {
+	RecipientID    int64  `json:"recipient_id,omitempty"`
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Content        string `json:"content"`
+	UpdatedAt      string `json:"updated_at,omitempty"`
+}
+
+// HandleSaveDraft stores the current user's draft to another user and pushes
+// it to their other clients.
+// Route: POST /conversations/{userID}/draft
+// Form field: content; blank content clears the draft.
+func HandleSaveDraft(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		recipientID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if recipientID == user.ID {
+			http.Error(w, "Cannot message yourself", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, recipientID); err != nil {
+			http.Error(w, "Recipient not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		content := r.FormValue("content")
+		if strings.TrimSpace(content) == "" {
+			clearDraft(ctx, queries, hub, user, recipientID, 0)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := validate.Message(content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = queries.SaveDirectDraft(ctx, store.SaveDirectDraftParams{
+			UserID:      user.ID,
+			OtherUserID: sql.NullInt64{Int64: recipientID, Valid: true},
+			Content:     content,
+		})
+		if err != nil {
+			slog.Error("failed to save draft", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		hub.SendToUser(user.ID, &realtime.Message{
+			Type:    "draft",
+			Payload: DraftItem{RecipientID: recipientID, Content: content},
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleSaveGroupDraft stores the current user's draft to a group and pushes
+// it to their other clients.
+// Route: POST /groups/{groupID}/draft
+// Form field: content; blank content clears the draft.
+func HandleSaveGroupDraft(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		content := r.FormValue("content")
+		if strings.TrimSpace(content) == "" {
+			clearDraft(ctx, queries, hub, user, 0, groupID)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := validate.Message(content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = queries.SaveGroupDraft(ctx, store.SaveGroupDraftParams{
+			UserID:         user.ID,
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+			Content:        content,
+		})
+		if err != nil {
+			slog.Error("failed to save group draft", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		hub.SendToUser(user.ID, &realtime.Message{
+			Type:    "draft",
+			Payload: DraftItem{ConversationID: groupID, Content: content},
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleListDrafts returns the current user's drafts, most recently changed first.
+// Route: GET /drafts
+func HandleListDrafts(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		rows, err := queries.ListDrafts(ctx, user.ID)
+		if err != nil {
+			slog.Error("failed to list drafts", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		drafts := make([]DraftItem, len(rows))
+		for i, row := range rows {
+			drafts[i] = DraftItem{
+				RecipientID:    row.OtherUserID.Int64,
+				ConversationID: row.ConversationID.Int64,
+				Content:        row.Content,
+				UpdatedAt:      row.UpdatedAt,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(drafts); err != nil {
+			slog.Error("failed to encode drafts", "type", "request", "error", err)
+		}
+	}
+}
+
+// clearDraft deletes the user's draft to recipientID, or to groupID when it
+// is set, and tells their clients it is gone. Called once a message is sent.
+// Failures are logged rather than returned since the message itself was sent.
+func clearDraft(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, recipientID, groupID int64) {
+	var result sql.Result
+	var err error
+	if groupID > 0 {
+		result, err = queries.DeleteGroupDraft(ctx, store.DeleteGroupDraftParams{
+			UserID:         user.ID,
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+		})
+	} else {
+		result, err = queries.DeleteDirectDraft(ctx, store.DeleteDirectDraftParams{
+			UserID:      user.ID,
+			OtherUserID: sql.NullInt64{Int64: recipientID, Valid: true},
+		})
+	}
+	if err != nil {
+		slog.Error("failed to clear draft", "type", "request", "user_id", user.ID, "error", err)
+		return
+	}
+
+	// Nothing to tell other clients if there was no draft
+	if count, _ := result.RowsAffected(); count == 0 {
+		return
+	}
+	hub.SendToUser(user.ID, &realtime.Message{
+		Type:    "draft",
+		Payload: DraftItem{RecipientID: recipientID, ConversationID: groupID},
+	})
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/validate"
+)
+
+// EditWindow is how long after sending a message its sender may edit it.
+// Zero disables editing.
+var EditWindow = 15 * time.Minute
+
+// RevisionItem is an earlier version of an edited message.
+type RevisionItem struct {
+	Content    string `json:"content"`
+	ReplacedAt string `json:"replaced_at"` // When this version was edited away
+}
+
+var (
+	errMessageNotFound  = errors.New("message not found")
+	errEditNotAllowed   = errors.New("only the sender can edit a message")
+	errEditWindowClosed = errors.New("message can no longer be edited")
+	errMessageDeleted   = errors.New("message was deleted")
+	errEditConflict     = errors.New("message was changed by another edit, try again")
+)
+
+// HandleEditMessage replaces the content of one of the current user's messages.
+// Route: POST /messages/{messageID}
+// Form field: content.
+func HandleEditMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		response, err := editMessage(ctx, queries, hub, user, messageID, r.FormValue("content"))
+		if err != nil {
+			switch {
+			case errors.Is(err, errMessageNotFound):
+				http.Error(w, "Message not found", http.StatusNotFound)
+			case errors.Is(err, errEditNotAllowed), errors.Is(err, errEditWindowClosed), errors.Is(err, errMessageDeleted):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, errEditConflict):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, validate.ErrMessageEmpty), errors.Is(err, validate.ErrMessageTooLong):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to edit message", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode message", "type", "request", "error", err)
+		}
+	}
+}
+
+// HandleGetMessageHistory returns the earlier versions of a message, oldest first.
+// Route: GET /messages/{messageID}/history
+func HandleGetMessageHistory(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getVisibleMessage(ctx, queries, user.ID, messageID); err != nil {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+
+		rows, err := queries.ListMessageRevisions(ctx, messageID)
+		if err != nil {
+			slog.Error("failed to list message revisions", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		revisions := make([]RevisionItem, len(rows))
+		for i, row := range rows {
+			revisions[i] = RevisionItem{
+				Content:    row.Content,
+				ReplacedAt: row.ReplacedAt,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(revisions); err != nil {
+			slog.Error("failed to encode message history", "type", "request", "error", err)
+		}
+	}
+}
+
+// editMessage validates and stores new content for a message sent by user,
+// keeping the old content as a revision, and sends an "edited" event to
+// everyone who can see the message. Returns the message as seen by the sender.
+func editMessage(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, messageID int64, content string) (MessageItem, error) {
+	content = strings.TrimSpace(content)
+	if err := validate.Message(content); err != nil {
+		return MessageItem{}, err
+	}
+
+	msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+	if err != nil {
+		return MessageItem{}, err
+	}
+	if msg.SenderID != user.ID {
+		return MessageItem{}, errEditNotAllowed
+	}
+	if msg.DeletedAt.Valid {
+		return MessageItem{}, errMessageDeleted
+	}
+	if !editable(msg.CreatedAt) {
+		return MessageItem{}, errEditWindowClosed
+	}
+
+	changed := content != msg.Content
+	if changed {
+		// The update goes first so the transaction holds the write lock for
+		// the revision. It matches nothing if another edit or a delete got in
+		// since msg was read, which would otherwise lose that edit's history.
+		previous := msg.Content
+		err = queries.InTx(ctx, func(tx *store.Queries) error {
+			var err error
+			msg, err = tx.UpdateMessageContent(ctx, store.UpdateMessageContentParams{
+				Content:         content,
+				ID:              msg.ID,
+				PreviousContent: previous,
+			})
+			if err != nil {
+				return err
+			}
+			return tx.CreateMessageRevision(ctx, store.CreateMessageRevisionParams{
+				MessageID: msg.ID,
+				Content:   previous,
+			})
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return MessageItem{}, errEditConflict
+		}
+		if err != nil {
+			slog.Error("failed to update message", "type", "request", "error", err)
+			return MessageItem{}, err
+		}
+
+		slog.Info("message edited", "type", "request", "user_id", user.ID, "message_id", msg.ID)
+	}
+
+	item := MessageItem{
+		ID:             msg.ID,
+		Content:        msg.Content,
+		ContentHTML:    markup.Render(msg.Content),
+		SenderID:       msg.SenderID,
+		SenderName:     user.DisplayName,
+		CreatedAt:      msg.CreatedAt,
+		EditedAt:       msg.EditedAt.String,
+		ConversationID: msg.ConversationID.Int64,
+		ExpiresAt:      msg.ExpiresAt.String,
+	}
+
+	viewers, err := messageViewerIDs(ctx, queries, msg)
+	if err != nil {
+		slog.Error("failed to list message viewers", "type", "request", "message_id", msg.ID, "error", err)
+		return MessageItem{}, err
+	}
+	for _, viewerID := range viewers {
+		viewerItem := item
+		viewerItem.IsSent = viewerID == user.ID
+		hub.Publish(ctx, viewerID, &realtime.Message{Type: "edited", Payload: viewerItem})
+	}
+	// The old preview was cleared along with the old content
+	if changed {
+		previewMessage(queries, hub, msg)
+	}
+
+	item.IsSent = true
+	return item, nil
+}
+
+// editable reports whether a message sent at createdAt is still within EditWindow.
+func editable(createdAt string) bool {
+	if EditWindow <= 0 {
+		return false
+	}
+	sent, err := time.Parse(timeFormat, createdAt)
+	if err != nil {
+		return false
+	}
+	return time.Since(sent) < EditWindow
+}
+
+// getVisibleMessage loads a message, returning errMessageNotFound unless userID
+// sent or received it, or was in its group when it was sent.
+func getVisibleMessage(ctx context.Context, queries *store.Queries, userID, messageID int64) (store.Message, error) {
+	msg, err := queries.GetMessage(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return store.Message{}, errMessageNotFound
+		}
+		slog.Error("failed to get message", "type", "request", "message_id", messageID, "error", err)
+		return store.Message{}, err
+	}
+
+	if !msg.ConversationID.Valid {
+		if msg.SenderID != userID && msg.RecipientID.Int64 != userID {
+			return store.Message{}, errMessageNotFound
+		}
+		return msg, nil
+	}
+
+	member, err := queries.GetConversationMember(ctx, store.GetConversationMemberParams{
+		ConversationID: msg.ConversationID.Int64,
+		UserID:         userID,
+	})
+	if err != nil || msg.ID <= member.JoinedAfterMessageID {
+		return store.Message{}, errMessageNotFound
+	}
+	return msg, nil
+}
+
+// messageViewerIDs returns the users who can see a message: both participants
+// of a direct message, or the group members who joined before it was sent.
+func messageViewerIDs(ctx context.Context, queries *store.Queries, msg store.Message) ([]int64, error) {
+	if !msg.ConversationID.Valid {
+		return []int64{msg.SenderID, msg.RecipientID.Int64}, nil
+	}
+	return queries.ListGroupMessageViewerIDs(ctx, store.ListGroupMessageViewerIDsParams{
+		ConversationID: msg.ConversationID.Int64,
+		MessageID:      msg.ID,
+	})
+}
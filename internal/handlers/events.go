@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/presence"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// HandleEvents streams realtime messages as Server-Sent Events.
+// Fallback for clients that cannot open a WebSocket; carries the same messages.
+// The first message is a "stream" message naming where to post inbound frames.
+// Route: GET /events
+func HandleEvents(hub *realtime.Hub, queries *store.Queries, tracker *presence.Tracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+		w.WriteHeader(http.StatusOK)
+
+		client := realtime.NewStreamClient(hub, user.ID, user.DisplayName, handleInbound(ctx, queries, hub, tracker, user))
+		hub.Register(client)
+		tracker.Connected(client)
+
+		client.StreamPump(w, ctx.Done()) // Blocks until disconnect
+		hub.Unregister(client)
+		tracker.Disconnected(client)
+	}
+}
+
+// HandleEventFrame accepts an inbound frame for an open event stream.
+// The body is a frame as sent over the WebSocket; replies arrive on the stream.
+// Route: POST /events/{streamID}
+func HandleEventFrame(hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		client := hub.StreamClient(user.ID, r.PathValue("streamID"))
+		if client == nil {
+			http.Error(w, "Stream not found", http.StatusNotFound)
+			return
+		}
+
+		if err := client.ReadFrame(r.Body); err != nil {
+			if errors.Is(err, realtime.ErrFrameTooLarge) {
+				http.Error(w, "Frame too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			slog.Error("failed to read frame", "type", "request", "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
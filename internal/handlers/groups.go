@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/attachments"
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/validate"
+	"github.com/dukerupert/wantok/internal/views/pages"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+// GroupPayload is the payload of a "group" event.
+// Sent to every member, and to a member who left, when a group's membership changes.
+type GroupPayload struct {
+	ConversationID int64   `json:"conversation_id"`
+	Name           string  `json:"name"`
+	MemberIDs      []int64 `json:"member_ids"`
+}
+
+var (
+	errGroupNotFound  = errors.New("group not found")
+	errMembersMissing = errors.New("choose at least one member")
+	errMemberNotFound = errors.New("member not found")
+)
+
+// HandleCreateGroup creates a group conversation with the current user as a member.
+// Route: POST /groups
+// Form fields: name, and member_id once per other member.
+func HandleCreateGroup(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		name := strings.TrimSpace(r.FormValue("name"))
+		if err := validate.GroupName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		memberIDs, err := parseMemberIDs(ctx, queries, user.ID, r.Form["member_id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		group, err := queries.CreateConversation(ctx, store.CreateConversationParams{
+			Name:      name,
+			CreatedBy: sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+		if err != nil {
+			slog.Error("failed to create group", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		for _, memberID := range append([]int64{user.ID}, memberIDs...) {
+			if err := queries.AddConversationMember(ctx, store.AddConversationMemberParams{
+				ConversationID: group.ID,
+				UserID:         memberID,
+			}); err != nil {
+				slog.Error("failed to add group member", "type", "request", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		slog.Info("group created", "type", "request", "group_id", group.ID, "user_id", user.ID, "members", len(memberIDs)+1)
+		publishGroup(ctx, queries, hub, group)
+
+		http.Redirect(w, r, fmt.Sprintf("/?group=%d", group.ID), http.StatusSeeOther)
+	}
+}
+
+// HandleAddGroupMembers adds users to a group the current user belongs to.
+// Route: POST /groups/{groupID}/members
+// Form fields: member_id once per new member.
+func HandleAddGroupMembers(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		group, err := getGroup(ctx, queries, user.ID, groupID)
+		if err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		memberIDs, err := parseMemberIDs(ctx, queries, user.ID, r.Form["member_id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, memberID := range memberIDs {
+			if err := queries.AddConversationMember(ctx, store.AddConversationMemberParams{
+				ConversationID: group.ID,
+				UserID:         memberID,
+			}); err != nil {
+				slog.Error("failed to add group member", "type", "request", "error", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		slog.Info("group members added", "type", "request", "group_id", group.ID, "user_id", user.ID, "count", len(memberIDs))
+		publishGroup(ctx, queries, hub, group)
+
+		http.Redirect(w, r, fmt.Sprintf("/?group=%d", group.ID), http.StatusSeeOther)
+	}
+}
+
+// HandleLeaveGroup removes the current user from a group.
+// The group is deleted when its last member leaves.
+// Route: POST /groups/{groupID}/leave
+func HandleLeaveGroup(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		group, err := getGroup(ctx, queries, user.ID, groupID)
+		if err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		// One transaction, so members leaving or joining at the same time
+		// can't both see the group empty or have it deleted under them
+		err = queries.InTx(ctx, func(tx *store.Queries) error {
+			if _, err := tx.RemoveConversationMember(ctx, store.RemoveConversationMemberParams{
+				ConversationID: group.ID,
+				UserID:         user.ID,
+			}); err != nil {
+				return err
+			}
+			remaining, err := tx.ListConversationMemberIDs(ctx, group.ID)
+			if err != nil || len(remaining) > 0 {
+				return err
+			}
+			return tx.DeleteConversation(ctx, group.ID)
+		})
+		if err != nil {
+			slog.Error("failed to leave group", "type", "request", "group_id", group.ID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("group left", "type", "request", "group_id", group.ID, "user_id", user.ID)
+		publishGroup(ctx, queries, hub, group, user.ID)
+
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// HandleGetGroupMessages returns a page of a group's messages, newest first,
+// rendered for HTMX requests. Members only see messages sent since they joined.
+// Route: GET /groups/{groupID}/messages?before_id=&after_id=&limit=
+func HandleGetGroupMessages(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		page, err := parseMessagePage(r)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+
+		msgs, err := queries.GetGroupMessages(ctx, store.GetGroupMessagesParams{
+			UserID:         user.ID,
+			ConversationID: groupID,
+			BeforeID:       page.BeforeID,
+			AfterID:        page.AfterID,
+			Limit:          page.Limit,
+		})
+		if err != nil {
+			slog.Error("failed to get group messages", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		messages := make([]MessageItem, len(msgs))
+		for i, m := range msgs {
+			messages[i] = MessageItem{
+				ID:             m.ID,
+				Content:        m.Content,
+				ContentHTML:    markup.Render(m.Content),
+				SenderID:       m.SenderID,
+				SenderName:     m.SenderDisplayName,
+				CreatedAt:      m.CreatedAt,
+				IsSent:         m.SenderID == user.ID,
+				ConversationID: groupID,
+				EditedAt:       m.EditedAt.String,
+				Deleted:        m.DeletedAt.Valid,
+				ReplyTo:        newReplyItem(m.ReplyToID, m.ReplyContent, m.ReplyDeletedAt, m.ReplySenderDisplayName),
+				Reactions:      parseReactions(m.Reactions, user.ID),
+				Attachment:     newAttachmentItem(m.AttachmentID, m.AttachmentFilename, m.AttachmentContentType, m.AttachmentSize, m.AttachmentWidth, m.AttachmentHeight, m.AttachmentThumbnailHash),
+				ExpiresAt:      m.ExpiresAt.String,
+				Preview:        newPreviewItem(m.PreviewUrl, m.PreviewTitle, m.PreviewDescription, m.PreviewImageUrl, m.PreviewSiteName),
+				PinnedAt:       m.PinnedAt.String,
+				Starred:        m.Starred != 0,
+			}
+		}
+
+		writeMessagePage(w, r, messages, page, fmt.Sprintf("/groups/%d/messages", groupID))
+	}
+}
+
+// HandleSendGroupMessage sends a message to every member of a group.
+// Route: POST /groups/{groupID}/messages
+// Form fields: content, reply_to_id, client_id and, in multipart requests, an optional file.
+// A retry with the same client_id, or Idempotency-Key header, returns the original message.
+func HandleSendGroupMessage(queries *store.Queries, hub *realtime.Hub, files *attachments.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		file, err := parseSendForm(w, r, files)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+
+		clientID := requestClientID(r)
+		if err := validateClientID(clientID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := sendGroupMessage(ctx, queries, hub, user, groupID, r.FormValue("content"), parseReplyToID(r.FormValue("reply_to_id")), clientID, file)
+		if err != nil {
+			switch {
+			case errors.Is(err, errGroupNotFound):
+				http.Error(w, "Group not found", http.StatusNotFound)
+			case isSendValidationError(err):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to send message", http.StatusInternalServerError)
+			}
+			return
+		}
+		clearDraft(ctx, queries, hub, user, 0, groupID)
+
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusCreated)
+			partials.Message(partials.MessageProps{
+				ID:         response.ID,
+				Content:    response.Content,
+				CreatedAt:  response.CreatedAt,
+				IsSent:     true,
+				Editable:   EditWindow > 0,
+				ReplyTo:    response.ReplyTo.props(),
+				Attachment: response.Attachment.props(),
+			}).Render(ctx, w)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode message", "type", "request", "error", err)
+		}
+	}
+}
+
+// HandleMarkGroupRead marks a group's messages as read for the current user.
+// Route: POST /groups/{groupID}/read
+// Optional form field message_id; defaults to the newest message.
+func HandleMarkGroupRead(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		var messageID int64
+		if v := r.FormValue("message_id"); v != "" {
+			messageID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid message ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := markGroupRead(ctx, queries, user, groupID, messageID); err != nil {
+			if errors.Is(err, errGroupNotFound) {
+				http.Error(w, "Group not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// loadActiveGroup fills in the chat page for a group the user belongs to.
+// Leaves data untouched if the user is not a member.
+func loadActiveGroup(ctx context.Context, queries *store.Queries, user *auth.User, groupID int64, data *pages.ChatPageData) {
+	group, err := getGroup(ctx, queries, user.ID, groupID)
+	if err != nil {
+		return
+	}
+
+	members, err := queries.ListConversationMembers(ctx, groupID)
+	if err != nil {
+		slog.Error("failed to list group members", "type", "request", "group_id", groupID, "error", err)
+		return
+	}
+
+	data.ActiveGroupID = group.ID
+	data.ActiveGroupName = group.Name
+	data.Draft, _ = queries.GetGroupDraft(ctx, store.GetGroupDraftParams{
+		UserID:         user.ID,
+		ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+	})
+	data.DisappearSeconds = getGroupTimer(ctx, queries, groupID)
+	if pins, err := listGroupPinned(ctx, queries, user.ID, groupID); err == nil {
+		data.Pinned = pinProps(pins)
+	}
+
+	isMember := make(map[int64]bool, len(members))
+	for _, m := range members {
+		isMember[m.UserID] = true
+		data.ActiveGroupMembers = append(data.ActiveGroupMembers, m.DisplayName)
+	}
+
+	// Everyone else can be added
+	if users, err := queries.ListUsersExcept(ctx, user.ID); err == nil {
+		for _, u := range users {
+			if !isMember[u.ID] {
+				data.GroupCandidates = append(data.GroupCandidates, partials.UserListItem{
+					ID:          u.ID,
+					DisplayName: u.DisplayName,
+				})
+			}
+		}
+	}
+
+	limit := pageSize(ctx, queries, user.ID, 0, groupID, data.FocusMessageID)
+	msgs, err := queries.GetGroupMessages(ctx, store.GetGroupMessagesParams{
+		UserID:         user.ID,
+		ConversationID: groupID,
+		BeforeID:       math.MaxInt64,
+		Limit:          limit,
+	})
+	if err != nil {
+		slog.Error("failed to get group messages", "type", "request", "error", err)
+		return
+	}
+	data.Messages = make([]pages.MessageItem, len(msgs))
+	for i, m := range msgs {
+		data.Messages[i] = pages.MessageItem{
+			ID:         m.ID,
+			Content:    m.Content,
+			SenderID:   m.SenderID,
+			SenderName: m.SenderDisplayName,
+			CreatedAt:  m.CreatedAt,
+			IsSent:     m.SenderID == user.ID,
+			EditedAt:   m.EditedAt.String,
+			Deleted:    m.DeletedAt.Valid,
+			Editable:   m.SenderID == user.ID && !m.DeletedAt.Valid && editable(m.CreatedAt),
+			ReplyTo:    newReplyItem(m.ReplyToID, m.ReplyContent, m.ReplyDeletedAt, m.ReplySenderDisplayName).props(),
+			Reactions:  reactionProps(parseReactions(m.Reactions, user.ID)),
+			Attachment: newAttachmentItem(m.AttachmentID, m.AttachmentFilename, m.AttachmentContentType, m.AttachmentSize, m.AttachmentWidth, m.AttachmentHeight, m.AttachmentThumbnailHash).props(),
+			Preview:    newPreviewItem(m.PreviewUrl, m.PreviewTitle, m.PreviewDescription, m.PreviewImageUrl, m.PreviewSiteName).props(),
+			Pinned:     m.PinnedAt.Valid,
+			Starred:    m.Starred != 0,
+		}
+	}
+	if len(msgs) > 0 {
+		data.OlderMessagesURL = olderMessagesURL(fmt.Sprintf("/groups/%d/messages", groupID), msgs[len(msgs)-1].ID, len(msgs), limit)
+	}
+}
+
+// sendGroupMessage validates, stores and broadcasts a message from user to a group.
+// Shared by HandleSendGroupMessage and the WebSocket "send" frame.
+// A replyToID of 0 sends a message that is not a reply. A message with a
+// stored file attached may have empty content. If the sender already sent a
+// message with clientID, that message is returned instead. clientID must
+// already have passed validateClientID.
+// Returns the created message as seen by the sender.
+func sendGroupMessage(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, groupID int64, content string, replyToID int64, clientID string, file *upload) (MessageItem, error) {
+	content = strings.TrimSpace(content)
+	if file == nil || content != "" {
+		if err := validate.Message(content); err != nil {
+			return MessageItem{}, err
+		}
+	}
+
+	if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+		return MessageItem{}, err
+	}
+
+	if clientID != "" {
+		if item, found, err := findSentMessage(ctx, queries, user, clientID, 0, groupID); err != nil || found {
+			return item, err
+		}
+	}
+
+	var reply *ReplyItem
+	var parentID sql.NullInt64
+	// Members who joined after the parent was sent can't see it quoted
+	canSeeParent := make(map[int64]bool)
+	if replyToID > 0 {
+		parent, quote, err := quoteParent(ctx, queries, user, replyToID, 0, groupID)
+		if err != nil {
+			return MessageItem{}, err
+		}
+		reply = quote
+		parentID = sql.NullInt64{Int64: parent.ID, Valid: true}
+
+		viewers, err := queries.ListGroupMessageViewerIDs(ctx, store.ListGroupMessageViewerIDsParams{
+			ConversationID: groupID,
+			MessageID:      parent.ID,
+		})
+		if err != nil {
+			slog.Error("failed to list message viewers", "type", "request", "message_id", parent.ID, "error", err)
+			return MessageItem{}, err
+		}
+		for _, id := range viewers {
+			canSeeParent[id] = true
+		}
+	}
+
+	msg, err := queries.CreateGroupMessage(ctx, store.CreateGroupMessageParams{
+		SenderID:       user.ID,
+		ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+		Content:        content,
+		ReplyToID:      parentID,
+		ClientID:       sql.NullString{String: clientID, Valid: clientID != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent retry stored the message first
+		if item, found, err := findSentMessage(ctx, queries, user, clientID, 0, groupID); err != nil || found {
+			return item, err
+		}
+	}
+	if err != nil {
+		slog.Error("failed to create group message", "type", "request", "error", err)
+		return MessageItem{}, err
+	}
+
+	var attachment *AttachmentItem
+	if file != nil {
+		attachment, err = attachFile(ctx, queries, msg.ID, file)
+		if err != nil {
+			return MessageItem{}, err
+		}
+	}
+
+	slog.Info("group message sent", "type", "request", "from", user.ID, "group_id", groupID, "message_id", msg.ID)
+
+	item := MessageItem{
+		ID:             msg.ID,
+		Content:        msg.Content,
+		ContentHTML:    markup.Render(msg.Content),
+		SenderID:       msg.SenderID,
+		SenderName:     user.DisplayName,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: groupID,
+		ReplyTo:        reply,
+		Attachment:     attachment,
+		ExpiresAt:      msg.ExpiresAt.String,
+	}
+
+	memberIDs, err := queries.ListConversationMemberIDs(ctx, groupID)
+	if err != nil {
+		slog.Error("failed to list group members", "type", "request", "group_id", groupID, "error", err)
+		return MessageItem{}, err
+	}
+	for _, memberID := range memberIDs {
+		memberItem := item
+		memberItem.IsSent = memberID == user.ID
+		if memberItem.IsSent {
+			memberItem.ClientID = clientID
+		}
+		if reply != nil && !canSeeParent[memberID] {
+			memberItem.ReplyTo = &ReplyItem{ID: reply.ID, Unavailable: true}
+		}
+		hub.Publish(ctx, memberID, &realtime.Message{Type: "message", Payload: memberItem})
+	}
+
+	previewMessage(queries, hub, msg)
+	item.IsSent = true
+	item.ClientID = clientID
+	return item, nil
+}
+
+// markGroupRead advances user's read pointer in a group up to messageID.
+// A messageID of 0 or past the newest message means the newest message.
+func markGroupRead(ctx context.Context, queries *store.Queries, user *auth.User, groupID, messageID int64) error {
+	if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+		return err
+	}
+
+	latest, err := queries.GetLatestGroupMessageID(ctx, sql.NullInt64{Int64: groupID, Valid: true})
+	if err != nil {
+		slog.Error("failed to get latest group message", "type", "request", "error", err)
+		return err
+	}
+	if messageID <= 0 || messageID > latest {
+		messageID = latest
+	}
+
+	if err := queries.MarkGroupRead(ctx, store.MarkGroupReadParams{
+		LastReadMessageID: messageID,
+		ConversationID:    groupID,
+		UserID:            user.ID,
+	}); err != nil {
+		slog.Error("failed to update group read state", "type", "request", "error", err)
+		return err
+	}
+	return nil
+}
+
+// getGroup loads a group, returning errGroupNotFound unless userID is a member.
+func getGroup(ctx context.Context, queries *store.Queries, userID, groupID int64) (store.Conversation, error) {
+	if _, err := queries.GetConversationMember(ctx, store.GetConversationMemberParams{
+		ConversationID: groupID,
+		UserID:         userID,
+	}); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("failed to get group member", "type", "request", "error", err)
+		}
+		return store.Conversation{}, errGroupNotFound
+	}
+
+	group, err := queries.GetConversation(ctx, groupID)
+	if err != nil {
+		slog.Error("failed to get group", "type", "request", "error", err)
+		return store.Conversation{}, errGroupNotFound
+	}
+	return group, nil
+}
+
+// parseMemberIDs parses member_id form values into existing user IDs other than userID.
+func parseMemberIDs(ctx context.Context, queries *store.Queries, userID int64, values []string) ([]int64, error) {
+	seen := make(map[int64]bool)
+	var memberIDs []int64
+	for _, v := range values {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id == userID || seen[id] {
+			continue
+		}
+		if _, err := queries.GetUserByID(ctx, id); err != nil {
+			return nil, errMemberNotFound
+		}
+		seen[id] = true
+		memberIDs = append(memberIDs, id)
+	}
+	if len(memberIDs) == 0 {
+		return nil, errMembersMissing
+	}
+	return memberIDs, nil
+}
+
+// publishGroup sends a group's current membership to its members and to
+// any extra users, such as a member who just left.
+func publishGroup(ctx context.Context, queries *store.Queries, hub *realtime.Hub, group store.Conversation, extra ...int64) {
+	memberIDs, err := queries.ListConversationMemberIDs(ctx, group.ID)
+	if err != nil {
+		slog.Error("failed to list group members", "type", "request", "group_id", group.ID, "error", err)
+		return
+	}
+
+	msg := &realtime.Message{
+		Type: "group",
+		Payload: GroupPayload{
+			ConversationID: group.ID,
+			Name:           group.Name,
+			MemberIDs:      memberIDs,
+		},
+	}
+	for _, id := range append(memberIDs, extra...) {
+		hub.Publish(ctx, id, msg)
+	}
+}
@@ -3,13 +3,15 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/dukerupert/wantok/internal/attachments"
 	"github.com/dukerupert/wantok/internal/auth"
 	"github.com/dukerupert/wantok/internal/email"
+	"github.com/dukerupert/wantok/internal/presence"
 	"github.com/dukerupert/wantok/internal/realtime"
 	"github.com/dukerupert/wantok/internal/store"
 )
 
-func NewServer(queries *store.Queries, hub *realtime.Hub, mailer *email.Mailer) http.Handler {
+func NewServer(queries *store.Queries, hub *realtime.Hub, tracker *presence.Tracker, mailer *email.Mailer, files *attachments.Store) http.Handler {
 	mux := http.NewServeMux()
 
 	// Static files
@@ -30,13 +32,67 @@ func NewServer(queries *store.Queries, hub *realtime.Hub, mailer *email.Mailer)
 	mux.HandleFunc("POST /register/{token}", HandleRegister(queries))
 
 	// Protected routes (require auth)
-	mux.Handle("GET /", auth.RequireAuth(queries)(HandleChatPage(queries)))
+	mux.Handle("GET /", auth.RequireAuth(queries)(HandleChatPage(queries, hub, tracker)))
 	mux.Handle("GET /users", auth.RequireAuth(queries)(HandleListUsers(queries)))
+	mux.Handle("GET /search", auth.RequireAuth(queries)(HandleSearch(queries)))
 
 	// Messaging routes (require auth)
-	mux.Handle("GET /conversations", auth.RequireAuth(queries)(HandleGetConversations(queries)))
+	mux.Handle("GET /conversations", auth.RequireAuth(queries)(HandleGetConversations(queries, tracker)))
 	mux.Handle("GET /conversations/{userID}/messages", auth.RequireAuth(queries)(HandleGetMessages(queries)))
-	mux.Handle("POST /conversations/{userID}/messages", auth.RequireAuth(queries)(HandleSendMessage(queries, hub)))
+	mux.Handle("POST /conversations/{userID}/messages", auth.RequireAuth(queries)(HandleSendMessage(queries, hub, files)))
+	mux.Handle("POST /conversations/{userID}/read", auth.RequireAuth(queries)(HandleMarkRead(queries, hub)))
+	mux.Handle("DELETE /conversations/{userID}/messages/{messageID}", auth.RequireAuth(queries)(HandleDeleteMessage(queries, hub)))
+	mux.Handle("POST /conversations/{userID}/scheduled", auth.RequireAuth(queries)(HandleScheduleMessage(queries)))
+	mux.Handle("POST /conversations/{userID}/draft", auth.RequireAuth(queries)(HandleSaveDraft(queries, hub)))
+	mux.Handle("GET /conversations/{userID}/retention", auth.RequireAuth(queries)(HandleGetRetention(queries)))
+	mux.Handle("POST /conversations/{userID}/retention", auth.RequireAuth(queries)(HandleSetRetention(queries)))
+	mux.Handle("GET /conversations/{userID}/pinned", auth.RequireAuth(queries)(HandleListPinned(queries)))
+	mux.Handle("GET /conversations/{userID}/timer", auth.RequireAuth(queries)(HandleGetTimer(queries)))
+	mux.Handle("POST /conversations/{userID}/timer", auth.RequireAuth(queries)(HandleSetTimer(queries, hub)))
+
+	// Message routes (require auth)
+	mux.Handle("POST /messages/{messageID}", auth.RequireAuth(queries)(HandleEditMessage(queries, hub)))
+	mux.Handle("GET /messages/{messageID}/history", auth.RequireAuth(queries)(HandleGetMessageHistory(queries)))
+	mux.Handle("POST /messages/{messageID}/reactions", auth.RequireAuth(queries)(HandleToggleReaction(queries, hub)))
+	mux.Handle("POST /messages/{messageID}/star", auth.RequireAuth(queries)(HandleStarMessage(queries, hub)))
+	mux.Handle("DELETE /messages/{messageID}/star", auth.RequireAuth(queries)(HandleUnstarMessage(queries, hub)))
+	mux.Handle("POST /messages/{messageID}/pin", auth.RequireAuth(queries)(HandlePinMessage(queries, hub)))
+	mux.Handle("DELETE /messages/{messageID}/pin", auth.RequireAuth(queries)(HandleUnpinMessage(queries, hub)))
+
+	// Attachment routes (require auth)
+	mux.Handle("GET /attachments/{attachmentID}", auth.RequireAuth(queries)(HandleGetAttachment(queries, files)))
+	mux.Handle("GET /attachments/{attachmentID}/thumbnail", auth.RequireAuth(queries)(HandleGetAttachmentThumbnail(queries, files)))
+
+	// Group routes (require auth)
+	mux.Handle("POST /groups", auth.RequireAuth(queries)(HandleCreateGroup(queries, hub)))
+	mux.Handle("GET /groups/{groupID}/messages", auth.RequireAuth(queries)(HandleGetGroupMessages(queries)))
+	mux.Handle("POST /groups/{groupID}/messages", auth.RequireAuth(queries)(HandleSendGroupMessage(queries, hub, files)))
+	mux.Handle("DELETE /groups/{groupID}/messages/{messageID}", auth.RequireAuth(queries)(HandleDeleteGroupMessage(queries, hub)))
+	mux.Handle("POST /groups/{groupID}/members", auth.RequireAuth(queries)(HandleAddGroupMembers(queries, hub)))
+	mux.Handle("POST /groups/{groupID}/leave", auth.RequireAuth(queries)(HandleLeaveGroup(queries, hub)))
+	mux.Handle("POST /groups/{groupID}/read", auth.RequireAuth(queries)(HandleMarkGroupRead(queries)))
+	mux.Handle("POST /groups/{groupID}/scheduled", auth.RequireAuth(queries)(HandleScheduleGroupMessage(queries)))
+	mux.Handle("POST /groups/{groupID}/draft", auth.RequireAuth(queries)(HandleSaveGroupDraft(queries, hub)))
+	mux.Handle("GET /groups/{groupID}/retention", auth.RequireAuth(queries)(HandleGetGroupRetention(queries)))
+	mux.Handle("POST /groups/{groupID}/retention", auth.RequireAuth(queries)(HandleSetGroupRetention(queries)))
+	mux.Handle("GET /groups/{groupID}/pinned", auth.RequireAuth(queries)(HandleListGroupPinned(queries)))
+	mux.Handle("GET /groups/{groupID}/timer", auth.RequireAuth(queries)(HandleGetGroupTimer(queries)))
+	mux.Handle("POST /groups/{groupID}/timer", auth.RequireAuth(queries)(HandleSetGroupTimer(queries, hub)))
+
+	// Scheduled message routes (require auth)
+	mux.Handle("GET /scheduled", auth.RequireAuth(queries)(HandleListScheduled(queries)))
+	mux.Handle("POST /scheduled/{scheduledID}", auth.RequireAuth(queries)(HandleEditScheduled(queries)))
+	mux.Handle("DELETE /scheduled/{scheduledID}", auth.RequireAuth(queries)(HandleCancelScheduled(queries)))
+
+	// Draft routes (require auth)
+	mux.Handle("GET /drafts", auth.RequireAuth(queries)(HandleListDrafts(queries)))
+
+	// Starred message routes (require auth)
+	mux.Handle("GET /starred", auth.RequireAuth(queries)(HandleListStarred(queries)))
+
+	// Preferences routes (require auth)
+	mux.Handle("GET /preferences", auth.RequireAuth(queries)(HandlePreferencesPage(queries)))
+	mux.Handle("POST /preferences", auth.RequireAuth(queries)(HandleUpdatePreferences(queries)))
 
 	// Admin routes (require auth + admin)
 	mux.Handle("GET /admin", auth.RequireAuth(queries)(auth.RequireAdmin(HandleAdminPage(queries))))
@@ -44,9 +100,16 @@ func NewServer(queries *store.Queries, hub *realtime.Hub, mailer *email.Mailer)
 	mux.Handle("POST /admin/users/{id}", auth.RequireAuth(queries)(auth.RequireAdmin(HandleUpdateUser(queries))))
 	mux.Handle("POST /admin/users/{id}/delete", auth.RequireAuth(queries)(auth.RequireAdmin(HandleDeleteUser(queries))))
 	mux.Handle("POST /admin/invite", auth.RequireAuth(queries)(auth.RequireAdmin(HandleInviteUser(queries, mailer))))
+	mux.Handle("POST /admin/messages/{messageID}/keep", auth.RequireAuth(queries)(auth.RequireAdmin(HandleKeepMessage(queries))))
+	mux.Handle("POST /admin/conversations/{userID}/{otherUserID}/keep", auth.RequireAuth(queries)(auth.RequireAdmin(HandleKeepConversation(queries))))
+	mux.Handle("POST /admin/groups/{groupID}/keep", auth.RequireAuth(queries)(auth.RequireAdmin(HandleKeepGroup(queries))))
 
 	// WebSocket route (require auth)
-	mux.Handle("GET /ws", auth.RequireAuth(queries)(HandleWebSocket(hub, queries)))
+	mux.Handle("GET /ws", auth.RequireAuth(queries)(HandleWebSocket(hub, queries, tracker)))
+
+	// Server-Sent Events fallback (require auth)
+	mux.Handle("GET /events", auth.RequireAuth(queries)(HandleEvents(hub, queries, tracker)))
+	mux.Handle("POST /events/{streamID}", auth.RequireAuth(queries)(HandleEventFrame(hub)))
 
 	return mux
 }
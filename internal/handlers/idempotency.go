@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+const (
+	// maxClientIDLength is the longest client-generated message ID accepted.
+	maxClientIDLength = 64
+
+	// scheduledClientIDPrefix starts the IDs the scheduler gives the messages
+	// it sends, so clients may not use it.
+	scheduledClientIDPrefix = "scheduled-"
+)
+
+var (
+	errClientIDInvalid  = errors.New("client_id must be up to 64 letters, digits, '-' or '_'")
+	errClientIDReserved = errors.New("client_id must not start with \"" + scheduledClientIDPrefix + "\"")
+	errClientIDReused   = errors.New("client_id was already used for a message to another conversation")
+)
+
+// requestClientID returns the client-generated ID of a message sent over
+// HTTP, from the Idempotency-Key header or else the client_id form field.
+// Returns an empty string when the client didn't choose one.
+func requestClientID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("Idempotency-Key")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.FormValue("client_id"))
+}
+
+// validateClientID checks a client-generated message ID. UUIDs are accepted.
+// Callers check IDs where they arrive from the client, since sendMessage and
+// sendGroupMessage also take the scheduler's reserved ones.
+func validateClientID(id string) error {
+	if len(id) > maxClientIDLength {
+		return errClientIDInvalid
+	}
+	if strings.HasPrefix(id, scheduledClientIDPrefix) {
+		return errClientIDReserved
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return errClientIDInvalid
+		}
+	}
+	return nil
+}
+
+// findSentMessage looks up the message user already sent with clientID, so a
+// retried send can return it instead of storing a copy. found is false if
+// there is none. The message must have gone to recipientID, or to groupID
+// when it is set; otherwise errClientIDReused is returned.
+// Returns the message as seen by the sender.
+func findSentMessage(ctx context.Context, queries *store.Queries, user *auth.User, clientID string, recipientID, groupID int64) (item MessageItem, found bool, err error) {
+	m, err := queries.GetSentMessage(ctx, store.GetSentMessageParams{
+		SenderID: user.ID,
+		ClientID: sql.NullString{String: clientID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return MessageItem{}, false, nil
+		}
+		slog.Error("failed to get sent message", "type", "request", "error", err)
+		return MessageItem{}, false, err
+	}
+
+	if m.ConversationID.Int64 != groupID || (groupID == 0 && m.RecipientID.Int64 != recipientID) {
+		return MessageItem{}, false, errClientIDReused
+	}
+
+	slog.Info("message send retried", "type", "request", "from", user.ID, "message_id", m.ID)
+
+	item = MessageItem{
+		ID:             m.ID,
+		Content:        m.Content,
+		ContentHTML:    markup.Render(m.Content),
+		SenderID:       m.SenderID,
+		SenderName:     user.DisplayName,
+		CreatedAt:      m.CreatedAt,
+		IsSent:         true,
+		ConversationID: m.ConversationID.Int64,
+		EditedAt:       m.EditedAt.String,
+		Deleted:        m.DeletedAt.Valid,
+		ReplyTo:        newReplyItem(m.ReplyToID, m.ReplyContent, m.ReplyDeletedAt, m.ReplySenderDisplayName),
+		Attachment:     newAttachmentItem(m.AttachmentID, m.AttachmentFilename, m.AttachmentContentType, m.AttachmentSize, m.AttachmentWidth, m.AttachmentHeight, m.AttachmentThumbnailHash),
+		ExpiresAt:      m.ExpiresAt.String,
+		ClientID:       clientID,
+		Preview:        newPreviewItem(m.PreviewUrl, m.PreviewTitle, m.PreviewDescription, m.PreviewImageUrl, m.PreviewSiteName),
+	}
+	if groupID == 0 {
+		item.Status = getReceiptState(ctx, queries, user.ID, recipientID).status(m.ID)
+	}
+	return item, true, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/database"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// newTestQueries returns Queries for an empty in-memory database with the
+// schema applied.
+func newTestQueries(t *testing.T) *store.Queries {
+	t.Helper()
+	db, err := database.NewMemory()
+	if err != nil {
+		t.Fatalf("database.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return store.New(db)
+}
+
+func createTestUser(t *testing.T, queries *store.Queries, username string) *auth.User {
+	t.Helper()
+	u, err := queries.CreateUser(context.Background(), store.CreateUserParams{
+		Username:     username,
+		DisplayName:  username,
+		PasswordHash: "x",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser %s: %v", username, err)
+	}
+	return &auth.User{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName}
+}
+
+func TestValidateClientID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want error
+	}{
+		{"", nil},
+		{"3f2b8c1e-9d4a-4e6b-8f0a-1c2d3e4f5a6b", nil},
+		{"retry_1", nil},
+		{strings.Repeat("a", maxClientIDLength), nil},
+		{strings.Repeat("a", maxClientIDLength+1), errClientIDInvalid},
+		{"has space", errClientIDInvalid},
+		{"<script>", errClientIDInvalid},
+		{"scheduled-1", errClientIDReserved},
+		{"Scheduled-1", nil},
+	}
+	for _, tt := range tests {
+		if got := validateClientID(tt.id); got != tt.want {
+			t.Errorf("validateClientID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSendMessageIdempotent(t *testing.T) {
+	ctx := context.Background()
+	queries := newTestQueries(t)
+	hub := realtime.NewHub(queries, realtime.NewLocalBroker())
+	alice := createTestUser(t, queries, "alice")
+	bob := createTestUser(t, queries, "bob")
+	carol := createTestUser(t, queries, "carol")
+
+	first, err := sendMessage(ctx, queries, hub, alice, bob.ID, "hello", 0, "retry-1", nil)
+	if err != nil {
+		t.Fatalf("sendMessage: %v", err)
+	}
+	again, err := sendMessage(ctx, queries, hub, alice, bob.ID, "hello", 0, "retry-1", nil)
+	if err != nil {
+		t.Fatalf("retried sendMessage: %v", err)
+	}
+	if again.ID != first.ID || again.ClientID != "retry-1" || !again.IsSent {
+		t.Errorf("retry returned %+v, want message %d as sent by alice", again, first.ID)
+	}
+
+	// The ID belongs to the sender, so others can use it too
+	other, err := sendMessage(ctx, queries, hub, bob, alice.ID, "hi", 0, "retry-1", nil)
+	if err != nil {
+		t.Fatalf("sendMessage from bob: %v", err)
+	}
+	if other.ID == first.ID {
+		t.Errorf("bob's message was taken for alice's")
+	}
+
+	if _, err := sendMessage(ctx, queries, hub, alice, carol.ID, "hello", 0, "retry-1", nil); !errors.Is(err, errClientIDReused) {
+		t.Errorf("reusing the ID for another recipient: err = %v, want %v", err, errClientIDReused)
+	}
+
+	rows, err := queries.GetConversationMessages(ctx, store.GetConversationMessagesParams{
+		UserID:      alice.ID,
+		OtherUserID: sql.NullInt64{Int64: bob.ID, Valid: true},
+		BeforeID:    math.MaxInt64,
+		Limit:       50,
+	})
+	if err != nil {
+		t.Fatalf("GetConversationMessages: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Errorf("stored %d messages between alice and bob, want 2", len(rows))
+	}
+}
+
+// A retry that races the original finds the insert skipped by the unique
+// index, and returns the stored message instead.
+func TestCreateMessageConflict(t *testing.T) {
+	ctx := context.Background()
+	queries := newTestQueries(t)
+	alice := createTestUser(t, queries, "alice")
+	bob := createTestUser(t, queries, "bob")
+
+	params := store.CreateMessageParams{
+		SenderID:    alice.ID,
+		RecipientID: sql.NullInt64{Int64: bob.ID, Valid: true},
+		Content:     "hello",
+		ClientID:    sql.NullString{String: "retry-1", Valid: true},
+	}
+	msg, err := queries.CreateMessage(ctx, params)
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	if _, err := queries.CreateMessage(ctx, params); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("second CreateMessage: err = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	item, found, err := findSentMessage(ctx, queries, alice, "retry-1", bob.ID, 0)
+	if err != nil || !found {
+		t.Fatalf("findSentMessage = %v, %v", found, err)
+	}
+	if item.ID != msg.ID || item.Content != "hello" {
+		t.Errorf("findSentMessage returned %+v, want message %d", item, msg.ID)
+	}
+
+	if _, found, err := findSentMessage(ctx, queries, alice, "retry-2", bob.ID, 0); err != nil || found {
+		t.Errorf("findSentMessage for an unused ID = %v, %v", found, err)
+	}
+	if _, _, err := findSentMessage(ctx, queries, alice, "retry-1", 0, 1); !errors.Is(err, errClientIDReused) {
+		t.Errorf("findSentMessage for a group: err = %v, want %v", err, errClientIDReused)
+	}
+}
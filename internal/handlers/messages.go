@@ -2,13 +2,20 @@ package handlers
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/dukerupert/wantok/internal/attachments"
 	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/presence"
 	"github.com/dukerupert/wantok/internal/realtime"
 	"github.com/dukerupert/wantok/internal/store"
 	"github.com/dukerupert/wantok/internal/validate"
@@ -18,22 +25,51 @@ import (
 
 // MessageItem represents a single message for JSON API responses.
 type MessageItem struct {
-	ID         int64  `json:"id"`
-	Content    string `json:"content"`
-	SenderID   int64  `json:"sender_id"`
-	SenderName string `json:"sender_name"`
-	CreatedAt  string `json:"created_at"`
-	IsSent     bool   `json:"is_sent"`
+	ID             int64           `json:"id"`
+	Content        string          `json:"content"`
+	ContentHTML    string          `json:"content_html,omitempty"` // Content formatted for display; safe to insert as HTML
+	SenderID       int64           `json:"sender_id"`
+	SenderName     string          `json:"sender_name"`
+	CreatedAt      string          `json:"created_at"`
+	IsSent         bool            `json:"is_sent"`
+	Status         string          `json:"status,omitempty"`          // "sent", "delivered" or "read"; only for sent direct messages
+	ConversationID int64           `json:"conversation_id,omitempty"` // Set for group messages
+	EditedAt       string          `json:"edited_at,omitempty"`       // Set once the sender has edited the message
+	Deleted        bool            `json:"deleted,omitempty"`         // Deleted by the sender; content is empty
+	ReplyTo        *ReplyItem      `json:"reply_to,omitempty"`        // Set when the message replies to another
+	Reactions      []ReactionItem  `json:"reactions,omitempty"`       // Grouped by emoji, in the order first used
+	Attachment     *AttachmentItem `json:"attachment,omitempty"`      // Set when a file is attached
+	ExpiresAt      string          `json:"expires_at,omitempty"`      // When the message disappears, if the conversation has a timer
+	ClientID       string          `json:"client_id,omitempty"`       // ID the sending client chose; only in the sender's copy
+	Preview        *PreviewItem    `json:"preview,omitempty"`         // Set once the first link's page has been fetched
+	PinnedAt       string          `json:"pinned_at,omitempty"`       // Set while the message is pinned
+	Starred        bool            `json:"starred,omitempty"`         // The current user starred the message
 }
 
 // HandleChatPage renders the main chat interface.
-func HandleChatPage(queries *store.Queries) http.HandlerFunc {
+func HandleChatPage(queries *store.Queries, hub *realtime.Hub, tracker *presence.Tracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		user := auth.GetUser(ctx)
 
+		// Read the event position first so anything newer is replayed over the socket
+		lastEventSeq, err := queries.GetLatestUserEventSeq(ctx, user.ID)
+		if err != nil {
+			slog.Error("failed to get latest event seq", "type", "request", "error", err)
+		}
+
+		// Opening a conversation reads it; do this before counting unread messages
+		activeUserID, _ := strconv.ParseInt(r.URL.Query().Get("user"), 10, 64)
+		if activeUserID > 0 && activeUserID != user.ID {
+			markConversation(ctx, queries, hub, user, activeUserID, 0, true)
+		}
+		activeGroupID, _ := strconv.ParseInt(r.URL.Query().Get("group"), 10, 64)
+		if activeGroupID > 0 {
+			markGroupRead(ctx, queries, user, activeGroupID, 0)
+		}
+
 		// Fetch conversations list
-		conversations := getConversationsListForPage(queries, ctx, user.ID)
+		conversations := getConversationsListForPage(queries, tracker, ctx, user.ID)
 
 		// Check for ?user= query param to load specific conversation
 		data := pages.ChatPageData{
@@ -41,8 +77,13 @@ func HandleChatPage(queries *store.Queries) http.HandlerFunc {
 			CurrentUserID:   user.ID,
 			CurrentUserName: user.DisplayName,
 			IsAdmin:         user.IsAdmin,
+			LastEventSeq:    lastEventSeq,
+			EditingEnabled:  EditWindow > 0,
 		}
 
+		// Linked from a search result: load back far enough to show it
+		data.FocusMessageID, _ = strconv.ParseInt(r.URL.Query().Get("message"), 10, 64)
+
 		userIDParam := r.URL.Query().Get("user")
 		if userIDParam != "" {
 			otherUserID, err := strconv.ParseInt(userIDParam, 10, 64)
@@ -52,17 +93,27 @@ func HandleChatPage(queries *store.Queries) http.HandlerFunc {
 				if err == nil {
 					data.ActiveUserID = otherUserID
 					data.ActiveUserName = otherUser.DisplayName
+					data.ActiveUserStatus = string(tracker.Status(otherUserID))
+					data.ActiveUserLastSeen = otherUser.LastSeenAt.String
+					data.Draft, _ = queries.GetDirectDraft(ctx, store.GetDirectDraftParams{
+						UserID:      user.ID,
+						OtherUserID: sql.NullInt64{Int64: otherUserID, Valid: true},
+					})
+					data.DisappearSeconds = getDirectTimer(ctx, queries, user.ID, otherUserID)
+					if pins, err := listPinned(ctx, queries, user.ID, otherUserID); err == nil {
+						data.Pinned = pinProps(pins)
+					}
 
 					// Fetch messages
+					limit := pageSize(ctx, queries, user.ID, otherUserID, 0, data.FocusMessageID)
 					msgs, err := queries.GetConversationMessages(ctx, store.GetConversationMessagesParams{
-						SenderID:      user.ID,
-						RecipientID:   otherUserID,
-						SenderID_2:    otherUserID,
-						RecipientID_2: user.ID,
-						Limit:         50,
-						Offset:        0,
+						UserID:      user.ID,
+						OtherUserID: sql.NullInt64{Int64: otherUserID, Valid: true},
+						BeforeID:    math.MaxInt64,
+						Limit:       limit,
 					})
 					if err == nil {
+						receipts := getReceiptState(ctx, queries, user.ID, otherUserID)
 						data.Messages = make([]pages.MessageItem, len(msgs))
 						for i, m := range msgs {
 							data.Messages[i] = pages.MessageItem{
@@ -72,13 +123,32 @@ func HandleChatPage(queries *store.Queries) http.HandlerFunc {
 								SenderName: m.SenderDisplayName,
 								CreatedAt:  m.CreatedAt,
 								IsSent:     m.SenderID == user.ID,
+								EditedAt:   m.EditedAt.String,
+								Deleted:    m.DeletedAt.Valid,
+								ReplyTo:    newReplyItem(m.ReplyToID, m.ReplyContent, m.ReplyDeletedAt, m.ReplySenderDisplayName).props(),
+								Reactions:  reactionProps(parseReactions(m.Reactions, user.ID)),
+								Attachment: newAttachmentItem(m.AttachmentID, m.AttachmentFilename, m.AttachmentContentType, m.AttachmentSize, m.AttachmentWidth, m.AttachmentHeight, m.AttachmentThumbnailHash).props(),
+								Preview:    newPreviewItem(m.PreviewUrl, m.PreviewTitle, m.PreviewDescription, m.PreviewImageUrl, m.PreviewSiteName).props(),
+								Pinned:     m.PinnedAt.Valid,
+								Starred:    m.Starred != 0,
 							}
+							if m.SenderID == user.ID {
+								data.Messages[i].Status = receipts.status(m.ID)
+								data.Messages[i].Editable = !m.DeletedAt.Valid && editable(m.CreatedAt)
+							}
+						}
+						if len(msgs) > 0 {
+							data.OlderMessagesURL = olderMessagesURL(fmt.Sprintf("/conversations/%d/messages", otherUserID), msgs[len(msgs)-1].ID, len(msgs), limit)
 						}
 					}
 				}
 			}
 		}
 
+		if activeGroupID > 0 && data.ActiveUserID == 0 {
+			loadActiveGroup(ctx, queries, user, activeGroupID, &data)
+		}
+
 		if err := pages.Chat(data).Render(ctx, w); err != nil {
 			slog.Error("failed to render chat page", "type", "request", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -87,12 +157,12 @@ func HandleChatPage(queries *store.Queries) http.HandlerFunc {
 }
 
 // HandleGetConversations returns the conversation list as JSON.
-func HandleGetConversations(queries *store.Queries) http.HandlerFunc {
+func HandleGetConversations(queries *store.Queries, tracker *presence.Tracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		user := auth.GetUser(ctx)
 
-		conversations := getConversationsList(queries, ctx, user.ID)
+		conversations := getConversationsList(queries, tracker, ctx, user.ID)
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(conversations); err != nil {
@@ -101,7 +171,9 @@ func HandleGetConversations(queries *store.Queries) http.HandlerFunc {
 	}
 }
 
-// HandleGetMessages returns messages for a conversation as JSON.
+// HandleGetMessages returns a page of messages in a conversation, newest first.
+// HTMX requests get them rendered, to load older messages into the chat page.
+// Route: GET /conversations/{userID}/messages?before_id=&after_id=&limit=
 func HandleGetMessages(queries *store.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -120,28 +192,19 @@ func HandleGetMessages(queries *store.Queries) http.HandlerFunc {
 			return
 		}
 
-		// Parse pagination params
-		limit := int64(50)
-		offset := int64(0)
-		if l := r.URL.Query().Get("limit"); l != "" {
-			if parsed, err := strconv.ParseInt(l, 10, 64); err == nil && parsed > 0 && parsed <= 100 {
-				limit = parsed
-			}
-		}
-		if o := r.URL.Query().Get("offset"); o != "" {
-			if parsed, err := strconv.ParseInt(o, 10, 64); err == nil && parsed >= 0 {
-				offset = parsed
-			}
+		page, err := parseMessagePage(r)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
 		}
 
 		// Fetch messages
 		msgs, err := queries.GetConversationMessages(ctx, store.GetConversationMessagesParams{
-			SenderID:      user.ID,
-			RecipientID:   otherUserID,
-			SenderID_2:    otherUserID,
-			RecipientID_2: user.ID,
-			Limit:         limit,
-			Offset:        offset,
+			UserID:      user.ID,
+			OtherUserID: sql.NullInt64{Int64: otherUserID, Valid: true},
+			BeforeID:    page.BeforeID,
+			AfterID:     page.AfterID,
+			Limit:       page.Limit,
 		})
 		if err != nil {
 			slog.Error("failed to get messages", "type", "request", "error", err)
@@ -150,27 +213,40 @@ func HandleGetMessages(queries *store.Queries) http.HandlerFunc {
 		}
 
 		// Transform to MessageItem slice
+		receipts := getReceiptState(ctx, queries, user.ID, otherUserID)
 		messages := make([]MessageItem, len(msgs))
 		for i, m := range msgs {
 			messages[i] = MessageItem{
-				ID:         m.ID,
-				Content:    m.Content,
-				SenderID:   m.SenderID,
-				SenderName: m.SenderDisplayName,
-				CreatedAt:  m.CreatedAt,
-				IsSent:     m.SenderID == user.ID,
+				ID:          m.ID,
+				Content:     m.Content,
+				ContentHTML: markup.Render(m.Content),
+				SenderID:    m.SenderID,
+				SenderName:  m.SenderDisplayName,
+				CreatedAt:   m.CreatedAt,
+				IsSent:      m.SenderID == user.ID,
+				EditedAt:    m.EditedAt.String,
+				Deleted:     m.DeletedAt.Valid,
+				ReplyTo:     newReplyItem(m.ReplyToID, m.ReplyContent, m.ReplyDeletedAt, m.ReplySenderDisplayName),
+				Reactions:   parseReactions(m.Reactions, user.ID),
+				Attachment:  newAttachmentItem(m.AttachmentID, m.AttachmentFilename, m.AttachmentContentType, m.AttachmentSize, m.AttachmentWidth, m.AttachmentHeight, m.AttachmentThumbnailHash),
+				ExpiresAt:   m.ExpiresAt.String,
+				Preview:     newPreviewItem(m.PreviewUrl, m.PreviewTitle, m.PreviewDescription, m.PreviewImageUrl, m.PreviewSiteName),
+				PinnedAt:    m.PinnedAt.String,
+				Starred:     m.Starred != 0,
+			}
+			if m.SenderID == user.ID {
+				messages[i].Status = receipts.status(m.ID)
 			}
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(messages); err != nil {
-			slog.Error("failed to encode messages", "type", "request", "error", err)
-		}
+		writeMessagePage(w, r, messages, page, fmt.Sprintf("/conversations/%d/messages", otherUserID))
 	}
 }
 
 // HandleSendMessage creates a new message in a conversation.
-func HandleSendMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+// Form fields: content, reply_to_id, client_id and, in multipart requests, an optional file.
+// A retry with the same client_id, or Idempotency-Key header, returns the original message.
+func HandleSendMessage(queries *store.Queries, hub *realtime.Hub, files *attachments.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		user := auth.GetUser(ctx)
@@ -188,184 +264,230 @@ func HandleSendMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFu
 			return
 		}
 
-		// Parse form
-		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Bad request", http.StatusBadRequest)
+		// Parse form, storing any attached file
+		file, err := parseSendForm(w, r, files)
+		if err != nil {
+			writeUploadError(w, err)
 			return
 		}
 
-		content := strings.TrimSpace(r.FormValue("content"))
-		if err := validate.Message(content); err != nil {
+		clientID := requestClientID(r)
+		if err := validateClientID(clientID); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		// Verify recipient exists
-		recipient, err := queries.GetUserByID(ctx, recipientID)
-		if err != nil {
-			http.Error(w, "Recipient not found", http.StatusNotFound)
-			return
-		}
-
-		// Create message
-		msg, err := queries.CreateMessage(ctx, store.CreateMessageParams{
-			SenderID:    user.ID,
-			RecipientID: recipientID,
-			Content:     content,
-		})
+		response, err := sendMessage(ctx, queries, hub, user, recipientID, r.FormValue("content"), parseReplyToID(r.FormValue("reply_to_id")), clientID, file)
 		if err != nil {
-			slog.Error("failed to create message", "type", "request", "error", err)
-			http.Error(w, "Failed to send message", http.StatusInternalServerError)
+			switch {
+			case errors.Is(err, errRecipientNotFound):
+				http.Error(w, "Recipient not found", http.StatusNotFound)
+			case isSendValidationError(err):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to send message", http.StatusInternalServerError)
+			}
 			return
 		}
-
-		slog.Info("message sent", "type", "request", "from", user.ID, "to", recipientID, "message_id", msg.ID)
-
-		// Broadcast via WebSocket to sender's other devices and recipient
-		wsMsg := &realtime.Message{
-			Type: "message",
-			Payload: MessageItem{
-				ID:         msg.ID,
-				Content:    msg.Content,
-				SenderID:   msg.SenderID,
-				SenderName: user.DisplayName,
-				CreatedAt:  msg.CreatedAt,
-				IsSent:     false, // Will be determined by recipient
-			},
-		}
-		hub.SendToUser(recipientID, wsMsg)
-		// Also send to sender's other devices (mark as sent)
-		wsMsg.Payload = MessageItem{
-			ID:         msg.ID,
-			Content:    msg.Content,
-			SenderID:   msg.SenderID,
-			SenderName: user.DisplayName,
-			CreatedAt:  msg.CreatedAt,
-			IsSent:     true,
-		}
-		hub.SendToUser(user.ID, wsMsg)
+		clearDraft(ctx, queries, hub, user, recipientID, 0)
 
 		// Check if HTMX request - return HTML fragment using templ
 		if r.Header.Get("HX-Request") == "true" {
 			w.Header().Set("Content-Type", "text/html")
 			w.WriteHeader(http.StatusCreated)
-			partials.Message(msg.ID, msg.Content, msg.CreatedAt, true).Render(ctx, w)
+			partials.Message(partials.MessageProps{
+				ID:         response.ID,
+				Content:    response.Content,
+				CreatedAt:  response.CreatedAt,
+				IsSent:     true,
+				Status:     response.Status,
+				Editable:   EditWindow > 0,
+				ReplyTo:    response.ReplyTo.props(),
+				Attachment: response.Attachment.props(),
+			}).Render(ctx, w)
 			return
 		}
 
 		// Return created message as JSON for API clients
-		response := MessageItem{
-			ID:         msg.ID,
-			Content:    msg.Content,
-			SenderID:   msg.SenderID,
-			SenderName: user.DisplayName,
-			CreatedAt:  msg.CreatedAt,
-			IsSent:     true,
-		}
-
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			slog.Error("failed to encode message", "type", "request", "error", err)
 		}
-
-		_ = recipient // recipient info used above
 	}
 }
 
-// ConversationListItem represents a conversation for JSON API responses.
-type ConversationListItem struct {
-	UserID          int64  `json:"user_id"`
-	DisplayName     string `json:"display_name"`
-	LastMessage     string `json:"last_message"`
-	LastMessageTime string `json:"last_message_time"`
+var (
+	errMessageSelf       = errors.New("cannot message yourself")
+	errRecipientNotFound = errors.New("recipient not found")
+)
+
+// isSendValidationError reports whether err from sendMessage was caused by bad input.
+func isSendValidationError(err error) bool {
+	return errors.Is(err, errMessageSelf) ||
+		errors.Is(err, errReplyNotFound) ||
+		errors.Is(err, errClientIDReused) ||
+		errors.Is(err, validate.ErrMessageEmpty) ||
+		errors.Is(err, validate.ErrMessageTooLong)
 }
 
-// getConversationsListForPage fetches conversations for templ page rendering.
-func getConversationsListForPage(queries *store.Queries, ctx context.Context, userID int64) []pages.ConversationListItem {
-	rows, err := queries.GetRecentMessagePerUser(ctx, store.GetRecentMessagePerUserParams{
-		SenderID:    userID,
-		SenderID_2:  userID,
-		RecipientID: userID,
-	})
-	if err != nil {
-		slog.Error("failed to get conversations", "type", "request", "error", err)
-		return []pages.ConversationListItem{}
+// sendMessage validates, stores and broadcasts a message from user to recipientID.
+// Shared by HandleSendMessage and the WebSocket "send" frame.
+// A replyToID of 0 sends a message that is not a reply. A message with a
+// stored file attached may have empty content. If the sender already sent a
+// message with clientID, that message is returned instead. clientID must
+// already have passed validateClientID.
+// Returns the created message as seen by the sender.
+func sendMessage(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, recipientID int64, content string, replyToID int64, clientID string, file *upload) (MessageItem, error) {
+	if recipientID == user.ID {
+		return MessageItem{}, errMessageSelf
 	}
 
-	// Deduplicate by other user ID, keeping most recent (already sorted by created_at DESC)
-	seen := make(map[int64]bool)
-	var conversations []pages.ConversationListItem
+	content = strings.TrimSpace(content)
+	if file == nil || content != "" {
+		if err := validate.Message(content); err != nil {
+			return MessageItem{}, err
+		}
+	}
 
-	for _, row := range rows {
-		// Determine the other user's ID
-		otherUserID := row.RecipientID
-		if row.SenderID != userID {
-			otherUserID = row.SenderID
+	// Verify recipient exists
+	if _, err := queries.GetUserByID(ctx, recipientID); err != nil {
+		return MessageItem{}, errRecipientNotFound
+	}
+
+	if clientID != "" {
+		if item, found, err := findSentMessage(ctx, queries, user, clientID, recipientID, 0); err != nil || found {
+			return item, err
 		}
+	}
 
-		if seen[otherUserID] {
-			continue
+	var reply *ReplyItem
+	var parentID sql.NullInt64
+	if replyToID > 0 {
+		parent, quote, err := quoteParent(ctx, queries, user, replyToID, recipientID, 0)
+		if err != nil {
+			return MessageItem{}, err
 		}
-		seen[otherUserID] = true
+		reply = quote
+		parentID = sql.NullInt64{Int64: parent.ID, Valid: true}
+	}
 
-		// Truncate message preview
-		preview := row.Content
-		if len(preview) > 50 {
-			preview = preview[:47] + "..."
+	// Create message
+	msg, err := queries.CreateMessage(ctx, store.CreateMessageParams{
+		SenderID:    user.ID,
+		RecipientID: sql.NullInt64{Int64: recipientID, Valid: true},
+		Content:     content,
+		ReplyToID:   parentID,
+		ClientID:    sql.NullString{String: clientID, Valid: clientID != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// A concurrent retry stored the message first
+		if item, found, err := findSentMessage(ctx, queries, user, clientID, recipientID, 0); err != nil || found {
+			return item, err
 		}
+	}
+	if err != nil {
+		slog.Error("failed to create message", "type", "request", "error", err)
+		return MessageItem{}, err
+	}
 
-		conversations = append(conversations, pages.ConversationListItem{
-			UserID:          otherUserID,
-			DisplayName:     row.OtherUserDisplayName,
-			LastMessage:     preview,
-			LastMessageTime: row.CreatedAt,
-		})
+	var attachment *AttachmentItem
+	if file != nil {
+		attachment, err = attachFile(ctx, queries, msg.ID, file)
+		if err != nil {
+			return MessageItem{}, err
+		}
 	}
 
+	slog.Info("message sent", "type", "request", "from", user.ID, "to", recipientID, "message_id", msg.ID)
+
+	// The message replaces the sender's typing indicator
+	hub.StopTyping(user.ID, recipientID)
+
+	item := MessageItem{
+		ID:          msg.ID,
+		Content:     msg.Content,
+		ContentHTML: markup.Render(msg.Content),
+		SenderID:    msg.SenderID,
+		SenderName:  user.DisplayName,
+		CreatedAt:   msg.CreatedAt,
+		IsSent:      false, // Will be determined by recipient
+		ReplyTo:     reply,
+		Attachment:  attachment,
+		ExpiresAt:   msg.ExpiresAt.String,
+	}
+
+	// Broadcast via WebSocket to sender's other devices and recipient
+	hub.Publish(ctx, recipientID, &realtime.Message{Type: "message", Payload: item})
+	// Also send to sender's other devices (mark as sent)
+	item.IsSent = true
+	item.Status = messageStatusSent
+	item.ClientID = clientID
+	hub.Publish(ctx, user.ID, &realtime.Message{Type: "message", Payload: item})
+
+	previewMessage(queries, hub, msg)
+	return item, nil
+}
+
+// ConversationListItem represents a conversation for JSON API responses.
+// Group conversations set GroupID and use the group's name as DisplayName.
+type ConversationListItem struct {
+	UserID          int64  `json:"user_id"`
+	GroupID         int64  `json:"group_id,omitempty"`
+	DisplayName     string `json:"display_name"`
+	LastMessage     string `json:"last_message"`
+	LastMessageTime string `json:"last_message_time"`
+	Status          string `json:"status"`                 // "online", "away" or "offline"
+	LastSeenAt      string `json:"last_seen_at,omitempty"` // When the user was last connected
+	UnreadCount     int64  `json:"unread_count"`
+}
+
+// getConversationsListForPage fetches conversations for templ page rendering.
+func getConversationsListForPage(queries *store.Queries, tracker *presence.Tracker, ctx context.Context, userID int64) []pages.ConversationListItem {
+	list := getConversationsList(queries, tracker, ctx, userID)
+	conversations := make([]pages.ConversationListItem, len(list))
+	for i, c := range list {
+		conversations[i] = pages.ConversationListItem{
+			UserID:          c.UserID,
+			GroupID:         c.GroupID,
+			DisplayName:     c.DisplayName,
+			LastMessage:     c.LastMessage,
+			LastMessageTime: c.LastMessageTime,
+			Status:          c.Status,
+			LastSeenAt:      c.LastSeenAt,
+			UnreadCount:     c.UnreadCount,
+		}
+	}
 	return conversations
 }
 
-// getConversationsList fetches conversations for JSON API responses.
-func getConversationsList(queries *store.Queries, ctx context.Context, userID int64) []ConversationListItem {
-	rows, err := queries.GetRecentMessagePerUser(ctx, store.GetRecentMessagePerUserParams{
-		SenderID:    userID,
-		SenderID_2:  userID,
-		RecipientID: userID,
-	})
+// getConversationsList fetches conversations for JSON API responses,
+// most recent activity first across direct and group conversations.
+func getConversationsList(queries *store.Queries, tracker *presence.Tracker, ctx context.Context, userID int64) []ConversationListItem {
+	rows, err := queries.ListConversationSummaries(ctx, userID)
 	if err != nil {
 		slog.Error("failed to get conversations", "type", "request", "error", err)
 		return []ConversationListItem{}
 	}
 
-	// Deduplicate by other user ID, keeping most recent (already sorted by created_at DESC)
-	seen := make(map[int64]bool)
-	var conversations []ConversationListItem
-
-	for _, row := range rows {
-		// Determine the other user's ID
-		otherUserID := row.RecipientID
-		if row.SenderID != userID {
-			otherUserID = row.SenderID
+	conversations := make([]ConversationListItem, len(rows))
+	for i, row := range rows {
+		conversations[i] = ConversationListItem{
+			DisplayName:     row.DisplayName,
+			LastMessageTime: row.LastMessageAt,
+			UnreadCount:     row.UnreadCount,
 		}
-
-		if seen[otherUserID] {
-			continue
+		// Groups with no messages since the user joined have no preview
+		if row.LastMessageID.Valid {
+			conversations[i].LastMessage = messagePreview(row.LastMessage, row.LastMessageDeletedAt.Valid)
 		}
-		seen[otherUserID] = true
-
-		// Truncate message preview
-		preview := row.Content
-		if len(preview) > 50 {
-			preview = preview[:47] + "..."
+		if row.ConversationID.Valid {
+			conversations[i].GroupID = row.ConversationID.Int64
+			continue
 		}
-
-		conversations = append(conversations, ConversationListItem{
-			UserID:          otherUserID,
-			DisplayName:     row.OtherUserDisplayName,
-			LastMessage:     preview,
-			LastMessageTime: row.CreatedAt,
-		})
+		conversations[i].UserID = row.OtherUserID.Int64
+		conversations[i].Status = string(tracker.Status(row.OtherUserID.Int64))
+		conversations[i].LastSeenAt = row.OtherUserLastSeenAt.String
 	}
 
 	return conversations
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+const (
+	// Number of messages the chat page shows when a conversation is opened,
+	// and the default size of each page fetched after that.
+	messagePageSize = 50
+	// Largest page a client can ask for.
+	maxMessagePageSize = 100
+)
+
+var errBadCursor = errors.New("invalid cursor")
+
+// messagePage selects part of a conversation's history: up to Limit messages
+// with ids between AfterID and BeforeID, exclusive, newest first. Message ids
+// only grow, so a page stays the same however many messages arrive meanwhile.
+type messagePage struct {
+	BeforeID int64
+	AfterID  int64
+	Limit    int64
+}
+
+// parseMessagePage reads the before_id, after_id and limit query parameters.
+// Without cursors the page holds the newest messages.
+func parseMessagePage(r *http.Request) (messagePage, error) {
+	page := messagePage{BeforeID: math.MaxInt64, Limit: messagePageSize}
+
+	query := r.URL.Query()
+	if l := query.Get("limit"); l != "" {
+		if parsed, err := strconv.ParseInt(l, 10, 64); err == nil && parsed > 0 && parsed <= maxMessagePageSize {
+			page.Limit = parsed
+		}
+	}
+	if b := query.Get("before_id"); b != "" {
+		parsed, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || parsed <= 0 {
+			return page, errBadCursor
+		}
+		page.BeforeID = parsed
+	}
+	if a := query.Get("after_id"); a != "" {
+		parsed, err := strconv.ParseInt(a, 10, 64)
+		if err != nil || parsed < 0 {
+			return page, errBadCursor
+		}
+		page.AfterID = parsed
+	}
+	return page, nil
+}
+
+// olderMessagesURL returns the URL of the page before the oldest of messages,
+// which are newest first, or "" if messages didn't fill a page of limit and so
+// reached the start of the conversation.
+func olderMessagesURL(baseURL string, oldestID int64, count int, limit int64) string {
+	if count == 0 || int64(count) < limit {
+		return ""
+	}
+	return baseURL + "?before_id=" + strconv.FormatInt(oldestID, 10)
+}
+
+// writeMessagePage writes a page of messages fetched from baseURL. HTMX
+// requests get them rendered for the chat page, followed by a loader for the
+// page before, while other clients get JSON.
+func writeMessagePage(w http.ResponseWriter, r *http.Request, messages []MessageItem, page messagePage, baseURL string) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html")
+		for _, msg := range messages {
+			if err := partials.Message(msg.props()).Render(r.Context(), w); err != nil {
+				slog.Error("failed to render messages", "type", "request", "error", err)
+				return
+			}
+		}
+		if len(messages) > 0 {
+			if url := olderMessagesURL(baseURL, messages[len(messages)-1].ID, len(messages), page.Limit); url != "" {
+				if err := partials.OlderMessages(url).Render(r.Context(), w); err != nil {
+					slog.Error("failed to render messages", "type", "request", "error", err)
+				}
+			}
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(messages); err != nil {
+		slog.Error("failed to encode messages", "type", "request", "error", err)
+	}
+}
+
+// props converts the message for rendering with partials.Message.
+func (m *MessageItem) props() partials.MessageProps {
+	props := partials.MessageProps{
+		ID:         m.ID,
+		Content:    m.Content,
+		CreatedAt:  m.CreatedAt,
+		IsSent:     m.IsSent,
+		Status:     m.Status,
+		EditedAt:   m.EditedAt,
+		Editable:   m.IsSent && !m.Deleted && editable(m.CreatedAt),
+		Deleted:    m.Deleted,
+		ReplyTo:    m.ReplyTo.props(),
+		Reactions:  reactionProps(m.Reactions),
+		Attachment: m.Attachment.props(),
+		Preview:    m.Preview.props(),
+		Pinned:     m.PinnedAt != "",
+		Starred:    m.Starred,
+	}
+	if m.ConversationID != 0 {
+		props.SenderName = m.SenderName
+	}
+	return props
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/pages"
+)
+
+// PinItem is a message pinned in a conversation. It is also the payload of
+// "pinned" events, sent to everyone in the conversation when a message is
+// pinned or unpinned.
+type PinItem struct {
+	ID             int64  `json:"id"`
+	SenderID       int64  `json:"sender_id"`
+	SenderName     string `json:"sender_name"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+	UserID         int64  `json:"user_id,omitempty"`         // The other participant of a direct message
+	ConversationID int64  `json:"conversation_id,omitempty"` // Set for group messages
+	PinnedAt       string `json:"pinned_at,omitempty"`       // Empty once unpinned
+	PinnedByName   string `json:"pinned_by_name,omitempty"`
+	URL            string `json:"url"` // Opens the chat page at the message
+}
+
+// HandlePinMessage pins a message for everyone in its conversation.
+// Pinned messages are kept past the conversation's retention period.
+// Route: POST /messages/{messageID}/pin
+func HandlePinMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return handlePin(queries, hub, true)
+}
+
+// HandleUnpinMessage unpins a message.
+// Route: DELETE /messages/{messageID}/pin
+func HandleUnpinMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return handlePin(queries, hub, false)
+}
+
+func handlePin(queries *store.Queries, hub *realtime.Hub, pinned bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		response, err := setPin(ctx, queries, hub, user, messageID, pinned)
+		if err != nil {
+			switch {
+			case errors.Is(err, errMessageNotFound):
+				http.Error(w, "Message not found", http.StatusNotFound)
+			case errors.Is(err, errMessageDeleted):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, "Failed to update pin", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode pin", "type", "request", "error", err)
+		}
+	}
+}
+
+// setPin pins or unpins a message user can see and, if that changed
+// anything, sends a "pinned" event to everyone who can see it. Returns the
+// pin as seen by user.
+func setPin(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, messageID int64, pinned bool) (PinItem, error) {
+	msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+	if err != nil {
+		return PinItem{}, err
+	}
+	if msg.DeletedAt.Valid {
+		return PinItem{}, errMessageDeleted
+	}
+
+	sender, err := queries.GetUserByID(ctx, msg.SenderID)
+	if err != nil {
+		slog.Error("failed to get message sender", "type", "request", "message_id", msg.ID, "error", err)
+		return PinItem{}, err
+	}
+	item := PinItem{
+		ID:             msg.ID,
+		SenderID:       msg.SenderID,
+		SenderName:     sender.DisplayName,
+		Content:        msg.Content,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: msg.ConversationID.Int64,
+	}
+
+	var result sql.Result
+	if pinned {
+		result, err = queries.PinMessage(ctx, store.PinMessageParams{
+			MessageID: msg.ID,
+			PinnedBy:  sql.NullInt64{Int64: user.ID, Valid: true},
+		})
+	} else {
+		result, err = queries.UnpinMessage(ctx, msg.ID)
+	}
+	if err != nil {
+		slog.Error("failed to update pin", "type", "request", "message_id", msg.ID, "error", err)
+		return PinItem{}, err
+	}
+	// Nothing to send if it was already pinned, or already unpinned
+	count, _ := result.RowsAffected()
+	changed := count > 0
+
+	if pinned {
+		pin, err := queries.GetMessagePin(ctx, msg.ID)
+		if err != nil {
+			slog.Error("failed to get pin", "type", "request", "message_id", msg.ID, "error", err)
+			return PinItem{}, err
+		}
+		item.PinnedAt = pin.PinnedAt
+		item.PinnedByName = pin.PinnedByDisplayName.String
+	}
+
+	if changed {
+		slog.Info("message pin changed", "type", "request", "user_id", user.ID, "message_id", msg.ID, "pinned", pinned)
+
+		viewers, err := messageViewerIDs(ctx, queries, msg)
+		if err != nil {
+			slog.Error("failed to list message viewers", "type", "request", "message_id", msg.ID, "error", err)
+			return PinItem{}, err
+		}
+		for _, viewerID := range viewers {
+			hub.Publish(ctx, viewerID, &realtime.Message{Type: "pinned", Payload: pinItemFor(item, msg, viewerID)})
+		}
+	}
+	return pinItemFor(item, msg, user.ID), nil
+}
+
+// pinItemFor fills in the parts of a pin that depend on who is looking at it.
+func pinItemFor(item PinItem, msg store.Message, viewerID int64) PinItem {
+	if msg.ConversationID.Valid {
+		item.URL = fmt.Sprintf("/?group=%d&message=%d", msg.ConversationID.Int64, msg.ID)
+		return item
+	}
+	item.UserID = msg.RecipientID.Int64
+	if msg.SenderID != viewerID {
+		item.UserID = msg.SenderID
+	}
+	item.URL = fmt.Sprintf("/?user=%d&message=%d", item.UserID, msg.ID)
+	return item
+}
+
+// HandleListPinned returns the pinned messages in a direct conversation,
+// most recently pinned first.
+// Route: GET /conversations/{userID}/pinned
+func HandleListPinned(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil || otherUserID == user.ID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		pins, err := listPinned(ctx, queries, user.ID, otherUserID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writePins(w, pins)
+	}
+}
+
+// HandleListGroupPinned returns the pinned messages in a group the current
+// user belongs to, most recently pinned first.
+// Route: GET /groups/{groupID}/pinned
+func HandleListGroupPinned(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		pins, err := listGroupPinned(ctx, queries, user.ID, groupID)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		writePins(w, pins)
+	}
+}
+
+func writePins(w http.ResponseWriter, pins []PinItem) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pins); err != nil {
+		slog.Error("failed to encode pins", "type", "request", "error", err)
+	}
+}
+
+// listPinned returns the pinned messages between userID and otherUserID.
+func listPinned(ctx context.Context, queries *store.Queries, userID, otherUserID int64) ([]PinItem, error) {
+	rows, err := queries.ListPinnedMessages(ctx, store.ListPinnedMessagesParams{
+		UserID:      userID,
+		OtherUserID: sql.NullInt64{Int64: otherUserID, Valid: true},
+	})
+	if err != nil {
+		slog.Error("failed to list pinned messages", "type", "request", "error", err)
+		return nil, err
+	}
+
+	pins := make([]PinItem, len(rows))
+	for i, row := range rows {
+		pins[i] = PinItem{
+			ID:           row.ID,
+			SenderID:     row.SenderID,
+			SenderName:   row.SenderDisplayName,
+			Content:      row.Content,
+			CreatedAt:    row.CreatedAt,
+			UserID:       otherUserID,
+			PinnedAt:     row.PinnedAt,
+			PinnedByName: row.PinnedByDisplayName.String,
+			URL:          fmt.Sprintf("/?user=%d&message=%d", otherUserID, row.ID),
+		}
+	}
+	return pins, nil
+}
+
+// listGroupPinned returns the pinned messages in a group that userID can see.
+func listGroupPinned(ctx context.Context, queries *store.Queries, userID, groupID int64) ([]PinItem, error) {
+	rows, err := queries.ListGroupPinnedMessages(ctx, store.ListGroupPinnedMessagesParams{
+		ConversationID: groupID,
+		UserID:         userID,
+	})
+	if err != nil {
+		slog.Error("failed to list group pinned messages", "type", "request", "error", err)
+		return nil, err
+	}
+
+	pins := make([]PinItem, len(rows))
+	for i, row := range rows {
+		pins[i] = PinItem{
+			ID:             row.ID,
+			SenderID:       row.SenderID,
+			SenderName:     row.SenderDisplayName,
+			Content:        row.Content,
+			CreatedAt:      row.CreatedAt,
+			ConversationID: groupID,
+			PinnedAt:       row.PinnedAt,
+			PinnedByName:   row.PinnedByDisplayName.String,
+			URL:            fmt.Sprintf("/?group=%d&message=%d", groupID, row.ID),
+		}
+	}
+	return pins, nil
+}
+
+// pinProps converts pins for the chat page's pinned bar.
+func pinProps(pins []PinItem) []pages.PinItem {
+	props := make([]pages.PinItem, len(pins))
+	for i, p := range pins {
+		props[i] = pages.PinItem{
+			ID:           p.ID,
+			SenderName:   p.SenderName,
+			Content:      p.Content,
+			PinnedByName: p.PinnedByName,
+			URL:          p.URL,
+		}
+	}
+	return props
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/pages"
+)
+
+// HandlePreferencesPage renders the current user's preferences.
+func HandlePreferencesPage(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		u, err := queries.GetUserByID(ctx, user.ID)
+		if err != nil {
+			slog.Error("failed to get user", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data := pages.PreferencesPageData{
+			ReadReceipts: u.ReadReceipts != 0,
+			Saved:        r.URL.Query().Get("saved") == "1",
+		}
+
+		if err := pages.Preferences(data).Render(ctx, w); err != nil {
+			slog.Error("failed to render preferences page", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// HandleUpdatePreferences processes the preferences form.
+func HandleUpdatePreferences(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		if err := r.ParseForm(); err != nil {
+			slog.Error("failed to parse form", "type", "request", "error", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		var readReceipts int64
+		if r.FormValue("read_receipts") == "on" {
+			readReceipts = 1
+		}
+
+		err := queries.UpdateUserReadReceipts(ctx, store.UpdateUserReadReceiptsParams{
+			ReadReceipts: readReceipts,
+			ID:           user.ID,
+		})
+		if err != nil {
+			slog.Error("failed to update preferences", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("preferences updated", "type", "request", "user_id", user.ID, "read_receipts", readReceipts)
+		http.Redirect(w, r, "/preferences?saved=1", http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/linkpreview"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+// LinkPreviews fetches previews of links in sent messages. Nil turns link
+// previews off.
+var LinkPreviews *linkpreview.Fetcher
+
+// How long a fetched preview is reused before the page is fetched again.
+// Pages that couldn't be previewed are retried sooner.
+const (
+	previewMaxAge       = 7 * 24 * time.Hour
+	failedPreviewMaxAge = time.Hour
+)
+
+// PreviewItem describes the page behind the first link in a message.
+type PreviewItem struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// MessageUpdateItem is the payload of "message_updated" messages, sent when
+// a preview is attached to a message after it was sent.
+type MessageUpdateItem struct {
+	ID             int64        `json:"id"`
+	ConversationID int64        `json:"conversation_id,omitempty"` // Set for group messages
+	Preview        *PreviewItem `json:"preview"`
+}
+
+// newPreviewItem builds a message's preview from its joined link_previews
+// columns. Returns nil if the message has none.
+func newPreviewItem(url, title, description, imageURL, siteName sql.NullString) *PreviewItem {
+	if !url.Valid {
+		return nil
+	}
+	return &PreviewItem{
+		URL:         url.String,
+		Title:       title.String,
+		Description: description.String,
+		ImageURL:    imageURL.String,
+		SiteName:    siteName.String,
+	}
+}
+
+// props converts the preview for rendering with partials.Message.
+func (p *PreviewItem) props() *partials.PreviewProps {
+	if p == nil {
+		return nil
+	}
+	return &partials.PreviewProps{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		ImageURL:    p.ImageURL,
+		SiteName:    p.SiteName,
+	}
+}
+
+// previewMessage fetches a preview of the first link in a newly sent or
+// edited message in the background. Once it is attached, everyone who can
+// see the message gets a "message_updated" event.
+func previewMessage(queries *store.Queries, hub *realtime.Hub, msg store.Message) {
+	if LinkPreviews == nil {
+		return
+	}
+	link := markup.FirstLink(msg.Content)
+	if link == "" {
+		return
+	}
+
+	go func() {
+		// Detached from the request, which is over by the time the page is fetched
+		ctx, cancel := context.WithTimeout(context.Background(), 2*linkpreview.Timeout)
+		defer cancel()
+
+		preview := getLinkPreview(ctx, queries, link)
+		if preview == nil {
+			return
+		}
+
+		result, err := queries.SetMessagePreview(ctx, store.SetMessagePreviewParams{
+			PreviewUrl: sql.NullString{String: link, Valid: true},
+			ID:         msg.ID,
+			Content:    msg.Content,
+		})
+		if err != nil {
+			slog.Error("failed to attach link preview", "type", "preview", "message_id", msg.ID, "error", err)
+			return
+		}
+		// Edited, deleted or expired while the page was fetched. An edit
+		// starts a preview of its own.
+		if count, _ := result.RowsAffected(); count == 0 {
+			return
+		}
+
+		viewers, err := messageViewerIDs(ctx, queries, msg)
+		if err != nil {
+			slog.Error("failed to list message viewers", "type", "preview", "message_id", msg.ID, "error", err)
+			return
+		}
+		item := MessageUpdateItem{
+			ID:             msg.ID,
+			ConversationID: msg.ConversationID.Int64,
+			Preview:        preview,
+		}
+		for _, viewerID := range viewers {
+			hub.Publish(ctx, viewerID, &realtime.Message{Type: "message_updated", Payload: item})
+		}
+	}()
+}
+
+// getLinkPreview returns the preview of link, from the cache while it is
+// fresh or else fetched and cached. Returns nil if the page has no preview.
+func getLinkPreview(ctx context.Context, queries *store.Queries, link string) *PreviewItem {
+	cached, err := queries.GetLinkPreview(ctx, link)
+	switch {
+	case err == nil:
+		maxAge := previewMaxAge
+		if cached.Title == "" {
+			maxAge = failedPreviewMaxAge
+		}
+		if fetchedAt, err := time.Parse(timeFormat, cached.FetchedAt); err == nil && time.Since(fetchedAt) < maxAge {
+			return cachedPreviewItem(cached)
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		slog.Error("failed to get link preview", "type", "preview", "error", err)
+		return nil
+	}
+
+	preview, err := LinkPreviews.Fetch(ctx, link)
+	if err != nil {
+		// Failures are cached too, so a popular broken link isn't fetched for every message
+		slog.Info("link not previewed", "type", "preview", "error", err)
+	}
+	err = queries.SaveLinkPreview(ctx, store.SaveLinkPreviewParams{
+		Url:         link,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageUrl:    preview.ImageURL,
+		SiteName:    preview.SiteName,
+	})
+	if err != nil {
+		slog.Error("failed to save link preview", "type", "preview", "error", err)
+		return nil
+	}
+	if preview.Title == "" {
+		return nil
+	}
+	return &PreviewItem{
+		URL:         link,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageURL:    preview.ImageURL,
+		SiteName:    preview.SiteName,
+	}
+}
+
+// cachedPreviewItem converts a cached preview. Returns nil for pages that
+// couldn't be previewed.
+func cachedPreviewItem(p store.LinkPreview) *PreviewItem {
+	if p.Title == "" {
+		return nil
+	}
+	return &PreviewItem{
+		URL:         p.Url,
+		Title:       p.Title,
+		Description: p.Description,
+		ImageURL:    p.ImageUrl,
+		SiteName:    p.SiteName,
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/validate"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+// ReactionItem counts the reactions with one emoji on a message.
+type ReactionItem struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted,omitempty"` // The current user reacted with this emoji
+}
+
+// ReactionPayload is the payload of a "reaction" event.
+// Sent to everyone who can see the message whenever its reactions change.
+type ReactionPayload struct {
+	MessageID      int64          `json:"message_id"`
+	ConversationID int64          `json:"conversation_id,omitempty"` // Set for group messages
+	Reactions      []ReactionItem `json:"reactions"`
+}
+
+// reactionGroup is one element of the JSON reactions column returned by the message queries.
+type reactionGroup struct {
+	Emoji   string  `json:"emoji"`
+	UserIDs []int64 `json:"user_ids"`
+}
+
+// HandleToggleReaction adds the current user's reaction to a message, or removes it if present.
+// Route: POST /messages/{messageID}/reactions
+// Form field: emoji.
+func HandleToggleReaction(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		response, err := toggleReaction(ctx, queries, hub, user, messageID, r.FormValue("emoji"))
+		if err != nil {
+			switch {
+			case errors.Is(err, errMessageNotFound):
+				http.Error(w, "Message not found", http.StatusNotFound)
+			case errors.Is(err, errMessageDeleted):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, validate.ErrReactionEmpty), errors.Is(err, validate.ErrReactionInvalid):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to update reaction", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode reactions", "type", "request", "error", err)
+		}
+	}
+}
+
+// toggleReaction adds or removes user's emoji reaction on a message and sends
+// the new counts to everyone who can see it. Returns the counts as seen by user.
+func toggleReaction(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, messageID int64, emoji string) (ReactionPayload, error) {
+	emoji = strings.TrimSpace(emoji)
+	if err := validate.Reaction(emoji); err != nil {
+		return ReactionPayload{}, err
+	}
+
+	msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+	if err != nil {
+		return ReactionPayload{}, err
+	}
+	if msg.DeletedAt.Valid {
+		return ReactionPayload{}, errMessageDeleted
+	}
+
+	result, err := queries.RemoveReaction(ctx, store.RemoveReactionParams{
+		MessageID: msg.ID,
+		UserID:    user.ID,
+		Emoji:     emoji,
+	})
+	if err != nil {
+		slog.Error("failed to remove reaction", "type", "request", "error", err)
+		return ReactionPayload{}, err
+	}
+	if removed, _ := result.RowsAffected(); removed == 0 {
+		if err := queries.AddReaction(ctx, store.AddReactionParams{
+			MessageID: msg.ID,
+			UserID:    user.ID,
+			Emoji:     emoji,
+		}); err != nil {
+			slog.Error("failed to add reaction", "type", "request", "error", err)
+			return ReactionPayload{}, err
+		}
+	}
+
+	data, err := queries.GetMessageReactions(ctx, msg.ID)
+	if err != nil {
+		slog.Error("failed to get reactions", "type", "request", "message_id", msg.ID, "error", err)
+		return ReactionPayload{}, err
+	}
+
+	viewers, err := messageViewerIDs(ctx, queries, msg)
+	if err != nil {
+		slog.Error("failed to list message viewers", "type", "request", "message_id", msg.ID, "error", err)
+		return ReactionPayload{}, err
+	}
+	for _, viewerID := range viewers {
+		hub.Publish(ctx, viewerID, &realtime.Message{Type: "reaction", Payload: ReactionPayload{
+			MessageID:      msg.ID,
+			ConversationID: msg.ConversationID.Int64,
+			Reactions:      parseReactions(data, viewerID),
+		}})
+	}
+
+	return ReactionPayload{
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID.Int64,
+		Reactions:      parseReactions(data, user.ID),
+	}, nil
+}
+
+// parseReactions decodes the JSON reactions column of a message as seen by viewerID.
+// Returns an empty slice when there are no reactions.
+func parseReactions(data string, viewerID int64) []ReactionItem {
+	var groups []reactionGroup
+	if err := json.Unmarshal([]byte(data), &groups); err != nil {
+		slog.Error("failed to decode reactions", "type", "request", "error", err)
+		return []ReactionItem{}
+	}
+
+	reactions := make([]ReactionItem, len(groups))
+	for i, g := range groups {
+		reactions[i] = ReactionItem{Emoji: g.Emoji, Count: len(g.UserIDs)}
+		for _, id := range g.UserIDs {
+			if id == viewerID {
+				reactions[i].Reacted = true
+				break
+			}
+		}
+	}
+	return reactions
+}
+
+// reactionProps converts reactions for rendering with partials.Message.
+func reactionProps(reactions []ReactionItem) []partials.ReactionProps {
+	props := make([]partials.ReactionProps, len(reactions))
+	for i, r := range reactions {
+		props[i] = partials.ReactionProps{
+			Emoji:   r.Emoji,
+			Count:   r.Count,
+			Reacted: r.Reacted,
+		}
+	}
+	return props
+}
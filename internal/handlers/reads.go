@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// Delivery states of a sent message, as shown to its sender.
+const (
+	messageStatusSent      = "sent"
+	messageStatusDelivered = "delivered"
+	messageStatusRead      = "read"
+)
+
+// ReadPayload is the payload of a "read" event.
+// Sent to the reader's own devices and to the other participant's devices.
+type ReadPayload struct {
+	ReaderID               int64 `json:"reader_id"`                 // User whose pointers moved
+	OtherUserID            int64 `json:"other_user_id"`             // Sender of the messages being acknowledged
+	LastDeliveredMessageID int64 `json:"last_delivered_message_id"` // Highest message ID received by the reader
+	LastReadMessageID      int64 `json:"last_read_message_id"`      // 0 when hidden by the reader's preferences
+}
+
+// receiptState holds what a sender may see about the other participant's progress.
+type receiptState struct {
+	lastDelivered int64
+	lastRead      int64
+}
+
+// status returns the delivery state of a sent message.
+func (s receiptState) status(messageID int64) string {
+	switch {
+	case messageID <= s.lastRead:
+		return messageStatusRead
+	case messageID <= s.lastDelivered:
+		return messageStatusDelivered
+	default:
+		return messageStatusSent
+	}
+}
+
+// getReceiptState loads how far otherUserID has received and read userID's messages.
+// The read pointer is hidden when otherUserID has turned read receipts off.
+func getReceiptState(ctx context.Context, queries *store.Queries, userID, otherUserID int64) receiptState {
+	read, err := queries.GetConversationRead(ctx, store.GetConversationReadParams{
+		UserID:      otherUserID,
+		OtherUserID: userID,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.Error("failed to get conversation read state", "type", "request", "error", err)
+		}
+		return receiptState{}
+	}
+
+	state := receiptState{lastDelivered: read.LastDeliveredMessageID}
+	if other, err := queries.GetUserByID(ctx, otherUserID); err == nil && other.ReadReceipts != 0 {
+		state.lastRead = read.LastReadMessageID
+	}
+	return state
+}
+
+// HandleMarkRead marks messages from another user as read.
+// Route: POST /conversations/{userID}/read
+// Optional form field message_id; defaults to the newest message.
+func HandleMarkRead(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		var messageID int64
+		if v := r.FormValue("message_id"); v != "" {
+			messageID, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "Invalid message ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := markConversation(ctx, queries, hub, user, otherUserID, messageID, true); err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// markConversation advances user's delivered (and, if read is set, read) pointer
+// for messages from otherUserID up to messageID. A messageID of 0 or past the
+// newest message means the newest message. Changes are published to both users.
+func markConversation(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, otherUserID, messageID int64, read bool) error {
+	latest, err := queries.GetLatestMessageIDFrom(ctx, store.GetLatestMessageIDFromParams{
+		SenderID:    otherUserID,
+		RecipientID: sql.NullInt64{Int64: user.ID, Valid: true},
+	})
+	if err != nil {
+		slog.Error("failed to get latest message", "type", "request", "error", err)
+		return err
+	}
+	if messageID <= 0 || messageID > latest {
+		messageID = latest
+	}
+	if messageID == 0 {
+		// Nothing received from this user yet
+		return nil
+	}
+
+	prev, err := queries.GetConversationRead(ctx, store.GetConversationReadParams{
+		UserID:      user.ID,
+		OtherUserID: otherUserID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to get conversation read state", "type", "request", "error", err)
+		return err
+	}
+
+	var next store.ConversationRead
+	if read {
+		next, err = queries.MarkConversationRead(ctx, store.MarkConversationReadParams{
+			UserID:                 user.ID,
+			OtherUserID:            otherUserID,
+			LastDeliveredMessageID: messageID,
+			LastReadMessageID:      messageID,
+		})
+	} else {
+		next, err = queries.MarkConversationDelivered(ctx, store.MarkConversationDeliveredParams{
+			UserID:                 user.ID,
+			OtherUserID:            otherUserID,
+			LastDeliveredMessageID: messageID,
+		})
+	}
+	if err != nil {
+		slog.Error("failed to update conversation read state", "type", "request", "error", err)
+		return err
+	}
+
+	deliveredMoved := next.LastDeliveredMessageID != prev.LastDeliveredMessageID
+	readMoved := next.LastReadMessageID != prev.LastReadMessageID
+	if !deliveredMoved && !readMoved {
+		return nil
+	}
+
+	payload := ReadPayload{
+		ReaderID:               user.ID,
+		OtherUserID:            otherUserID,
+		LastDeliveredMessageID: next.LastDeliveredMessageID,
+		LastReadMessageID:      next.LastReadMessageID,
+	}
+
+	// The reader's other devices update their unread counts
+	if readMoved {
+		hub.Publish(ctx, user.ID, &realtime.Message{Type: "read", Payload: payload})
+	}
+
+	// The sender's devices update their ticks, unless receipts are off
+	reader, err := queries.GetUserByID(ctx, user.ID)
+	if err != nil {
+		slog.Error("failed to get user", "type", "request", "error", err)
+		return err
+	}
+	if reader.ReadReceipts == 0 {
+		if !deliveredMoved {
+			return nil
+		}
+		payload.LastReadMessageID = 0
+	}
+	hub.Publish(ctx, otherUserID, &realtime.Message{Type: "read", Payload: payload})
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+// Maximum number of characters of the parent message quoted in a reply.
+const replySnippetLength = 100
+
+var errReplyNotFound = errors.New("message being replied to not found")
+
+// ReplyItem quotes the message a reply answers.
+type ReplyItem struct {
+	ID          int64  `json:"id"`
+	SenderName  string `json:"sender_name,omitempty"`
+	Snippet     string `json:"snippet,omitempty"`     // Start of the parent's content
+	Deleted     bool   `json:"deleted,omitempty"`     // The parent was deleted by its sender
+	Unavailable bool   `json:"unavailable,omitempty"` // The parent has expired or was sent before the reader joined
+}
+
+// newReplyItem builds the quote for a reply from its parent's columns, which
+// are all NULL when the parent no longer exists. Returns nil if replyToID is NULL.
+func newReplyItem(replyToID sql.NullInt64, content, deletedAt, senderName sql.NullString) *ReplyItem {
+	if !replyToID.Valid {
+		return nil
+	}
+
+	reply := &ReplyItem{ID: replyToID.Int64}
+	switch {
+	case !content.Valid:
+		reply.Unavailable = true
+	case deletedAt.Valid:
+		reply.SenderName = senderName.String
+		reply.Deleted = true
+	default:
+		reply.SenderName = senderName.String
+		reply.Snippet = replySnippet(content.String)
+	}
+	return reply
+}
+
+// replySnippet shortens a parent message for quoting.
+func replySnippet(content string) string {
+	if content == "" {
+		return attachmentPreview
+	}
+	if utf8.RuneCountInString(content) <= replySnippetLength {
+		return content
+	}
+	runes := []rune(content)
+	return string(runes[:replySnippetLength-3]) + "..."
+}
+
+// props converts the quote for rendering with partials.Message.
+func (r *ReplyItem) props() *partials.ReplyProps {
+	if r == nil {
+		return nil
+	}
+	return &partials.ReplyProps{
+		ID:          r.ID,
+		SenderName:  r.SenderName,
+		Snippet:     r.Snippet,
+		Deleted:     r.Deleted,
+		Unavailable: r.Unavailable,
+	}
+}
+
+// parseReplyToID reads the optional reply_to_id form field.
+// Missing or invalid values mean the message is not a reply.
+func parseReplyToID(value string) int64 {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// quoteParent loads the message user is replying to and builds its quote.
+// The parent must be visible to user and belong to the same conversation:
+// the direct conversation with otherUserID, or the group groupID.
+func quoteParent(ctx context.Context, queries *store.Queries, user *auth.User, replyToID, otherUserID, groupID int64) (store.Message, *ReplyItem, error) {
+	parent, err := getVisibleMessage(ctx, queries, user.ID, replyToID)
+	if err != nil {
+		if errors.Is(err, errMessageNotFound) {
+			return store.Message{}, nil, errReplyNotFound
+		}
+		return store.Message{}, nil, err
+	}
+
+	if groupID > 0 {
+		if parent.ConversationID.Int64 != groupID {
+			return store.Message{}, nil, errReplyNotFound
+		}
+	} else if parent.ConversationID.Valid || (parent.SenderID != otherUserID && parent.RecipientID.Int64 != otherUserID) {
+		return store.Message{}, nil, errReplyNotFound
+	}
+
+	senderName := user.DisplayName
+	if parent.SenderID != user.ID {
+		sender, err := queries.GetUserByID(ctx, parent.SenderID)
+		if err != nil {
+			return store.Message{}, nil, err
+		}
+		senderName = sender.DisplayName
+	}
+
+	reply := newReplyItem(
+		sql.NullInt64{Int64: parent.ID, Valid: true},
+		sql.NullString{String: parent.Content, Valid: true},
+		parent.DeletedAt,
+		sql.NullString{String: senderName, Valid: true},
+	)
+	return parent, reply, nil
+}
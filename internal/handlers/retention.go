@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/cleanup"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// RetentionDays is how long messages are kept in conversations without a
+// retention period of their own. Zero keeps them forever.
+// Set from the MESSAGE_RETENTION config at startup.
+var RetentionDays int64 = 30
+
+// maxRetentionDays is the longest retention period a conversation can set.
+const maxRetentionDays = 3650
+
+var errRetentionInvalid = errors.New("days must be a whole number from 1 to 3650, or blank for the default")
+
+// RetentionItem is a conversation's retention settings, for JSON API responses.
+type RetentionItem struct {
+	Days        int64 `json:"days"`         // Zero when the conversation uses DefaultDays
+	DefaultDays int64 `json:"default_days"` // Instance default; zero keeps messages forever
+	Keep        bool  `json:"keep"`         // On hold: nothing is deleted automatically
+}
+
+// ExpiredItem is the payload of an "expired" message, listing messages
+// removed from one conversation for being past its retention period or
+// disappearing-message timer.
+// Exactly one of UserID and ConversationID is set.
+type ExpiredItem struct {
+	UserID         int64   `json:"user_id,omitempty"` // Other participant of a direct conversation
+	ConversationID int64   `json:"conversation_id,omitempty"`
+	MessageIDs     []int64 `json:"message_ids"`
+}
+
+// HandleGetRetention returns the retention settings of the conversation with another user.
+// Route: GET /conversations/{userID}/retention
+func HandleGetRetention(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil || otherUserID == user.ID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, otherUserID); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		writeRetention(w, getDirectRetention(ctx, queries, user.ID, otherUserID))
+	}
+}
+
+// HandleSetRetention sets how long messages in the conversation with another user are kept.
+// Route: POST /conversations/{userID}/retention
+// Form field: days; blank returns the conversation to the instance default.
+func HandleSetRetention(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil || otherUserID == user.ID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, otherUserID); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		days, err := parseRetentionDays(r.FormValue("days"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		low, high := directPair(user.ID, otherUserID)
+		err = queries.SetDirectRetention(ctx, store.SetDirectRetentionParams{
+			UserID:        low,
+			OtherUserID:   high,
+			RetentionDays: days,
+		})
+		if err != nil {
+			slog.Error("failed to set retention", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("retention set", "type", "request", "user_id", user.ID, "other_user_id", otherUserID, "days", days.Int64)
+		writeRetention(w, getDirectRetention(ctx, queries, user.ID, otherUserID))
+	}
+}
+
+// HandleGetGroupRetention returns a group's retention settings.
+// Route: GET /groups/{groupID}/retention
+func HandleGetGroupRetention(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		writeRetention(w, getGroupRetention(ctx, queries, groupID))
+	}
+}
+
+// HandleSetGroupRetention sets how long a group's messages are kept.
+// Route: POST /groups/{groupID}/retention
+// Form field: days; blank returns the group to the instance default.
+func HandleSetGroupRetention(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		days, err := parseRetentionDays(r.FormValue("days"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = queries.SetGroupRetention(ctx, store.SetGroupRetentionParams{
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+			RetentionDays:  days,
+		})
+		if err != nil {
+			slog.Error("failed to set group retention", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("group retention set", "type", "request", "user_id", user.ID, "group_id", groupID, "days", days.Int64)
+		writeRetention(w, getGroupRetention(ctx, queries, groupID))
+	}
+}
+
+// HandleKeepMessage places a message on hold, or releases it.
+// Route: POST /admin/messages/{messageID}/keep
+// Form field: keep; "on" holds the message, anything else releases it.
+func HandleKeepMessage(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		keep := parseKeep(r)
+		result, err := queries.SetMessageKeep(ctx, store.SetMessageKeepParams{
+			Keep: keep,
+			ID:   messageID,
+		})
+		if err != nil {
+			slog.Error("failed to set message hold", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Info("message hold set", "type", "request", "user_id", user.ID, "message_id", messageID, "keep", keep != 0)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleKeepConversation places the direct conversation between two users on hold, or releases it.
+// Route: POST /admin/conversations/{userID}/{otherUserID}/keep
+// Form field: keep; "on" holds the conversation, anything else releases it.
+func HandleKeepConversation(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		userID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		otherUserID, err := strconv.ParseInt(r.PathValue("otherUserID"), 10, 64)
+		if err != nil || otherUserID == userID {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		for _, id := range []int64{userID, otherUserID} {
+			if _, err := queries.GetUserByID(ctx, id); err != nil {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		keep := parseKeep(r)
+		low, high := directPair(userID, otherUserID)
+		err = queries.SetDirectKeep(ctx, store.SetDirectKeepParams{
+			UserID:      low,
+			OtherUserID: high,
+			Keep:        keep,
+		})
+		if err != nil {
+			slog.Error("failed to set conversation hold", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("conversation hold set", "type", "request", "user_id", user.ID, "conversation", []int64{userID, otherUserID}, "keep", keep != 0)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleKeepGroup places a group's conversation on hold, or releases it.
+// Route: POST /admin/groups/{groupID}/keep
+// Form field: keep; "on" holds the group, anything else releases it.
+func HandleKeepGroup(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetConversation(ctx, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		keep := parseKeep(r)
+		err = queries.SetGroupKeep(ctx, store.SetGroupKeepParams{
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+			Keep:           keep,
+		})
+		if err != nil {
+			slog.Error("failed to set group hold", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("group hold set", "type", "request", "user_id", user.ID, "group_id", groupID, "keep", keep != 0)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// NotifyExpired returns the cleaner's ExpiredFunc.
+func NotifyExpired(queries *store.Queries, hub *realtime.Hub) cleanup.ExpiredFunc {
+	return func(ctx context.Context, expired []store.DeleteOldMessagesRow) {
+		msgs := make([]expiredMessage, len(expired))
+		for i, row := range expired {
+			msgs[i] = expiredMessage(row)
+		}
+		publishExpired(ctx, queries, hub, msgs)
+	}
+}
+
+// expiredMessage identifies a message deleted by the cleaner or the expiry worker.
+type expiredMessage struct {
+	ID             int64
+	SenderID       int64
+	RecipientID    sql.NullInt64
+	ConversationID sql.NullInt64
+}
+
+// publishExpired scrubs deleted messages from stored events and hub
+// notifications, which can outlive a short retention period, and sends each
+// participant an "expired" event per conversation that lost messages.
+func publishExpired(ctx context.Context, queries *store.Queries, hub *realtime.Hub, msgs []expiredMessage) {
+	direct := make(map[[2]int64][]int64)
+	groups := make(map[int64][]int64)
+	for _, msg := range msgs {
+		scrubMessageText(ctx, queries, msg.ID, "cleanup")
+
+		if msg.ConversationID.Valid {
+			groups[msg.ConversationID.Int64] = append(groups[msg.ConversationID.Int64], msg.ID)
+			continue
+		}
+		low, high := directPair(msg.SenderID, msg.RecipientID.Int64)
+		key := [2]int64{low.Int64, high.Int64}
+		direct[key] = append(direct[key], msg.ID)
+	}
+
+	for pair, ids := range direct {
+		hub.Publish(ctx, pair[0], &realtime.Message{Type: "expired", Payload: ExpiredItem{UserID: pair[1], MessageIDs: ids}})
+		hub.Publish(ctx, pair[1], &realtime.Message{Type: "expired", Payload: ExpiredItem{UserID: pair[0], MessageIDs: ids}})
+	}
+
+	for groupID, ids := range groups {
+		memberIDs, err := queries.ListConversationMemberIDs(ctx, groupID)
+		if err != nil {
+			slog.Error("failed to list group members", "type", "cleanup", "group_id", groupID, "error", err)
+			continue
+		}
+		for _, memberID := range memberIDs {
+			hub.Publish(ctx, memberID, &realtime.Message{Type: "expired", Payload: ExpiredItem{ConversationID: groupID, MessageIDs: ids}})
+		}
+	}
+}
+
+// getDirectRetention loads the retention settings of a direct conversation.
+// Conversations without settings use the defaults.
+func getDirectRetention(ctx context.Context, queries *store.Queries, userID, otherUserID int64) RetentionItem {
+	low, high := directPair(userID, otherUserID)
+	settings, err := queries.GetDirectSettings(ctx, store.GetDirectSettingsParams{
+		UserID:      low,
+		OtherUserID: high,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to get conversation settings", "type", "request", "error", err)
+	}
+	return RetentionItem{Days: settings.RetentionDays.Int64, DefaultDays: RetentionDays, Keep: settings.Keep != 0}
+}
+
+// getGroupRetention loads a group's retention settings.
+func getGroupRetention(ctx context.Context, queries *store.Queries, groupID int64) RetentionItem {
+	settings, err := queries.GetGroupSettings(ctx, sql.NullInt64{Int64: groupID, Valid: true})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to get group settings", "type", "request", "error", err)
+	}
+	return RetentionItem{Days: settings.RetentionDays.Int64, DefaultDays: RetentionDays, Keep: settings.Keep != 0}
+}
+
+// writeRetention writes retention settings as JSON.
+func writeRetention(w http.ResponseWriter, item RetentionItem) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		slog.Error("failed to encode retention", "type", "request", "error", err)
+	}
+}
+
+// parseRetentionDays parses a days form value. Blank means no override.
+func parseRetentionDays(value string) (sql.NullInt64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return sql.NullInt64{}, nil
+	}
+	days, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || days < 1 || days > maxRetentionDays {
+		return sql.NullInt64{}, errRetentionInvalid
+	}
+	return sql.NullInt64{Int64: days, Valid: true}, nil
+}
+
+// parseKeep reads the keep form field as the stored flag.
+func parseKeep(r *http.Request) int64 {
+	if r.FormValue("keep") == "on" {
+		return 1
+	}
+	return 0
+}
+
+// directPair orders two user IDs the way conversation settings store them.
+func directPair(a, b int64) (low, high sql.NullInt64) {
+	if a > b {
+		a, b = b, a
+	}
+	return sql.NullInt64{Int64: a, Valid: true}, sql.NullInt64{Int64: b, Valid: true}
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/scheduler"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/validate"
+)
+
+// maxScheduleAhead is how far in the future a message can be scheduled.
+const maxScheduleAhead = 366 * 24 * time.Hour
+
+// ScheduledItem is a message waiting to be sent, for JSON API responses.
+// Exactly one of RecipientID and ConversationID is set.
+type ScheduledItem struct {
+	ID             int64  `json:"id"`
+	RecipientID    int64  `json:"recipient_id,omitempty"`
+	ConversationID int64  `json:"conversation_id,omitempty"`
+	Content        string `json:"content"`
+	SendAt         string `json:"send_at"` // RFC 3339, in UTC
+	CreatedAt      string `json:"created_at"`
+	FailedAt       string `json:"failed_at,omitempty"` // Set if the message couldn't be sent
+	Failure        string `json:"failure,omitempty"`   // Why it couldn't be sent
+}
+
+var (
+	errSendAtInvalid = errors.New("send_at must be an RFC 3339 time")
+	errSendAtPast    = errors.New("send_at must be in the future")
+	errSendAtTooFar  = errors.New("send_at must be within a year")
+)
+
+// HandleScheduleMessage queues a message to another user for sending later.
+// Route: POST /conversations/{userID}/scheduled
+// Form fields: content, send_at.
+func HandleScheduleMessage(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		recipientID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Prevent messaging self
+		if recipientID == user.ID {
+			http.Error(w, "Cannot message yourself", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := queries.GetUserByID(ctx, recipientID); err != nil {
+			http.Error(w, "Recipient not found", http.StatusNotFound)
+			return
+		}
+
+		scheduleMessage(w, r, queries, store.CreateScheduledMessageParams{
+			SenderID:    user.ID,
+			RecipientID: sql.NullInt64{Int64: recipientID, Valid: true},
+		})
+	}
+}
+
+// HandleScheduleGroupMessage queues a message to a group for sending later.
+// Route: POST /groups/{groupID}/scheduled
+// Form fields: content, send_at.
+func HandleScheduleGroupMessage(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := getGroup(ctx, queries, user.ID, groupID); err != nil {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+
+		scheduleMessage(w, r, queries, store.CreateScheduledMessageParams{
+			SenderID:       user.ID,
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+		})
+	}
+}
+
+// scheduleMessage validates the content and send_at form fields and stores
+// a scheduled message to the destination in params.
+func scheduleMessage(w http.ResponseWriter, r *http.Request, queries *store.Queries, params store.CreateScheduledMessageParams) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	params.Content = strings.TrimSpace(r.FormValue("content"))
+	if err := validate.Message(params.Content); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sendAt, err := parseSendAt(r.FormValue("send_at"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params.SendAt = sendAt
+
+	scheduled, err := queries.CreateScheduledMessage(ctx, params)
+	if err != nil {
+		slog.Error("failed to schedule message", "type", "request", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("message scheduled", "type", "request", "user_id", params.SenderID, "scheduled_id", scheduled.ID, "send_at", scheduled.SendAt)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newScheduledItem(scheduled)); err != nil {
+		slog.Error("failed to encode scheduled message", "type", "request", "error", err)
+	}
+}
+
+// HandleListScheduled returns the current user's pending scheduled messages, soonest first.
+// Route: GET /scheduled
+func HandleListScheduled(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		rows, err := queries.ListScheduledMessages(ctx, user.ID)
+		if err != nil {
+			slog.Error("failed to list scheduled messages", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		scheduled := make([]ScheduledItem, len(rows))
+		for i, row := range rows {
+			scheduled[i] = newScheduledItem(row)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(scheduled); err != nil {
+			slog.Error("failed to encode scheduled messages", "type", "request", "error", err)
+		}
+	}
+}
+
+// HandleEditScheduled changes the content or send time of a pending scheduled message.
+// Route: POST /scheduled/{scheduledID}
+// Form fields: content, send_at; either may be left out to keep its current value.
+func HandleEditScheduled(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		scheduledID, err := strconv.ParseInt(r.PathValue("scheduledID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid scheduled message ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		scheduled, err := queries.GetScheduledMessage(ctx, store.GetScheduledMessageParams{
+			ID:       scheduledID,
+			SenderID: user.ID,
+		})
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				slog.Error("failed to get scheduled message", "type", "request", "error", err)
+			}
+			http.Error(w, "Scheduled message not found", http.StatusNotFound)
+			return
+		}
+
+		params := store.UpdateScheduledMessageParams{
+			Content:  scheduled.Content,
+			SendAt:   scheduled.SendAt,
+			ID:       scheduled.ID,
+			SenderID: user.ID,
+		}
+		if _, ok := r.Form["content"]; ok {
+			params.Content = strings.TrimSpace(r.FormValue("content"))
+			if err := validate.Message(params.Content); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if _, ok := r.Form["send_at"]; ok {
+			params.SendAt, err = parseSendAt(r.FormValue("send_at"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// The scheduler may have claimed the message since it was loaded
+		scheduled, err = queries.UpdateScheduledMessage(ctx, params)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Scheduled message not found", http.StatusNotFound)
+				return
+			}
+			slog.Error("failed to update scheduled message", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("scheduled message edited", "type", "request", "user_id", user.ID, "scheduled_id", scheduled.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(newScheduledItem(scheduled)); err != nil {
+			slog.Error("failed to encode scheduled message", "type", "request", "error", err)
+		}
+	}
+}
+
+// HandleCancelScheduled deletes one of the current user's pending scheduled messages.
+// Route: DELETE /scheduled/{scheduledID}
+func HandleCancelScheduled(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		scheduledID, err := strconv.ParseInt(r.PathValue("scheduledID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid scheduled message ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := queries.DeleteScheduledMessage(ctx, store.DeleteScheduledMessageParams{
+			ID:       scheduledID,
+			SenderID: user.ID,
+		})
+		if err != nil {
+			slog.Error("failed to cancel scheduled message", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if count, _ := result.RowsAffected(); count == 0 {
+			http.Error(w, "Scheduled message not found", http.StatusNotFound)
+			return
+		}
+
+		slog.Info("scheduled message cancelled", "type", "request", "user_id", user.ID, "scheduled_id", scheduledID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SendScheduled returns the scheduler's SendFunc. It sends a due message as
+// its sender would have, storing it and broadcasting it through the hub.
+// The message's client ID comes from its scheduled ID, so sending it again
+// returns the stored message.
+func SendScheduled(queries *store.Queries, hub *realtime.Hub) scheduler.SendFunc {
+	return func(ctx context.Context, msg store.ScheduledMessage) error {
+		sender, err := queries.GetUserByID(ctx, msg.SenderID)
+		if err != nil {
+			return err
+		}
+		user := &auth.User{
+			ID:          sender.ID,
+			Username:    sender.Username,
+			DisplayName: sender.DisplayName,
+			IsAdmin:     sender.IsAdmin != 0,
+		}
+
+		clientID := fmt.Sprintf("%s%d", scheduledClientIDPrefix, msg.ID)
+		if msg.ConversationID.Valid {
+			_, err = sendGroupMessage(ctx, queries, hub, user, msg.ConversationID.Int64, msg.Content, 0, clientID, nil)
+		} else {
+			_, err = sendMessage(ctx, queries, hub, user, msg.RecipientID.Int64, msg.Content, 0, clientID, nil)
+		}
+		if errors.Is(err, errGroupNotFound) || errors.Is(err, errRecipientNotFound) || isSendValidationError(err) {
+			return fmt.Errorf("%w: %w", scheduler.ErrUndeliverable, err)
+		}
+		return err
+	}
+}
+
+// parseSendAt parses an RFC 3339 send_at value into the database's UTC
+// timestamp format, checking that it is in the future but not too far.
+func parseSendAt(value string) (string, error) {
+	sendAt, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return "", errSendAtInvalid
+	}
+	now := time.Now()
+	if !sendAt.After(now) {
+		return "", errSendAtPast
+	}
+	if sendAt.Sub(now) > maxScheduleAhead {
+		return "", errSendAtTooFar
+	}
+	return sendAt.UTC().Format(timeFormat), nil
+}
+
+// newScheduledItem converts a stored scheduled message for JSON responses.
+func newScheduledItem(msg store.ScheduledMessage) ScheduledItem {
+	item := ScheduledItem{
+		ID:             msg.ID,
+		RecipientID:    msg.RecipientID.Int64,
+		ConversationID: msg.ConversationID.Int64,
+		Content:        msg.Content,
+		SendAt:         msg.SendAt,
+		CreatedAt:      msg.CreatedAt,
+		FailedAt:       msg.FailedAt.String,
+		Failure:        msg.Failure.String,
+	}
+	if sendAt, err := time.Parse(timeFormat, msg.SendAt); err == nil {
+		item.SendAt = sendAt.Format(time.RFC3339)
+	}
+	return item
+}
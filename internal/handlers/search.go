@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/store"
+	"github.com/dukerupert/wantok/internal/views/partials"
+)
+
+const (
+	// Maximum number of messages returned by a search.
+	searchLimit = 50
+	// Words after this many are ignored.
+	maxSearchWords = 10
+
+	// How far back the chat page will load to show a linked message.
+	maxJumpPageSize = 1000
+)
+
+// SearchResult is a message matching a search.
+type SearchResult struct {
+	MessageID        int64  `json:"message_id"`
+	SenderID         int64  `json:"sender_id"`
+	SenderName       string `json:"sender_name"`
+	CreatedAt        string `json:"created_at"`
+	UserID           int64  `json:"user_id,omitempty"`  // The other participant of a direct message
+	GroupID          int64  `json:"group_id,omitempty"` // Set for group messages
+	ConversationName string `json:"conversation_name"`  // The other user's display name, or the group's name
+	Snippet          string `json:"snippet"`            // HTML-escaped, with matches wrapped in <mark>
+	URL              string `json:"url"`                // Opens the chat page at the message
+
+	parts []partials.SnippetPart // Snippet before rendering, for the sidebar
+}
+
+// HandleSearch finds messages containing every word of the query in
+// conversations the current user takes part in, newest first.
+// Route: GET /search?q=
+// HTMX requests get the results rendered for the sidebar.
+func HandleSearch(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		results, err := searchMessages(ctx, queries, user.ID, query)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if r.Header.Get("HX-Request") == "true" {
+			props := make([]partials.SearchResultProps, len(results))
+			for i, result := range results {
+				props[i] = partials.SearchResultProps{
+					URL:              result.URL,
+					ConversationName: result.ConversationName,
+					SenderName:       result.SenderName,
+					CreatedAt:        result.CreatedAt,
+					Snippet:          result.parts,
+				}
+			}
+			if err := partials.SearchResults(query, props).Render(ctx, w); err != nil {
+				slog.Error("failed to render search results", "type", "request", "error", err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			slog.Error("failed to encode search results", "type", "request", "error", err)
+		}
+	}
+}
+
+// searchMessages runs a search for userID. An empty query matches nothing.
+func searchMessages(ctx context.Context, queries *store.Queries, userID int64, query string) ([]SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return []SearchResult{}, nil
+	}
+
+	rows, err := queries.SearchMessages(ctx, store.SearchMessagesParams{
+		UserID: userID,
+		Query:  match,
+		Limit:  searchLimit,
+	})
+	if err != nil {
+		slog.Error("failed to search messages", "type", "request", "error", err)
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(rows))
+	for i, row := range rows {
+		parts := snippetParts(row.Snippet)
+		results[i] = SearchResult{
+			MessageID:  row.ID,
+			SenderID:   row.SenderID,
+			SenderName: row.SenderDisplayName,
+			CreatedAt:  row.CreatedAt,
+			Snippet:    snippetHTML(parts),
+			parts:      parts,
+		}
+		if row.ConversationID.Valid {
+			results[i].GroupID = row.ConversationID.Int64
+			results[i].ConversationName = row.GroupName.String
+			results[i].URL = fmt.Sprintf("/?group=%d&message=%d", row.ConversationID.Int64, row.ID)
+		} else {
+			otherUserID := row.RecipientID.Int64
+			if row.SenderID != userID {
+				otherUserID = row.SenderID
+			}
+			results[i].UserID = otherUserID
+			results[i].ConversationName = row.OtherUserDisplayName.String
+			results[i].URL = fmt.Sprintf("/?user=%d&message=%d", otherUserID, row.ID)
+		}
+	}
+	return results, nil
+}
+
+// ftsQuery turns a search typed by a user into an FTS5 query for messages
+// containing every word, treating the last word as a prefix so results show
+// up while typing. Words are quoted, so FTS5 syntax in them is matched literally.
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	if len(words) > maxSearchWords {
+		words = words[:maxSearchWords]
+	}
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+// snippetParts splits a snippet from SearchMessages, whose matches are
+// wrapped in the control characters 0x02 and 0x03, into plain and matched text.
+func snippetParts(snippet string) []partials.SnippetPart {
+	var parts []partials.SnippetPart
+	match := false
+	for snippet != "" {
+		end := strings.IndexAny(snippet, "\x02\x03")
+		if end < 0 {
+			end = len(snippet)
+		}
+		if end > 0 {
+			parts = append(parts, partials.SnippetPart{Text: snippet[:end], Match: match})
+		}
+		if end == len(snippet) {
+			break
+		}
+		match = snippet[end] == '\x02'
+		snippet = snippet[end+1:]
+	}
+	return parts
+}
+
+// snippetHTML renders snippet parts as HTML, wrapping matches in <mark>.
+func snippetHTML(parts []partials.SnippetPart) string {
+	var b strings.Builder
+	for _, part := range parts {
+		if part.Match {
+			b.WriteString("<mark>" + html.EscapeString(part.Text) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(part.Text))
+		}
+	}
+	return b.String()
+}
+
+// pageSize returns how many of the newest messages the chat page should load
+// so that focusID, a message linked from search, is among them. The direct
+// conversation with otherUserID is used unless groupID is set.
+func pageSize(ctx context.Context, queries *store.Queries, userID, otherUserID, groupID, focusID int64) int64 {
+	if focusID <= 0 {
+		return messagePageSize
+	}
+
+	var count int64
+	var err error
+	if groupID > 0 {
+		count, err = queries.CountGroupMessagesSince(ctx, store.CountGroupMessagesSinceParams{
+			ConversationID: sql.NullInt64{Int64: groupID, Valid: true},
+			MessageID:      focusID,
+		})
+	} else {
+		count, err = queries.CountConversationMessagesSince(ctx, store.CountConversationMessagesSinceParams{
+			UserID:      userID,
+			OtherUserID: sql.NullInt64{Int64: otherUserID, Valid: true},
+			MessageID:   focusID,
+		})
+	}
+	if err != nil {
+		slog.Error("failed to count messages", "type", "request", "error", err)
+		return messagePageSize
+	}
+
+	// A few older messages give the linked one some context
+	return min(max(count+10, messagePageSize), maxJumpPageSize)
+}
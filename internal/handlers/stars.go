@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/markup"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// StarItem is the payload of "starred" events, sent to the user's own
+// devices when they star or unstar a message. Stars are private.
+type StarItem struct {
+	ID      int64 `json:"id"`
+	Starred bool  `json:"starred"`
+}
+
+// StarredMessage is a message the current user has starred.
+type StarredMessage struct {
+	MessageID        int64  `json:"message_id"`
+	SenderID         int64  `json:"sender_id"`
+	SenderName       string `json:"sender_name"`
+	Content          string `json:"content"`
+	ContentHTML      string `json:"content_html"` // Content formatted for display; safe to insert as HTML
+	CreatedAt        string `json:"created_at"`
+	UserID           int64  `json:"user_id,omitempty"`  // The other participant of a direct message
+	GroupID          int64  `json:"group_id,omitempty"` // Set for group messages
+	ConversationName string `json:"conversation_name"`  // The other user's display name, or the group's name
+	StarredAt        string `json:"starred_at"`
+	URL              string `json:"url"` // Opens the chat page at the message
+}
+
+// HandleStarMessage stars a message for the current user only.
+// Route: POST /messages/{messageID}/star
+func HandleStarMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return handleStar(queries, hub, true)
+}
+
+// HandleUnstarMessage removes the current user's star from a message.
+// Route: DELETE /messages/{messageID}/star
+func HandleUnstarMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return handleStar(queries, hub, false)
+}
+
+func handleStar(queries *store.Queries, hub *realtime.Hub, starred bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		response, err := setStar(ctx, queries, hub, user, messageID, starred)
+		if err != nil {
+			switch {
+			case errors.Is(err, errMessageNotFound):
+				http.Error(w, "Message not found", http.StatusNotFound)
+			case errors.Is(err, errMessageDeleted):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, "Failed to update star", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.Error("failed to encode star", "type", "request", "error", err)
+		}
+	}
+}
+
+// setStar stars or unstars a message for user and tells their other devices.
+// Only messages user can see may be starred, but a star can always be removed.
+func setStar(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, messageID int64, starred bool) (StarItem, error) {
+	if starred {
+		msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+		if err != nil {
+			return StarItem{}, err
+		}
+		if msg.DeletedAt.Valid {
+			return StarItem{}, errMessageDeleted
+		}
+		if err := queries.StarMessage(ctx, store.StarMessageParams{UserID: user.ID, MessageID: msg.ID}); err != nil {
+			slog.Error("failed to star message", "type", "request", "error", err)
+			return StarItem{}, err
+		}
+	} else {
+		if err := queries.UnstarMessage(ctx, store.UnstarMessageParams{UserID: user.ID, MessageID: messageID}); err != nil {
+			slog.Error("failed to unstar message", "type", "request", "error", err)
+			return StarItem{}, err
+		}
+	}
+
+	item := StarItem{ID: messageID, Starred: starred}
+	hub.Publish(ctx, user.ID, &realtime.Message{Type: "starred", Payload: item})
+	return item, nil
+}
+
+// HandleListStarred returns the messages the current user has starred, most
+// recently starred first. Deleted messages are left out.
+// Route: GET /starred
+func HandleListStarred(queries *store.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		rows, err := queries.ListStarredMessages(ctx, user.ID)
+		if err != nil {
+			slog.Error("failed to list starred messages", "type", "request", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		starred := make([]StarredMessage, len(rows))
+		for i, row := range rows {
+			starred[i] = StarredMessage{
+				MessageID:   row.ID,
+				SenderID:    row.SenderID,
+				SenderName:  row.SenderDisplayName,
+				Content:     row.Content,
+				ContentHTML: markup.Render(row.Content),
+				CreatedAt:   row.CreatedAt,
+				StarredAt:   row.StarredAt,
+			}
+			if row.ConversationID.Valid {
+				starred[i].GroupID = row.ConversationID.Int64
+				starred[i].ConversationName = row.GroupName.String
+				starred[i].URL = fmt.Sprintf("/?group=%d&message=%d", row.ConversationID.Int64, row.ID)
+			} else {
+				otherUserID := row.RecipientID.Int64
+				if row.SenderID != user.ID {
+					otherUserID = row.SenderID
+				}
+				starred[i].UserID = otherUserID
+				starred[i].ConversationName = row.OtherUserDisplayName.String
+				starred[i].URL = fmt.Sprintf("/?user=%d&message=%d", otherUserID, row.ID)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(starred); err != nil {
+			slog.Error("failed to encode starred messages", "type", "request", "error", err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// deletedPreview stands in for a deleted message in conversation lists.
+const deletedPreview = "Message deleted"
+
+var errDeleteNotAllowed = errors.New("only the sender can delete a message")
+
+// HandleDeleteMessage deletes one of the current user's direct messages for everyone.
+// Route: DELETE /conversations/{userID}/messages/{messageID}
+func HandleDeleteMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		otherUserID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		// The message must belong to this conversation
+		msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+		if err != nil || msg.ConversationID.Valid ||
+			(msg.SenderID != otherUserID && msg.RecipientID.Int64 != otherUserID) {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+
+		writeDeleteResult(w, deleteMessage(ctx, queries, hub, user, msg))
+	}
+}
+
+// HandleDeleteGroupMessage deletes one of the current user's group messages for everyone.
+// Route: DELETE /groups/{groupID}/messages/{messageID}
+func HandleDeleteGroupMessage(queries *store.Queries, hub *realtime.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		user := auth.GetUser(ctx)
+
+		groupID, err := strconv.ParseInt(r.PathValue("groupID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+
+		messageID, err := strconv.ParseInt(r.PathValue("messageID"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid message ID", http.StatusBadRequest)
+			return
+		}
+
+		msg, err := getVisibleMessage(ctx, queries, user.ID, messageID)
+		if err != nil || msg.ConversationID.Int64 != groupID {
+			http.Error(w, "Message not found", http.StatusNotFound)
+			return
+		}
+
+		writeDeleteResult(w, deleteMessage(ctx, queries, hub, user, msg))
+	}
+}
+
+// writeDeleteResult maps the result of deleteMessage to a response.
+func writeDeleteResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, errDeleteNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "Failed to delete message", http.StatusInternalServerError)
+	}
+}
+
+// deleteMessage replaces a message sent by user with a tombstone and sends a
+// "deleted" event to everyone who can see it. Its reactions, pin, attachment
+// and edit history are dropped, and its text is scrubbed from stored events,
+// including quotes in replies. Deleting a tombstone again is a no-op.
+func deleteMessage(ctx context.Context, queries *store.Queries, hub *realtime.Hub, user *auth.User, msg store.Message) error {
+	if msg.SenderID != user.ID {
+		return errDeleteNotAllowed
+	}
+	if msg.DeletedAt.Valid {
+		return nil
+	}
+
+	msg, err := queries.DeleteMessageContent(ctx, msg.ID)
+	if err != nil {
+		slog.Error("failed to delete message", "type", "request", "error", err)
+		return err
+	}
+
+	// The tombstone is in place; failing to scrub copies shouldn't undo it
+	if err := queries.DeleteMessageRevisions(ctx, msg.ID); err != nil {
+		slog.Error("failed to delete message revisions", "type", "request", "message_id", msg.ID, "error", err)
+	}
+	if err := queries.DeleteMessageReactions(ctx, msg.ID); err != nil {
+		slog.Error("failed to delete message reactions", "type", "request", "message_id", msg.ID, "error", err)
+	}
+	if _, err := queries.UnpinMessage(ctx, msg.ID); err != nil {
+		slog.Error("failed to unpin message", "type", "request", "message_id", msg.ID, "error", err)
+	}
+	// The file itself is removed by the cleaner once nothing refers to it
+	if err := queries.DeleteMessageAttachment(ctx, msg.ID); err != nil {
+		slog.Error("failed to delete message attachment", "type", "request", "message_id", msg.ID, "error", err)
+	}
+	scrubMessageText(ctx, queries, msg.ID, "request")
+
+	slog.Info("message deleted", "type", "request", "user_id", user.ID, "message_id", msg.ID)
+
+	item := MessageItem{
+		ID:             msg.ID,
+		SenderID:       msg.SenderID,
+		SenderName:     user.DisplayName,
+		CreatedAt:      msg.CreatedAt,
+		ConversationID: msg.ConversationID.Int64,
+		Deleted:        true,
+	}
+
+	viewers, err := messageViewerIDs(ctx, queries, msg)
+	if err != nil {
+		slog.Error("failed to list message viewers", "type", "request", "message_id", msg.ID, "error", err)
+		return nil
+	}
+	for _, viewerID := range viewers {
+		viewerItem := item
+		viewerItem.IsSent = viewerID == user.ID
+		hub.Publish(ctx, viewerID, &realtime.Message{Type: "deleted", Payload: viewerItem})
+	}
+	return nil
+}
+
+// scrubMessageText removes the text of a deleted message from stored events
+// and hub notifications, including quotes of it in replies. Failures are
+// logged with logType, since the message itself is already gone.
+func scrubMessageText(ctx context.Context, queries *store.Queries, messageID int64, logType string) {
+	if err := queries.ScrubMessageEvents(ctx, messageID); err != nil {
+		slog.Error("failed to scrub message events", "type", logType, "message_id", messageID, "error", err)
+	}
+	if err := queries.ScrubMessageNotifications(ctx, messageID); err != nil {
+		slog.Error("failed to scrub hub notifications", "type", logType, "message_id", messageID, "error", err)
+	}
+	if err := queries.ScrubReplyEvents(ctx, messageID); err != nil {
+		slog.Error("failed to scrub reply events", "type", logType, "message_id", messageID, "error", err)
+	}
+	if err := queries.ScrubReplyNotifications(ctx, messageID); err != nil {
+		slog.Error("failed to scrub reply notifications", "type", logType, "message_id", messageID, "error", err)
+	}
+}
+
+// messagePreview shortens message content for conversation lists.
+// Messages with only an attachment have empty content.
+func messagePreview(content string, deleted bool) string {
+	if deleted {
+		return deletedPreview
+	}
+	if content == "" {
+		return attachmentPreview
+	}
+	if len(content) > 50 {
+		return content[:47] + "..."
+	}
+	return content
+}
@@ -10,7 +10,7 @@ import (
 )
 
 // HandleListUsers returns all users except the current user.
-// Used for starting new conversations.
+// Used for starting new conversations, or with ?select=members for choosing group members.
 func HandleListUsers(queries *store.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -32,7 +32,11 @@ func HandleListUsers(queries *store.Queries) http.HandlerFunc {
 			}
 		}
 
-		if err := partials.UserList(userList).Render(ctx, w); err != nil {
+		component := partials.UserList(userList)
+		if r.URL.Query().Get("select") == "members" {
+			component = partials.MemberPicker(userList)
+		}
+		if err := component.Render(ctx, w); err != nil {
 			slog.Error("failed to render user list", "type", "request", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/dukerupert/wantok/internal/auth"
+	"github.com/dukerupert/wantok/internal/presence"
 	"github.com/dukerupert/wantok/internal/realtime"
 	"github.com/dukerupert/wantok/internal/store"
 	"github.com/gorilla/websocket"
@@ -21,7 +25,7 @@ var upgrader = websocket.Upgrader{
 
 // HandleWebSocket upgrades HTTP to WebSocket and registers the client.
 // Route: GET /ws
-func HandleWebSocket(hub *realtime.Hub, queries *store.Queries) http.HandlerFunc {
+func HandleWebSocket(hub *realtime.Hub, queries *store.Queries, tracker *presence.Tracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		user := auth.GetUser(ctx)
@@ -36,10 +40,164 @@ func HandleWebSocket(hub *realtime.Hub, queries *store.Queries) http.HandlerFunc
 			return
 		}
 
-		client := realtime.NewClient(hub, conn, user.ID, user.DisplayName)
+		client := realtime.NewClient(hub, conn, user.ID, user.DisplayName, handleInbound(ctx, queries, hub, tracker, user))
 		hub.Register(client)
+		tracker.Connected(client)
 
 		go client.WritePump()
 		client.ReadPump() // Blocks until disconnect
+		tracker.Disconnected(client)
 	}
 }
+
+// sendFrame is the payload of an inbound "send" frame.
+// Set RecipientID for a direct message or ConversationID for a group.
+type sendFrame struct {
+	RecipientID    int64  `json:"recipient_id"`
+	ConversationID int64  `json:"conversation_id"`
+	Content        string `json:"content"`
+	ReplyToID      int64  `json:"reply_to_id"` // Message being replied to, if any
+	ClientID       string `json:"client_id"`   // Resending with the same ID returns the original message
+}
+
+// typingFrame is the payload of an inbound "typing" frame.
+type typingFrame struct {
+	RecipientID int64 `json:"recipient_id"`
+	Typing      bool  `json:"typing"`
+}
+
+// receiptFrame is the payload of inbound "delivered" and "read" frames.
+// Groups only track reads, identified by ConversationID instead of UserID.
+type receiptFrame struct {
+	UserID         int64 `json:"user_id"`         // Sender of the messages being acknowledged
+	ConversationID int64 `json:"conversation_id"` // Group whose messages were read
+	MessageID      int64 `json:"message_id"`      // Newest message received or read
+}
+
+// heartbeatFrame is the payload of an inbound "heartbeat" frame.
+type heartbeatFrame struct {
+	Active bool `json:"active"`
+}
+
+// handleInbound returns the handler for frames sent by a user's client.
+// Send frames are answered with an "ack" or "error" carrying the frame's correlation ID.
+func handleInbound(ctx context.Context, queries *store.Queries, hub *realtime.Hub, tracker *presence.Tracker, user *auth.User) realtime.InboundHandler {
+	return func(c *realtime.Client, in *realtime.Inbound) {
+		switch in.Type {
+		case "send":
+			var frame sendFrame
+			if err := json.Unmarshal(in.Payload, &frame); err != nil {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+
+			if err := validateClientID(frame.ClientID); err != nil {
+				replyError(c, in.ID, err.Error())
+				return
+			}
+
+			var msg MessageItem
+			var err error
+			if frame.ConversationID > 0 {
+				msg, err = sendGroupMessage(ctx, queries, hub, user, frame.ConversationID, frame.Content, frame.ReplyToID, frame.ClientID, nil)
+			} else {
+				msg, err = sendMessage(ctx, queries, hub, user, frame.RecipientID, frame.Content, frame.ReplyToID, frame.ClientID, nil)
+			}
+			if err != nil {
+				switch {
+				case isSendValidationError(err), errors.Is(err, errRecipientNotFound), errors.Is(err, errGroupNotFound):
+					replyError(c, in.ID, err.Error())
+				default:
+					replyError(c, in.ID, "failed to send message")
+				}
+				return
+			}
+			if frame.ConversationID > 0 {
+				clearDraft(ctx, queries, hub, user, 0, frame.ConversationID)
+			} else {
+				clearDraft(ctx, queries, hub, user, frame.RecipientID, 0)
+			}
+			c.SendMessage(&realtime.Message{Type: "ack", ID: in.ID, Payload: msg})
+
+		case "typing":
+			// Fire-and-forget: only invalid frames get a reply
+			var frame typingFrame
+			if err := json.Unmarshal(in.Payload, &frame); err != nil || frame.RecipientID == user.ID {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			// Only people the user already talks to can see them typing. Unknown
+			// users get the same reply, so the frame can't probe for user IDs.
+			shared, err := queries.SharesConversation(ctx, store.SharesConversationParams{
+				UserID:      user.ID,
+				OtherUserID: frame.RecipientID,
+			})
+			if err != nil {
+				slog.Error("failed to check conversation", "type", "request", "error", err)
+			}
+			if shared == 0 {
+				replyError(c, in.ID, errRecipientNotFound.Error())
+				return
+			}
+			if frame.Typing {
+				hub.StartTyping(user.ID, frame.RecipientID)
+			} else {
+				hub.StopTyping(user.ID, frame.RecipientID)
+			}
+
+		case "resume":
+			var frame realtime.ResumePayload
+			if err := json.Unmarshal(in.Payload, &frame); err != nil || frame.LastSeq < 0 {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			hub.Resume(ctx, c, frame.LastSeq)
+
+		case "ack":
+			var frame realtime.AckPayload
+			if err := json.Unmarshal(in.Payload, &frame); err != nil {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			hub.Ack(c, frame.Seq)
+
+		case "delivered", "read":
+			var frame receiptFrame
+			if err := json.Unmarshal(in.Payload, &frame); err != nil || frame.MessageID <= 0 {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			if frame.ConversationID > 0 {
+				if in.Type == "read" {
+					if err := markGroupRead(ctx, queries, user, frame.ConversationID, frame.MessageID); err != nil {
+						replyError(c, in.ID, "failed to update read state")
+					}
+				}
+				return
+			}
+			if frame.UserID == user.ID {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			if err := markConversation(ctx, queries, hub, user, frame.UserID, frame.MessageID, in.Type == "read"); err != nil {
+				replyError(c, in.ID, "failed to update read state")
+			}
+
+		case "heartbeat":
+			var frame heartbeatFrame
+			if err := json.Unmarshal(in.Payload, &frame); err != nil {
+				replyError(c, in.ID, "invalid payload")
+				return
+			}
+			tracker.Heartbeat(c, frame.Active)
+
+		default:
+			replyError(c, in.ID, "unknown frame type")
+		}
+	}
+}
+
+// replyError sends an "error" frame for the inbound frame with the given correlation ID.
+func replyError(c *realtime.Client, id, message string) {
+	c.SendMessage(&realtime.Message{Type: "error", ID: id, Payload: realtime.ErrorPayload{Error: message}})
+}
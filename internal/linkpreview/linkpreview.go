@@ -0,0 +1,280 @@
+// Package linkpreview fetches the Open Graph and Twitter card metadata of
+// links shared in messages. Requests are bounded in time and size and may
+// only reach public addresses, so a message can't be used to probe the
+// server's own network.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// Timeout bounds a whole fetch, including redirects and reading the body.
+	Timeout = 5 * time.Second
+
+	// MaxBytes is how much of a page is read looking for metadata.
+	MaxBytes = 512 << 10
+
+	// maxRedirects is how many redirects a fetch follows.
+	maxRedirects = 3
+
+	// Longest title and description kept, in characters.
+	maxTitleLength       = 200
+	maxDescriptionLength = 300
+
+	userAgent = "Mozilla/5.0 (compatible; WantokBot/1.0; +link preview)"
+)
+
+var (
+	// ErrBlocked is returned for links that resolve to a private, loopback
+	// or otherwise non-public address.
+	ErrBlocked = errors.New("address is not public")
+
+	// ErrNoPreview is returned for pages that can't be previewed: error
+	// responses, non-HTML content and pages without a title.
+	ErrNoPreview = errors.New("no preview available")
+)
+
+// Preview is the metadata shown for a link.
+type Preview struct {
+	URL         string // The link as it appeared in the message
+	Title       string
+	Description string
+	ImageURL    string // Absolute http or https URL
+	SiteName    string
+}
+
+// Fetcher fetches link previews.
+type Fetcher struct {
+	client *http.Client
+
+	// allowed reports whether a connection may be made to addr.
+	// Only public addresses are allowed outside of tests.
+	allowed func(addr netip.Addr) bool
+}
+
+// New creates a Fetcher that only connects to public addresses.
+func New() *Fetcher {
+	f := &Fetcher{allowed: isPublic}
+
+	dialer := &net.Dialer{
+		Timeout: Timeout,
+		// Checked against the resolved address, so a hostname can't
+		// point somewhere else between the check and the connection
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !f.allowed(addr.Unmap()) {
+				return ErrBlocked
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: Timeout,
+		Transport: &http.Transport{
+			Proxy:                  nil, // Connect directly so the address check sees the real destination
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    Timeout,
+			ResponseHeaderTimeout:  Timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+			DisableKeepAlives:      true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return ErrNoPreview
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrNoPreview
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// Fetch downloads the page at link and extracts its preview.
+func (f *Fetcher) Fetch(ctx context.Context, link string) (Preview, error) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Preview{}, ErrNoPreview
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlocked) {
+			return Preview{}, ErrBlocked
+		}
+		if errors.Is(err, ErrNoPreview) {
+			return Preview{}, ErrNoPreview
+		}
+		return Preview{}, fmt.Errorf("fetch %s: %w", u.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, ErrNoPreview
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNoPreview
+	}
+
+	preview := parse(io.LimitReader(resp.Body, MaxBytes), resp.Request.URL)
+	if preview.Title == "" {
+		return Preview{}, ErrNoPreview
+	}
+	preview.URL = link
+	return preview, nil
+}
+
+// parse reads metadata from the head of an HTML page. Open Graph properties
+// are preferred over Twitter cards, which are preferred over plain tags.
+// Relative image URLs are resolved against base.
+func parse(r io.Reader, base *url.URL) Preview {
+	meta := make(map[string]string)
+	var title string
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return newPreview(meta, title, base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				return newPreview(meta, title, base)
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(string(v)))
+					case "content":
+						content = string(v)
+					}
+				}
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(z.Text())
+			}
+		}
+	}
+}
+
+// newPreview picks the preview fields from a page's meta tags and title.
+func newPreview(meta map[string]string, title string, base *url.URL) Preview {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := clean(meta[key]); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	preview := Preview{
+		Title:       truncate(first("og:title", "twitter:title"), maxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), maxDescriptionLength),
+		SiteName:    truncate(first("og:site_name"), maxTitleLength),
+	}
+	if preview.Title == "" {
+		preview.Title = truncate(clean(title), maxTitleLength)
+	}
+	if image := first("og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			preview.ImageURL = u.String()
+		}
+	}
+	return preview
+}
+
+// clean collapses whitespace and drops invalid UTF-8.
+func clean(s string) string {
+	return strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublic lists special-purpose ranges that IsGlobalUnicast and IsPrivate
+// don't exclude.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, which can reach private IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, which can embed private IPv4
+}
@@ -0,0 +1,170 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// newTestFetcher returns a Fetcher that can reach httptest servers on loopback.
+func newTestFetcher() *Fetcher {
+	f := New()
+	f.allowed = func(netip.Addr) bool { return true }
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!doctype html><html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="  Beach   day &amp; more ">
+<meta name="twitter:title" content="Twitter title">
+<meta name="description" content="Photos from the weekend">
+<meta property="og:image" content="/images/beach.jpg">
+<meta property="og:site_name" content="Family Blog">
+</head><body><meta property="og:description" content="ignored in body"></body></html>`))
+	}))
+	defer srv.Close()
+
+	link := srv.URL + "/posts/1"
+	preview, err := newTestFetcher().Fetch(context.Background(), link)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := Preview{
+		URL:         link,
+		Title:       "Beach day & more",
+		Description: "Photos from the weekend",
+		ImageURL:    srv.URL + "/images/beach.jpg",
+		SiteName:    "Family Blog",
+	}
+	if preview != want {
+		t.Errorf("Fetch = %+v, want %+v", preview, want)
+	}
+}
+
+func TestFetchTitleFallback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><title>Just a title</title></head></html>`))
+	}))
+	defer srv.Close()
+
+	preview, err := newTestFetcher().Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if preview.Title != "Just a title" {
+		t.Errorf("Title = %q, want %q", preview.Title, "Just a title")
+	}
+}
+
+func TestFetchBlocksLoopback(t *testing.T) {
+	requested := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer srv.Close()
+
+	_, err := New().Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("Fetch error = %v, want ErrBlocked", err)
+	}
+	if requested {
+		t.Error("request reached the loopback server")
+	}
+}
+
+func TestFetchBlocksRedirectToPrivate(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect reached the internal server")
+	}))
+	defer internal.Close()
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(internal.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer public.Close()
+
+	// Only the first server's address counts as public
+	f := New()
+	publicAddr := netip.MustParseAddrPort(strings.TrimPrefix(public.URL, "http://")).Addr()
+	first := true
+	f.allowed = func(addr netip.Addr) bool {
+		ok := first && addr == publicAddr
+		first = false
+		return ok
+	}
+
+	_, err := f.Fetch(context.Background(), public.URL)
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("Fetch error = %v, want ErrBlocked", err)
+	}
+}
+
+func TestFetchNoPreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}},
+		{"not html", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("<title>not really</title>"))
+		}},
+		{"no title", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><head></head><body>Hello</body></html>"))
+		}},
+		{"title past size cap", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><head><!--" + strings.Repeat("x", MaxBytes) + `--><meta property="og:title" content="Too far"></head></html>`))
+		}},
+		{"too many redirects", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/again", http.StatusFound)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			_, err := newTestFetcher().Fetch(context.Background(), srv.URL)
+			if !errors.Is(err, ErrNoPreview) {
+				t.Errorf("Fetch error = %v, want ErrNoPreview", err)
+			}
+		})
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"64:ff9b::a00:1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublic(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublic(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
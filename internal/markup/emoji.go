@@ -0,0 +1,86 @@
+package markup
+
+// shortcodes maps the emoji shortcodes Render replaces, without their colons,
+// to the emoji. Names follow the ones used by GitHub and Slack.
+var shortcodes = map[string]string{
+	"+1":                "👍",
+	"-1":                "👎",
+	"100":               "💯",
+	"airplane":          "✈️",
+	"angry":             "😠",
+	"baby":              "👶",
+	"balloon":           "🎈",
+	"birthday":          "🎂",
+	"blush":             "😊",
+	"broken_heart":      "💔",
+	"cake":              "🍰",
+	"cat":               "🐱",
+	"christmas_tree":    "🎄",
+	"clap":              "👏",
+	"coffee":            "☕",
+	"confused":          "😕",
+	"crossed_fingers":   "🤞",
+	"cry":               "😢",
+	"dog":               "🐶",
+	"exclamation":       "❗",
+	"eyes":              "👀",
+	"face_palm":         "🤦",
+	"fire":              "🔥",
+	"flushed":           "😳",
+	"gift":              "🎁",
+	"grin":              "😁",
+	"grinning":          "😀",
+	"heart":             "❤️",
+	"heart_eyes":        "😍",
+	"heavy_check_mark":  "✔️",
+	"house":             "🏠",
+	"hugs":              "🤗",
+	"innocent":          "😇",
+	"joy":               "😂",
+	"kiss":              "😘",
+	"laughing":          "😆",
+	"moon":              "🌙",
+	"muscle":            "💪",
+	"ok_hand":           "👌",
+	"party":             "🥳",
+	"pleading_face":     "🥺",
+	"point_up":          "☝️",
+	"pray":              "🙏",
+	"question":          "❓",
+	"rainbow":           "🌈",
+	"raised_hands":      "🙌",
+	"relieved":          "😌",
+	"rocket":            "🚀",
+	"rofl":              "🤣",
+	"rose":              "🌹",
+	"scream":            "😱",
+	"see_no_evil":       "🙈",
+	"shrug":             "🤷",
+	"slightly_smiling":  "🙂",
+	"smile":             "😄",
+	"smiley":            "😃",
+	"smiling_face_tear": "🥲",
+	"snowflake":         "❄️",
+	"sob":               "😭",
+	"sparkles":          "✨",
+	"star":              "⭐",
+	"stuck_out_tongue":  "😛",
+	"sun":               "☀️",
+	"sunglasses":        "😎",
+	"sweat_smile":       "😅",
+	"tada":              "🎉",
+	"thinking":          "🤔",
+	"thumbsdown":        "👎",
+	"thumbsup":          "👍",
+	"two_hearts":        "💕",
+	"umbrella":          "☂️",
+	"upside_down":       "🙃",
+	"v":                 "✌️",
+	"warning":           "⚠️",
+	"wave":              "👋",
+	"white_check_mark":  "✅",
+	"wink":              "😉",
+	"x":                 "❌",
+	"yum":               "😋",
+	"zzz":               "💤",
+}
@@ -0,0 +1,392 @@
+// Package markup renders the formatting people type in messages as HTML.
+//
+// The dialect is deliberately small:
+//
+//	**bold**  *italic*  _italic_  `code`
+//	```
+//	code block
+//	```
+//	> quoted line
+//
+// along with links to http and https URLs and emoji shortcodes such as
+// :heart:. Everything else is escaped text. The output only contains the
+// elements listed in Allowed, and the only attributes are the href, rel and
+// target of links, so it is safe to insert into a page as is.
+package markup
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Allowed lists the elements Render can produce, and their attributes.
+var Allowed = map[string][]string{
+	"a":          {"href", "rel", "target"},
+	"blockquote": nil,
+	"br":         nil,
+	"code":       nil,
+	"em":         nil,
+	"pre":        nil,
+	"strong":     nil,
+}
+
+// urlPattern matches the rest of an http or https link.
+var urlPattern = regexp.MustCompile(`^(?i:https?)://[^\s<>"'` + "`" + `]+`)
+
+// shortcodePattern matches an emoji shortcode.
+var shortcodePattern = regexp.MustCompile(`^:[a-z0-9_+-]+:`)
+
+// Render formats message text as HTML. Lines are separated with <br>.
+func Render(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var b strings.Builder
+	var para []string
+	// Blank lines next to a quote or code block would only add space
+	flush := func(block bool) {
+		if block {
+			for len(para) > 0 && strings.TrimSpace(para[len(para)-1]) == "" {
+				para = para[:len(para)-1]
+			}
+		}
+		writeLines(&b, para)
+		para = para[:0]
+	}
+	afterBlock := false
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isFence(line):
+			flush(true)
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != "```" {
+				end++
+			}
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(lines[i+1:min(end, len(lines))], "\n")))
+			b.WriteString("</code></pre>")
+			i = end + 1
+			afterBlock = true
+		case strings.HasPrefix(line, ">"):
+			flush(true)
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(lines[i], ">"); i++ {
+				quoted = append(quoted, strings.TrimPrefix(lines[i][1:], " "))
+			}
+			b.WriteString("<blockquote>")
+			writeLines(&b, quoted)
+			b.WriteString("</blockquote>")
+			afterBlock = true
+		default:
+			if !(afterBlock && strings.TrimSpace(line) == "") {
+				para = append(para, line)
+				afterBlock = false
+			}
+			i++
+		}
+	}
+	flush(false)
+	return b.String()
+}
+
+// FirstLink returns the first link Render would make in text, or an empty
+// string if there is none. Links in code don't count.
+func FirstLink(text string) string {
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		switch {
+		case inCode:
+			inCode = strings.TrimSpace(line) != "```"
+		case isFence(line):
+			inCode = true
+		default:
+			l := newLine(line)
+			for i := 0; i < len(line); i++ {
+				if s, ok := l.spans[i]; ok && s.link != "" {
+					return s.link
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isFence reports whether line opens a code block: three backticks,
+// optionally followed by a language name.
+func isFence(line string) bool {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "```")
+	return ok && !strings.Contains(rest, "`")
+}
+
+// writeLines writes inline-formatted lines separated by <br>.
+func writeLines(b *strings.Builder, lines []string) {
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("<br>")
+		}
+		l := newLine(line)
+		l.render(b, 0, len(line), false, false)
+	}
+}
+
+// span is a part of a line that is rendered whole, without looking for
+// emphasis inside it: code, a link or an emoji.
+type span struct {
+	end  int
+	html string
+	link string // Set for links
+}
+
+// line is a line of text with its code, links and emoji found.
+type line struct {
+	text    string
+	spans   map[int]span      // By start offset
+	closers map[closerKey]int // Filled in by nextCloser
+}
+
+// closerKey identifies a search for a closing delimiter from one offset.
+type closerKey struct {
+	delim string
+	from  int
+	end   int
+}
+
+func newLine(text string) *line {
+	l := &line{text: text, spans: make(map[int]span)}
+	for i := 0; i < len(text); {
+		if s, ok := l.spanAt(i); ok {
+			l.spans[i] = s
+			i = s.end
+			continue
+		}
+		i++
+	}
+	return l
+}
+
+// spanAt returns the code, link or emoji starting at offset i, if any.
+func (l *line) spanAt(i int) (span, bool) {
+	text := l.text
+	switch text[i] {
+	case '`':
+		// Code is closed by a run of as many backticks as opened it
+		n := runLength(text, i, '`')
+		for j := i + n; j < len(text); {
+			if text[j] != '`' {
+				j++
+				continue
+			}
+			m := runLength(text, j, '`')
+			if m == n {
+				code := text[i+n : j]
+				if strings.TrimSpace(code) == "" {
+					break
+				}
+				return span{end: j + m, html: "<code>" + html.EscapeString(code) + "</code>"}, true
+			}
+			j += m
+		}
+	case 'h', 'H':
+		link := linkAt(text, i)
+		if link == "" {
+			break
+		}
+		escaped := html.EscapeString(link)
+		return span{
+			end:  i + len(link),
+			html: `<a href="` + escaped + `" rel="noopener noreferrer" target="_blank">` + escaped + `</a>`,
+			link: link,
+		}, true
+	case ':':
+		code := shortcodePattern.FindString(text[i:])
+		if e, ok := shortcodes[strings.Trim(code, ":")]; ok {
+			return span{end: i + len(code), html: e}, true
+		}
+	}
+	return span{}, false
+}
+
+// linkAt returns the http or https link starting at offset i of text, or an
+// empty string if there is none. A link can't follow a letter or digit.
+func linkAt(text string, i int) string {
+	if i > 0 && isWordBefore(text, i) {
+		return ""
+	}
+	link := trimLink(urlPattern.FindString(text[i:]))
+	if u, err := url.Parse(link); err != nil || u.Host == "" {
+		return ""
+	}
+	return link
+}
+
+// trimLink drops trailing punctuation from a matched link. A closing
+// parenthesis is kept when it has a partner in the link, as in Wikipedia
+// article names.
+func trimLink(link string) string {
+	for link != "" {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?*_~]}", last) >= 0:
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+		default:
+			return link
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// render writes text[start:end] with emphasis. inBold and inItalic stop
+// emphasis of the same kind from nesting.
+func (l *line) render(b *strings.Builder, start, end int, inBold, inItalic bool) {
+	text := l.text
+	for i := start; i < end; {
+		if s, ok := l.spans[i]; ok && s.end <= end {
+			b.WriteString(s.html)
+			i = s.end
+			continue
+		}
+
+		switch {
+		case !inBold && strings.HasPrefix(text[i:end], "**"):
+			if close := l.closing(i, end, "**"); close >= 0 {
+				b.WriteString("<strong>")
+				l.render(b, i+2, close, true, inItalic)
+				b.WriteString("</strong>")
+				i = close + 2
+				continue
+			}
+		case !inItalic && (text[i] == '*' || text[i] == '_'):
+			if close := l.closing(i, end, text[i:i+1]); close >= 0 {
+				b.WriteString("<em>")
+				l.render(b, i+1, close, inBold, true)
+				b.WriteString("</em>")
+				i = close + 1
+				continue
+			}
+		}
+
+		// Plain text up to the next character that might start something
+		next := i + 1
+		for next < end && !l.special(next) {
+			next++
+		}
+		b.WriteString(html.EscapeString(text[i:next]))
+		i = next
+	}
+}
+
+// special reports whether text[i] might start a span or emphasis.
+func (l *line) special(i int) bool {
+	if _, ok := l.spans[i]; ok {
+		return true
+	}
+	c := l.text[i]
+	return c == '*' || c == '_'
+}
+
+// closing returns the offset of the delimiter closing one opened with delim
+// at start, or -1 if it isn't closed before end. Emphasis can't start or end
+// next to a space, must contain something, and underscores only count at the
+// edges of words so snake_case stays as it is.
+func (l *line) closing(start, end int, delim string) int {
+	text := l.text
+	open := start + len(delim)
+	if open >= end || isSpaceAt(text, open) {
+		return -1
+	}
+	if delim == "*" && text[open] == '*' {
+		return -1
+	}
+	if delim == "_" && start > 0 && isWordBefore(text, start) {
+		return -1
+	}
+
+	// The opening delimiter can't close itself
+	from := open + 1
+	if s, ok := l.spans[open]; ok {
+		from = s.end
+	}
+	return l.nextCloser(from, end, delim)
+}
+
+// nextCloser returns the offset of the first delimiter from offset j on that
+// closes emphasis opened with delim, or -1 if there is none before end. The
+// answer is remembered for each delimiter passed on the way, so that a line
+// full of unclosed delimiters is scanned once rather than once per opener.
+func (l *line) nextCloser(j, end int, delim string) int {
+	text := l.text
+	var passed []int
+	found := -1
+	for j < end {
+		if text[j] == delim[0] {
+			if c, ok := l.closers[closerKey{delim, j, end}]; ok {
+				found = c
+				break
+			}
+			passed = append(passed, j)
+		}
+		if s, ok := l.spans[j]; ok {
+			j = s.end
+			continue
+		}
+		if !strings.HasPrefix(text[j:end], delim) {
+			j++
+			continue
+		}
+		// A single * can't close on half of a **
+		if delim == "*" && j+1 < end && text[j+1] == '*' {
+			j += 2
+			continue
+		}
+		after := j + len(delim)
+		if isSpaceBefore(text, j) || (delim == "_" && after < len(text) && isWordAt(text, after)) {
+			j++
+			continue
+		}
+		found = j
+		break
+	}
+
+	if l.closers == nil {
+		l.closers = make(map[closerKey]int)
+	}
+	for _, p := range passed {
+		l.closers[closerKey{delim, p, end}] = found
+	}
+	return found
+}
+
+// runLength counts the repeats of c starting at text[i].
+func runLength(text string, i int, c byte) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isWordBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSpaceAt(text string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return unicode.IsSpace(r)
+}
+
+func isSpaceBefore(text string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,169 @@
+package markup
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello", "hello"},
+		{"escaped", `<script>alert("hi")</script> & 'x'`, "&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; &amp; &#39;x&#39;"},
+		{"lines", "one\ntwo\r\nthree", "one<br>two<br>three"},
+		{"bold", "a **big** deal", "a <strong>big</strong> deal"},
+		{"italic", "*very* _much_ so", "<em>very</em> <em>much</em> so"},
+		{"nested", "**bold _and italic_**", "<strong>bold <em>and italic</em></strong>"},
+		{"unclosed", "**not bold", "**not bold"},
+		{"spaced", "2 * 3 * 4", "2 * 3 * 4"},
+		{"snake case", "my_file_name.txt", "my_file_name.txt"},
+		{"empty emphasis", "****", "****"},
+		{"code", "run `go *test*` now", "run <code>go *test*</code> now"},
+		{"code escaped", "`<b>`", "<code>&lt;b&gt;</code>"},
+		{"double backticks", "``a ` b``", "<code>a ` b</code>"},
+		{"code block", "look:\n```go\nx := *p\n<tag>\n```\nok", "look:<pre><code>x := *p\n&lt;tag&gt;</code></pre>ok"},
+		{"unclosed code block", "```\ncode", "<pre><code>code</code></pre>"},
+		{"inline triple backticks", "```x```", "<code>x</code>"},
+		{"quote", "> first\n>second\nreply", "<blockquote>first<br>second</blockquote>reply"},
+		{"quote formatted", "> **hi**", "<blockquote><strong>hi</strong></blockquote>"},
+		{"blank lines around blocks", "a\n\n> q\n\nb", "a<blockquote>q</blockquote>b"},
+		{"link", "see https://example.com/a?b=1&c=2.", `see <a href="https://example.com/a?b=1&amp;c=2" rel="noopener noreferrer" target="_blank">https://example.com/a?b=1&amp;c=2</a>.`},
+		{"link in emphasis", "**https://example.com/x_y**", `<strong><a href="https://example.com/x_y" rel="noopener noreferrer" target="_blank">https://example.com/x_y</a></strong>`},
+		{"link underscores", "_https://example.com/a_b_", `<em><a href="https://example.com/a_b" rel="noopener noreferrer" target="_blank">https://example.com/a_b</a></em>`},
+		{"link in code", "`https://example.com`", "<code>https://example.com</code>"},
+		{"not a link", "javascript:alert(1) xhttp://example.com https://", "javascript:alert(1) xhttp://example.com https://"},
+		{"emoji", "happy :birthday: :tada:!", "happy 🎂 🎉!"},
+		{"unknown shortcode", ":not_an_emoji:", ":not_an_emoji:"},
+		{"emoji in code", "`:tada:`", "<code>:tada:</code>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.text); got != tt.want {
+				t.Errorf("Render(%q)\n got %s\nwant %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstLink(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"no links here", ""},
+		{"see https://example.com/a?b=c.", "https://example.com/a?b=c"},
+		{"(http://example.com/x)", "http://example.com/x"},
+		{"https://en.wikipedia.org/wiki/Rabaul_(town)!", "https://en.wikipedia.org/wiki/Rabaul_(town)"},
+		{"first http://a.example then http://b.example", "http://a.example"},
+		{"HTTPS://Example.com/Path", "HTTPS://Example.com/Path"},
+		{"`http://a.example` then http://b.example", "http://b.example"},
+		{"```\nhttp://a.example\n```\n> http://b.example", "http://b.example"},
+		{"xhttp://example.com", ""},
+		{"ftp://example.com", ""},
+		{"https://", ""},
+	}
+	for _, tt := range tests {
+		if got := FirstLink(tt.text); got != tt.want {
+			t.Errorf("FirstLink(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// FuzzRender checks that nothing typed into a message can produce markup
+// outside the allowed set.
+func FuzzRender(f *testing.F) {
+	for _, seed := range []string{
+		"**bold** *italic* _italic_ `code`",
+		"```\n<script>\n```",
+		"> quote\n> **more**",
+		"https://example.com/?q=\"><script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		":tada: **_`x`_** https://example.com/(a)_",
+		"*`*`*",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		checkHTML(t, Render(text))
+	})
+}
+
+// checkHTML fails unless out only uses allowed elements and attributes,
+// every element is closed, and links only go to web pages.
+func checkHTML(t *testing.T, out string) {
+	t.Helper()
+	var open []string
+	z := html.NewTokenizer(strings.NewReader(out))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() != io.EOF {
+				t.Fatalf("tokenizing %q: %v", out, z.Err())
+			}
+			if len(open) > 0 {
+				t.Fatalf("unclosed %v in %q", open, out)
+			}
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			allowed, ok := Allowed[token.Data]
+			if !ok {
+				t.Fatalf("element %q in %q", token.Data, out)
+			}
+			for _, attr := range token.Attr {
+				if !slices.Contains(allowed, attr.Key) {
+					t.Fatalf("attribute %q on %q in %q", attr.Key, token.Data, out)
+				}
+				if attr.Key == "href" {
+					u, err := url.Parse(attr.Val)
+					if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+						t.Fatalf("link to %q in %q", attr.Val, out)
+					}
+				}
+			}
+			if tt == html.StartTagToken && token.Data != "br" {
+				open = append(open, token.Data)
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if len(open) == 0 || open[len(open)-1] != string(name) {
+				t.Fatalf("unexpected </%s> in %q", name, out)
+			}
+			open = open[:len(open)-1]
+		case html.CommentToken, html.DoctypeToken:
+			t.Fatalf("%v in %q", tt, out)
+		}
+	}
+}
+
+// BenchmarkRender renders lines full of delimiters that are never closed.
+// The time per byte should stay flat as the lines grow.
+func BenchmarkRender(b *testing.B) {
+	patterns := map[string]string{
+		"italic":     "*a ",
+		"bold":       "**a ",
+		"underscore": "_a *",
+	}
+	for name, pattern := range patterns {
+		for _, size := range []int{1000, 10000, 100000} {
+			text := strings.Repeat(pattern, size/len(pattern))
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				b.SetBytes(int64(len(text)))
+				for b.Loop() {
+					Render(text)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package presence derives whether users are online, away or offline from
+// their connected clients. Status is kept in memory by each process: with
+// several instances sharing a database, each only knows about the clients
+// connected to it, so instances can disagree about a user's status.
+package presence
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/realtime"
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+const (
+	// A client counts as idle when it has not reported activity for this long.
+	awayAfter = 5 * time.Minute
+
+	// How often idle clients are checked for online/away transitions.
+	sweepInterval = 30 * time.Second
+)
+
+// Status is a user's presence state.
+type Status string
+
+const (
+	StatusOnline  Status = "online"
+	StatusAway    Status = "away"
+	StatusOffline Status = "offline"
+)
+
+// Payload is the payload of a "presence" message.
+type Payload struct {
+	UserID     int64  `json:"user_id"`
+	Status     Status `json:"status"`
+	LastSeenAt string `json:"last_seen_at,omitempty"` // Set when Status is offline
+}
+
+// Tracker follows connected clients and heartbeats to derive each user's presence.
+// Transitions are pushed to everyone the user has a direct conversation or
+// a group with.
+type Tracker struct {
+	queries *store.Queries
+	hub     *realtime.Hub
+	stop    chan struct{}
+
+	mu sync.Mutex
+	// lastActive maps user ID to each connected client's last reported activity
+	lastActive map[int64]map[*realtime.Client]time.Time
+	// status holds the last published status of each connected user
+	status map[int64]Status
+}
+
+// New creates a new Tracker.
+func New(queries *store.Queries, hub *realtime.Hub) *Tracker {
+	return &Tracker{
+		queries:    queries,
+		hub:        hub,
+		stop:       make(chan struct{}),
+		lastActive: make(map[int64]map[*realtime.Client]time.Time),
+		status:     make(map[int64]Status),
+	}
+}
+
+// Start begins the idle sweep loop in a goroutine.
+func (t *Tracker) Start() {
+	go t.run()
+}
+
+// Stop signals the idle sweep loop to stop.
+func (t *Tracker) Stop() {
+	close(t.stop)
+}
+
+func (t *Tracker) run() {
+	slog.Info("presence service started", "type", "lifecycle", "interval", sweepInterval.String())
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.sweep()
+		case <-t.stop:
+			slog.Info("presence service stopped", "type", "lifecycle")
+			return
+		}
+	}
+}
+
+// Connected records a newly registered client. A new connection counts as activity.
+func (t *Tracker) Connected(c *realtime.Client) {
+	t.mu.Lock()
+	if t.lastActive[c.UserID] == nil {
+		t.lastActive[c.UserID] = make(map[*realtime.Client]time.Time)
+	}
+	t.lastActive[c.UserID][c] = time.Now()
+	changed := t.updateLocked(c.UserID)
+	t.mu.Unlock()
+
+	if changed != "" {
+		t.publish(c.UserID, changed, "")
+	}
+}
+
+// Disconnected removes a client. When the user's last client leaves,
+// their last-seen time is stored and they are reported offline.
+func (t *Tracker) Disconnected(c *realtime.Client) {
+	t.mu.Lock()
+	clients := t.lastActive[c.UserID]
+	delete(clients, c)
+	if len(clients) > 0 {
+		changed := t.updateLocked(c.UserID)
+		t.mu.Unlock()
+		if changed != "" {
+			t.publish(c.UserID, changed, "")
+		}
+		return
+	}
+	delete(t.lastActive, c.UserID)
+	delete(t.status, c.UserID)
+	t.mu.Unlock()
+
+	ctx := context.Background()
+	if err := t.queries.UpdateUserLastSeen(ctx, c.UserID); err != nil {
+		slog.Error("failed to update last seen", "type", "presence", "user_id", c.UserID, "error", err)
+	}
+
+	lastSeen := ""
+	if user, err := t.queries.GetUserByID(ctx, c.UserID); err == nil && user.LastSeenAt.Valid {
+		lastSeen = user.LastSeenAt.String
+	}
+	t.publish(c.UserID, StatusOffline, lastSeen)
+}
+
+// Heartbeat records a client's periodic report of whether its user is active.
+func (t *Tracker) Heartbeat(c *realtime.Client, active bool) {
+	t.mu.Lock()
+	clients := t.lastActive[c.UserID]
+	if _, ok := clients[c]; !ok {
+		t.mu.Unlock()
+		return
+	}
+	if active {
+		clients[c] = time.Now()
+	}
+	changed := t.updateLocked(c.UserID)
+	t.mu.Unlock()
+
+	if changed != "" {
+		t.publish(c.UserID, changed, "")
+	}
+}
+
+// Status returns a user's current presence.
+func (t *Tracker) Status(userID int64) Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if status, ok := t.status[userID]; ok {
+		return status
+	}
+	return StatusOffline
+}
+
+// sweep moves users whose clients have all gone idle to away.
+func (t *Tracker) sweep() {
+	type transition struct {
+		userID int64
+		status Status
+	}
+	var transitions []transition
+
+	t.mu.Lock()
+	for userID := range t.lastActive {
+		if changed := t.updateLocked(userID); changed != "" {
+			transitions = append(transitions, transition{userID, changed})
+		}
+	}
+	t.mu.Unlock()
+
+	for _, tr := range transitions {
+		t.publish(tr.userID, tr.status, "")
+	}
+}
+
+// updateLocked recomputes a connected user's status.
+// Returns the new status if it changed, or "" otherwise. t.mu must be held.
+func (t *Tracker) updateLocked(userID int64) Status {
+	status := StatusAway
+	for _, last := range t.lastActive[userID] {
+		if time.Since(last) < awayAfter {
+			status = StatusOnline
+			break
+		}
+	}
+	if t.status[userID] == status {
+		return ""
+	}
+	t.status[userID] = status
+	return status
+}
+
+// publish sends a presence transition to everyone the user has a direct
+// conversation or a group with.
+func (t *Tracker) publish(userID int64, status Status, lastSeenAt string) {
+	contacts, err := t.queries.ListContactIDs(context.Background(), userID)
+	if err != nil {
+		slog.Error("failed to list contacts", "type", "presence", "user_id", userID, "error", err)
+		return
+	}
+
+	msg := &realtime.Message{
+		Type: "presence",
+		Payload: Payload{
+			UserID:     userID,
+			Status:     status,
+			LastSeenAt: lastSeenAt,
+		},
+	}
+	for _, contactID := range contacts {
+		t.hub.SendToUser(contactID, msg)
+	}
+	slog.Info("presence changed", "type", "presence", "user_id", userID, "status", status)
+}
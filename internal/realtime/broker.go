@@ -0,0 +1,40 @@
+package realtime
+
+// Broker carries serialized messages from SendToUser to every hub that may hold
+// the target user's connections. A hub delivers what the broker hands it to its
+// own local clients only.
+type Broker interface {
+	// Start begins delivering published messages to deliver.
+	// Called once by NewHub before any Publish.
+	Start(deliver func(*UserMessage))
+
+	// Publish sends a message to every subscribed hub, including this process.
+	Publish(msg *UserMessage)
+
+	// Stop releases the broker's resources.
+	Stop()
+}
+
+// LocalBroker delivers messages within a single process.
+// Use when only one Wantok instance is running.
+type LocalBroker struct {
+	deliver func(*UserMessage)
+}
+
+// NewLocalBroker creates an in-process broker.
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{}
+}
+
+// Start records the hub's delivery function.
+func (b *LocalBroker) Start(deliver func(*UserMessage)) {
+	b.deliver = deliver
+}
+
+// Publish hands the message straight to the local hub.
+func (b *LocalBroker) Publish(msg *UserMessage) {
+	b.deliver(msg)
+}
+
+// Stop is a no-op for the in-process broker.
+func (b *LocalBroker) Stop() {}
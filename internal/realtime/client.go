@@ -1,6 +1,10 @@
 package realtime
 
 import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,20 +21,63 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 
 	// Maximum message size allowed from peer.
-	maxMessageSize = 512
+	// Fits a 4096-character message (up to 4 bytes per rune) plus the JSON envelope.
+	maxMessageSize = 32 * 1024
 )
 
-// Client represents a single WebSocket connection.
+// Inbound represents a frame received from a client.
+type Inbound struct {
+	Type    string          `json:"type"`    // "send"
+	ID      string          `json:"id"`      // Client correlation ID, echoed in the reply
+	Payload json.RawMessage `json:"payload"` // Type-specific body
+}
+
+// InboundHandler processes a frame received from a client.
+// Called from the client's read goroutine, so frames from one client are handled in order.
+type InboundHandler func(c *Client, in *Inbound)
+
+// Client represents a single WebSocket or Server-Sent Events connection.
 // Each browser tab/device creates a new Client.
 type Client struct {
 	hub *Hub
 
-	// The websocket connection.
+	// The websocket connection. Nil for event-stream clients.
 	conn *websocket.Conn
 
 	// Buffered channel of outbound messages.
 	send chan []byte
 
+	// mu guards closed so replies from ReadPump never race the hub closing send.
+	mu     sync.Mutex
+	closed bool
+
+	// closeReason is sent to the peer when send is closed. Set before closing send.
+	closeReason string
+
+	// flushed is closed when the write pump has exited.
+	flushed chan struct{}
+
+	// handler processes inbound frames. May be nil.
+	handler InboundHandler
+
+	// inbound serializes handler calls for event-stream clients, whose frames
+	// arrive on separate requests.
+	inbound sync.Mutex
+
+	// resumed is set once the client sends a resume frame and opts into redelivery.
+	resumed atomic.Bool
+
+	// acked is the highest contiguous event sequence number the client has applied.
+	acked atomic.Int64
+
+	// behindSince is when the client was first seen lagging the latest event.
+	// Only accessed by the hub's Run goroutine.
+	behindSince time.Time
+
+	// StreamID identifies an event-stream client to the requests carrying its
+	// inbound frames. Empty for WebSocket clients.
+	StreamID string
+
 	// UserID of the authenticated user.
 	UserID int64
 
@@ -39,11 +86,13 @@ type Client struct {
 }
 
 // NewClient creates a new Client instance.
-func NewClient(hub *Hub, conn *websocket.Conn, userID int64, displayName string) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID int64, displayName string, handler InboundHandler) *Client {
 	return &Client{
 		hub:         hub,
 		conn:        conn,
 		send:        make(chan []byte, 256),
+		flushed:     make(chan struct{}),
+		handler:     handler,
 		UserID:      userID,
 		DisplayName: displayName,
 	}
@@ -65,12 +114,26 @@ func (c *Client) ReadPump() {
 	})
 
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			// Connection closed or error - exit loop
 			break
 		}
-		// Future: process incoming message (typing, read receipt, etc.)
+
+		c.dispatch(data)
+	}
+}
+
+// dispatch decodes an inbound frame and passes it to the handler.
+// Invalid frames are answered with an "error" frame.
+func (c *Client) dispatch(data []byte) {
+	var in Inbound
+	if err := json.Unmarshal(data, &in); err != nil {
+		c.SendMessage(&Message{Type: "error", Payload: ErrorPayload{Error: "invalid frame"}})
+		return
+	}
+	if c.handler != nil {
+		c.handler(c, &in)
 	}
 }
 
@@ -81,6 +144,7 @@ func (c *Client) WritePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		close(c.flushed)
 	}()
 
 	for {
@@ -89,7 +153,11 @@ func (c *Client) WritePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// Hub closed the channel
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				data := []byte{}
+				if c.closeReason != "" {
+					data = websocket.FormatCloseMessage(websocket.CloseServiceRestart, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, data)
 				return
 			}
 
@@ -110,6 +178,11 @@ func (c *Client) WritePump() {
 // Send queues a message to be sent to this client.
 // Returns false if the send buffer is full (client will be disconnected).
 func (c *Client) Send(data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
 	select {
 	case c.send <- data:
 		return true
@@ -118,8 +191,32 @@ func (c *Client) Send(data []byte) bool {
 	}
 }
 
+// SendMessage serializes and queues a message for this client only.
+// Used to reply to inbound frames (acks, errors).
+func (c *Client) SendMessage(msg *Message) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("failed to marshal message", "type", "websocket", "error", err)
+		return false
+	}
+	return c.Send(data)
+}
+
 // Close closes the client's send channel.
 // Should only be called by the hub.
 func (c *Client) Close() {
+	c.closeWithReason("")
+}
+
+// closeWithReason closes the client's send channel. A non-empty reason is
+// passed to the peer as a service-restart close frame.
+func (c *Client) closeWithReason(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.closeReason = reason
 	close(c.send)
 }
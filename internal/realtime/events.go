@@ -0,0 +1,142 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+const (
+	// Number of stored events sent per query when replaying.
+	replayBatchSize = 100
+
+	// How often clients are checked for unacknowledged events.
+	redeliverPeriod = 5 * time.Second
+
+	// How long a client may lag behind the latest event before it is replayed.
+	redeliverAfter = 10 * time.Second
+)
+
+// ResumePayload is the payload of an inbound "resume" frame.
+type ResumePayload struct {
+	LastSeq int64 `json:"last_seq"` // Highest sequence number the client has applied
+}
+
+// AckPayload is the payload of an inbound "ack" frame.
+type AckPayload struct {
+	Seq int64 `json:"seq"` // Highest contiguous sequence number the client has applied
+}
+
+// Publish stores msg in the user's event log and delivers it to their connected clients.
+// Stored events carry a per-user sequence number so clients can detect gaps and
+// resume after a reconnect. If storing fails the message is still delivered live.
+func (h *Hub) Publish(ctx context.Context, userID int64, msg *Message) {
+	payload, err := json.Marshal(msg.Payload)
+	if err != nil {
+		slog.Error("failed to marshal event payload", "type", "websocket", "error", err)
+		return
+	}
+
+	event, err := h.queries.CreateUserEvent(ctx, store.CreateUserEventParams{
+		UserID:   userID,
+		UserID_2: userID,
+		Type:     msg.Type,
+		Payload:  string(payload),
+	})
+	if err != nil {
+		slog.Error("failed to store event", "type", "websocket", "user_id", userID, "error", err)
+		h.SendToUser(userID, msg)
+		return
+	}
+
+	h.SendToUser(userID, &Message{Type: msg.Type, Seq: event.Seq, Payload: json.RawMessage(payload)})
+}
+
+// Resume replays every stored event after lastSeq to a single client and
+// enables redelivery of events the client does not acknowledge.
+func (h *Hub) Resume(ctx context.Context, c *Client, lastSeq int64) {
+	c.acked.Store(lastSeq)
+	c.resumed.Store(true)
+	h.replay(ctx, c, lastSeq)
+}
+
+// Ack records the highest contiguous sequence number a client has applied.
+func (h *Hub) Ack(c *Client, seq int64) {
+	for {
+		acked := c.acked.Load()
+		if seq <= acked || c.acked.CompareAndSwap(acked, seq) {
+			return
+		}
+	}
+}
+
+// replay sends stored events after afterSeq to c in order.
+// If older events have been pruned the client is told to reload instead.
+func (h *Hub) replay(ctx context.Context, c *Client, afterSeq int64) {
+	for {
+		events, err := h.queries.ListUserEventsAfter(ctx, store.ListUserEventsAfterParams{
+			UserID: c.UserID,
+			Seq:    afterSeq,
+			Limit:  replayBatchSize,
+		})
+		if err != nil {
+			slog.Error("failed to list events", "type", "websocket", "user_id", c.UserID, "error", err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+		if events[0].Seq > afterSeq+1 {
+			slog.Info("events pruned, client must reload", "type", "websocket", "user_id", c.UserID, "after_seq", afterSeq)
+			c.SendMessage(&Message{Type: "reset", Seq: events[len(events)-1].Seq, Payload: nil})
+			return
+		}
+
+		for _, event := range events {
+			msg := &Message{Type: event.Type, Seq: event.Seq, Payload: json.RawMessage(event.Payload)}
+			if !c.SendMessage(msg) {
+				// Buffer full or client closed; redelivery picks up from the last ack
+				return
+			}
+			afterSeq = event.Seq
+		}
+		if len(events) < replayBatchSize {
+			return
+		}
+	}
+}
+
+// redeliver replays events to resumed clients that have not acknowledged the
+// latest sequence number for longer than redeliverAfter.
+// Called from Run, which owns each client's behindSince.
+func (h *Hub) redeliver() {
+	now := time.Now()
+
+	h.mu.RLock()
+	var lagging []*Client
+	for userID, clients := range h.clients {
+		latest := h.latestSeq[userID]
+		for client := range clients {
+			if !client.resumed.Load() || client.acked.Load() >= latest {
+				client.behindSince = time.Time{}
+				continue
+			}
+			if client.behindSince.IsZero() {
+				client.behindSince = now
+				continue
+			}
+			if now.Sub(client.behindSince) >= redeliverAfter {
+				client.behindSince = time.Time{}
+				lagging = append(lagging, client)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range lagging {
+		go h.replay(context.Background(), client, client.acked.Load())
+	}
+}
@@ -1,15 +1,31 @@
 package realtime
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/store"
 )
 
+// How long clients are told to wait before reconnecting after a shutdown.
+// Matches RestartSec in deploy/wantok.service.
+const restartRetryAfter = 5 * time.Second
+
 // Message represents a WebSocket message to be sent to clients.
 type Message struct {
-	Type    string `json:"type"`    // "message", "typing", "presence", etc.
-	Payload any    `json:"payload"` // Message content
+	Type    string `json:"type"`          // "message", "ack", "error", "typing", "presence", etc.
+	ID      string `json:"id,omitempty"`  // Client correlation ID for replies to inbound frames
+	Seq     int64  `json:"seq,omitempty"` // Per-user sequence number of stored events
+	Payload any    `json:"payload"`       // Message content
+}
+
+// ErrorPayload is the payload of an "error" frame.
+type ErrorPayload struct {
+	Error string `json:"error"`
 }
 
 // Hub maintains the set of active clients and broadcasts messages to them.
@@ -25,33 +41,69 @@ type Hub struct {
 	// unregister channel for client disconnections
 	unregister chan *Client
 
-	// broadcast channel for messages to specific users
+	// broadcast channel for messages to specific users connected to this process
 	broadcast chan *UserMessage
 
-	// mu protects clients map for read operations outside Run()
+	// broker carries messages from SendToUser to every process's hub
+	broker Broker
+
+	// mu protects clients map for read operations outside Run(), and latestSeq
 	mu sync.RWMutex
+
+	// latestSeq maps user ID to the newest event sequence number seen by this hub
+	latestSeq map[int64]int64
+
+	// queries stores and replays the per-user event log
+	queries *store.Queries
+
+	// typing throttles and expires typing indicators
+	typing *typingTracker
+
+	// closing is closed by Shutdown to ask Run to close every client and return
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// stopped is closed when Run returns; draining then holds the clients it closed
+	stopped  chan struct{}
+	draining []*Client
 }
 
 // UserMessage wraps a serialized message with target user ID.
 type UserMessage struct {
 	UserID int64
+	Seq    int64 // Event sequence number, or 0 for ephemeral messages
 	Data   []byte
 }
 
 // NewHub creates a new Hub instance.
-func NewHub() *Hub {
-	return &Hub{
+// The broker decides which processes see messages passed to SendToUser.
+func NewHub(queries *store.Queries, broker Broker) *Hub {
+	h := &Hub{
 		clients:    make(map[int64]map[*Client]bool),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		broadcast:  make(chan *UserMessage, 256), // buffered to prevent blocking
+		broker:     broker,
+		latestSeq:  make(map[int64]int64),
+		queries:    queries,
+		closing:    make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
+	h.typing = newTypingTracker(h)
+	broker.Start(h.deliver)
+	return h
 }
 
 // Run starts the hub's main loop. Should be called in a goroutine.
 // Handles all client registration, unregistration, and message broadcasting.
+// Returns after Shutdown has closed every client.
 func (h *Hub) Run() {
 	slog.Info("hub started", "type", "lifecycle")
+
+	ticker := time.NewTicker(redeliverPeriod)
+	defer ticker.Stop()
+	defer close(h.stopped)
+
 	for {
 		select {
 		case client := <-h.register:
@@ -85,27 +137,86 @@ func (h *Hub) Run() {
 			for client := range clients {
 				if !client.Send(userMsg.Data) {
 					// Buffer full, disconnect client
-					go func(c *Client) {
-						h.unregister <- c
-					}(client)
+					go h.Unregister(client)
 				}
 			}
+
+		case <-ticker.C:
+			h.redeliver()
+
+		case <-h.closing:
+			h.closeAll()
+			slog.Info("hub stopped", "type", "lifecycle", "clients", len(h.draining))
+			return
+		}
+	}
+}
+
+// closeAll closes every client with a restart reason and records them for Shutdown.
+// Called from Run.
+func (h *Hub) closeAll() {
+	reason := restartReason()
+
+	h.mu.Lock()
+	for userID, clients := range h.clients {
+		for client := range clients {
+			client.closeWithReason(reason)
+			h.draining = append(h.draining, client)
 		}
+		delete(h.clients, userID)
 	}
+	h.mu.Unlock()
+}
+
+// restartReason tells clients the server is going away and when to come back.
+func restartReason() string {
+	return fmt.Sprintf("server restarting, retry in %d s", int(restartRetryAfter.Seconds()))
+}
+
+// Shutdown stops the hub, sends every client a close frame asking it to
+// reconnect shortly, and waits for their pumps to flush it.
+// Clients registering after Shutdown begins are closed immediately.
+// Returns ctx's error if the clients have not flushed before ctx is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.closeOnce.Do(func() { close(h.closing) })
+
+	select {
+	case <-h.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, client := range h.draining {
+		select {
+		case <-client.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
 }
 
 // Register adds a client to the hub.
+// After the hub has stopped the client is closed instead.
 func (h *Hub) Register(client *Client) {
-	h.register <- client
+	select {
+	case h.register <- client:
+	case <-h.stopped:
+		client.closeWithReason(restartReason())
+	}
 }
 
 // Unregister removes a client from the hub.
 func (h *Hub) Unregister(client *Client) {
-	h.unregister <- client
+	select {
+	case h.unregister <- client:
+	case <-h.stopped:
+	}
 }
 
 // SendToUser sends a message to all connected clients for a user.
-// Used by message handlers to broadcast new messages.
+// Used for ephemeral events such as typing and presence; use Publish for
+// events that must survive a reconnect.
 // Non-blocking: if user has no clients or channel is full, message is dropped.
 func (h *Hub) SendToUser(userID int64, msg *Message) {
 	data, err := json.Marshal(msg)
@@ -114,11 +225,51 @@ func (h *Hub) SendToUser(userID int64, msg *Message) {
 		return
 	}
 
+	h.broker.Publish(&UserMessage{UserID: userID, Seq: msg.Seq, Data: data})
+}
+
+// deliver queues a message from the broker for this process's clients.
+// Non-blocking: if the channel is full, the message is dropped. Stored events
+// are still recorded in latestSeq so redelivery notices the gap.
+func (h *Hub) deliver(userMsg *UserMessage) {
+	if userMsg.Seq > 0 {
+		h.mu.Lock()
+		if userMsg.Seq > h.latestSeq[userMsg.UserID] {
+			h.latestSeq[userMsg.UserID] = userMsg.Seq
+		}
+		h.mu.Unlock()
+	}
+
 	select {
-	case h.broadcast <- &UserMessage{UserID: userID, Data: data}:
+	case h.broadcast <- userMsg:
 	default:
-		slog.Warn("broadcast channel full, dropping message", "type", "websocket", "user_id", userID)
+		slog.Warn("broadcast channel full, dropping message", "type", "websocket", "user_id", userMsg.UserID)
+	}
+}
+
+// StartTyping relays that fromUserID is typing to toUserID's devices.
+// Repeated calls are throttled; the indicator expires if calls stop arriving.
+func (h *Hub) StartTyping(fromUserID, toUserID int64) {
+	h.typing.start(fromUserID, toUserID)
+}
+
+// StopTyping relays that fromUserID stopped typing to toUserID.
+// No-op if no typing indicator is active.
+func (h *Hub) StopTyping(fromUserID, toUserID int64) {
+	h.typing.stop(fromUserID, toUserID)
+}
+
+// StreamClient returns a user's connected event-stream client with the given
+// stream ID, or nil if there is none.
+func (h *Hub) StreamClient(userID int64, streamID string) *Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients[userID] {
+		if client.StreamID != "" && client.StreamID == streamID {
+			return client
+		}
 	}
+	return nil
 }
 
 // ClientCount returns the number of connected clients for a user.
@@ -0,0 +1,131 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+const (
+	// How often each process polls for messages published by other processes.
+	brokerPollInterval = 250 * time.Millisecond
+
+	// Maximum number of messages read per poll.
+	brokerPollBatchSize = 500
+
+	// How often delivered notifications are pruned.
+	brokerPruneInterval = time.Minute
+)
+
+// SQLiteBroker shares messages between processes that use the same database.
+// Messages are delivered to the local hub immediately and written to the
+// hub_notifications table, which every other process polls.
+type SQLiteBroker struct {
+	queries *store.Queries
+	nodeID  string
+	deliver func(*UserMessage)
+	stop    chan struct{}
+}
+
+// NewSQLiteBroker creates a broker backed by the hub_notifications table.
+func NewSQLiteBroker(queries *store.Queries) *SQLiteBroker {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return &SQLiteBroker{
+		queries: queries,
+		nodeID:  hex.EncodeToString(b),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start begins polling for messages from other processes.
+func (b *SQLiteBroker) Start(deliver func(*UserMessage)) {
+	b.deliver = deliver
+
+	// Only messages published from now on are of interest
+	lastID, err := b.queries.GetLatestHubNotificationID(context.Background())
+	if err != nil {
+		slog.Error("failed to get latest hub notification", "type", "broker", "error", err)
+	}
+
+	go b.run(lastID)
+}
+
+// Publish delivers the message locally and records it for other processes.
+func (b *SQLiteBroker) Publish(msg *UserMessage) {
+	b.deliver(msg)
+
+	err := b.queries.CreateHubNotification(context.Background(), store.CreateHubNotificationParams{
+		NodeID: b.nodeID,
+		UserID: msg.UserID,
+		Seq:    msg.Seq,
+		Data:   string(msg.Data),
+	})
+	if err != nil {
+		slog.Error("failed to store hub notification", "type", "broker", "user_id", msg.UserID, "error", err)
+	}
+}
+
+// Stop signals the polling loop to stop.
+func (b *SQLiteBroker) Stop() {
+	close(b.stop)
+}
+
+func (b *SQLiteBroker) run(lastID int64) {
+	slog.Info("sqlite broker started", "type", "lifecycle", "node_id", b.nodeID, "interval", brokerPollInterval.String())
+
+	poll := time.NewTicker(brokerPollInterval)
+	defer poll.Stop()
+	prune := time.NewTicker(brokerPruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-poll.C:
+			lastID = b.poll(lastID)
+		case <-prune.C:
+			b.prune()
+		case <-b.stop:
+			slog.Info("sqlite broker stopped", "type", "lifecycle")
+			return
+		}
+	}
+}
+
+// poll delivers messages written by other processes after lastID.
+// Returns the ID of the last message seen.
+func (b *SQLiteBroker) poll(lastID int64) int64 {
+	rows, err := b.queries.ListHubNotificationsAfter(context.Background(), store.ListHubNotificationsAfterParams{
+		ID:     lastID,
+		NodeID: b.nodeID,
+		Limit:  brokerPollBatchSize,
+	})
+	if err != nil {
+		slog.Error("failed to poll hub notifications", "type", "broker", "error", err)
+		return lastID
+	}
+
+	for _, row := range rows {
+		b.deliver(&UserMessage{UserID: row.UserID, Seq: row.Seq, Data: []byte(row.Data)})
+		lastID = row.ID
+	}
+	return lastID
+}
+
+// prune removes notifications every process has had time to read.
+func (b *SQLiteBroker) prune() {
+	result, err := b.queries.DeleteOldHubNotifications(context.Background())
+	if err != nil {
+		slog.Error("failed to delete old hub notifications", "type", "broker", "error", err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		slog.Info("deleted old hub notifications", "type", "broker", "count", count)
+	}
+}
@@ -0,0 +1,117 @@
+package realtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Send a comment line with this period so proxies don't close an idle stream.
+// Shorter than pingPeriod because many proxies time out idle responses after 30s.
+const streamPingPeriod = 25 * time.Second
+
+// ErrFrameTooLarge is returned by ReadFrame when a frame exceeds maxMessageSize.
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// ClosePayload is the payload of the "close" message ending an event stream
+// during a restart. WebSocket clients get the reason in the close frame instead.
+type ClosePayload struct {
+	Reason string `json:"reason"`
+}
+
+// StreamPayload is the payload of the "stream" message that opens an event stream.
+type StreamPayload struct {
+	StreamID string `json:"stream_id"` // Path parameter for posting inbound frames
+}
+
+// NewStreamClient creates a Client for a Server-Sent Events connection.
+// Outbound messages are written by StreamPump; inbound frames arrive through ReadFrame.
+func NewStreamClient(hub *Hub, userID int64, displayName string, handler InboundHandler) *Client {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return &Client{
+		hub:         hub,
+		send:        make(chan []byte, 256),
+		flushed:     make(chan struct{}),
+		handler:     handler,
+		StreamID:    hex.EncodeToString(b),
+		UserID:      userID,
+		DisplayName: displayName,
+	}
+}
+
+// StreamPump writes messages from the hub to an event-stream response.
+// Runs in the request's goroutine until the hub closes the client, a write
+// fails, or done is closed.
+func (c *Client) StreamPump(w http.ResponseWriter, done <-chan struct{}) {
+	rc := http.NewResponseController(w)
+	ticker := time.NewTicker(streamPingPeriod)
+	defer func() {
+		ticker.Stop()
+		close(c.flushed)
+	}()
+
+	write := func(chunk []byte) bool {
+		rc.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := w.Write(chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	// Tell the page where to post its frames
+	c.SendMessage(&Message{Type: "stream", Payload: StreamPayload{StreamID: c.StreamID}})
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			if !ok {
+				// Hub closed the channel; on restart, ask the browser to wait before reconnecting
+				if c.closeReason != "" {
+					data, _ := json.Marshal(&Message{Type: "close", Payload: ClosePayload{Reason: c.closeReason}})
+					write([]byte(fmt.Sprintf("retry: %d\ndata: %s\n\n", restartRetryAfter.Milliseconds(), data)))
+				}
+				return
+			}
+			chunk := make([]byte, 0, len(message)+8)
+			chunk = append(chunk, "data: "...)
+			chunk = append(chunk, message...)
+			chunk = append(chunk, "\n\n"...)
+			if !write(chunk) {
+				return
+			}
+
+		case <-ticker.C:
+			if !write([]byte(": ping\n\n")) {
+				return
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// ReadFrame reads one inbound frame for an event-stream client and handles it.
+// Frames from concurrent requests are handled one at a time.
+func (c *Client) ReadFrame(r io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(r, maxMessageSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxMessageSize {
+		return ErrFrameTooLarge
+	}
+
+	c.inbound.Lock()
+	defer c.inbound.Unlock()
+	c.dispatch(data)
+	return nil
+}
@@ -0,0 +1,100 @@
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// Minimum time between relayed "typing started" events for the same pair.
+	typingThrottle = 3 * time.Second
+
+	// Time after the last start event before the server reports typing stopped.
+	// Covers clients that disconnect without sending a stop event.
+	typingTimeout = 6 * time.Second
+)
+
+// TypingPayload is the payload of a "typing" message.
+type TypingPayload struct {
+	UserID int64 `json:"user_id"` // User who is typing
+	Typing bool  `json:"typing"`  // false when the user stopped typing
+}
+
+// typingKey identifies a typist and the user they are typing to.
+type typingKey struct {
+	from int64
+	to   int64
+}
+
+// typingState tracks an active typing indicator.
+type typingState struct {
+	lastSent time.Time
+	timer    *time.Timer
+}
+
+// typingTracker throttles and expires typing indicators.
+type typingTracker struct {
+	hub    *Hub
+	mu     sync.Mutex
+	active map[typingKey]*typingState
+}
+
+func newTypingTracker(hub *Hub) *typingTracker {
+	return &typingTracker{
+		hub:    hub,
+		active: make(map[typingKey]*typingState),
+	}
+}
+
+// start records that from is typing to to, relaying the event unless throttled.
+func (t *typingTracker) start(from, to int64) {
+	key := typingKey{from: from, to: to}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.active[key]
+	if !ok {
+		state = &typingState{}
+		state.timer = time.AfterFunc(typingTimeout, func() { t.expire(key, state) })
+		t.active[key] = state
+	} else {
+		state.timer.Reset(typingTimeout)
+	}
+
+	if now.Sub(state.lastSent) < typingThrottle {
+		return
+	}
+	state.lastSent = now
+	t.hub.SendToUser(to, &Message{Type: "typing", Payload: TypingPayload{UserID: from, Typing: true}})
+}
+
+// stop clears the typing indicator for from to to, if one is active.
+func (t *typingTracker) stop(from, to int64) {
+	key := typingKey{from: from, to: to}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.active[key]
+	if !ok {
+		return
+	}
+	state.timer.Stop()
+	delete(t.active, key)
+	t.hub.SendToUser(to, &Message{Type: "typing", Payload: TypingPayload{UserID: from, Typing: false}})
+}
+
+// expire clears an indicator whose client stopped sending start events.
+func (t *typingTracker) expire(key typingKey, state *typingState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// A stop followed by a new start replaces the state; leave the new one alone.
+	if t.active[key] != state {
+		return
+	}
+	delete(t.active, key)
+	t.hub.SendToUser(key.to, &Message{Type: "typing", Payload: TypingPayload{UserID: key.from, Typing: false}})
+}
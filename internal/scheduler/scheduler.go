@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/dukerupert/wantok/internal/store"
+)
+
+// SendFunc sends a scheduled message that has come due. Sending the same
+// message again must not store it twice, since a message is tried again if
+// its process stopped before the scheduler could remove it.
+type SendFunc func(ctx context.Context, msg store.ScheduledMessage) error
+
+// ErrUndeliverable is wrapped by a SendFunc's error when the message can
+// never be sent, because its sender has left the group for example. Other
+// errors are taken to be temporary.
+var ErrUndeliverable = errors.New("message can't be delivered")
+
+// Scheduler periodically sends scheduled messages once they are due.
+type Scheduler struct {
+	queries  *store.Queries
+	send     SendFunc
+	interval time.Duration
+	stop     chan struct{}
+}
+
+// New creates a new Scheduler that checks for due messages every interval.
+func New(queries *store.Queries, send SendFunc, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		queries:  queries,
+		send:     send,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins the dispatch loop in a goroutine.
+func (s *Scheduler) Start() {
+	go s.run()
+}
+
+// Stop signals the dispatch loop to stop.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+}
+
+func (s *Scheduler) run() {
+	slog.Info("scheduler service started", "type", "lifecycle", "interval", s.interval.String())
+
+	// Send anything that came due while the server was down
+	s.dispatch()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.dispatch()
+		case <-s.stop:
+			slog.Info("scheduler service stopped", "type", "lifecycle")
+			return
+		}
+	}
+}
+
+// dispatch claims and sends every due message. A message is removed once
+// it is sent. One that failed for now is released to be tried on the next
+// tick, and one that can never be sent is kept, marked failed, for its
+// sender to see.
+func (s *Scheduler) dispatch() {
+	ctx := context.Background()
+
+	due, err := s.queries.ClaimDueScheduledMessages(ctx)
+	if err != nil {
+		slog.Error("failed to claim scheduled messages", "type", "scheduler", "error", err)
+		return
+	}
+
+	for _, msg := range due {
+		err := s.send(ctx, msg)
+		switch {
+		case err == nil:
+			slog.Info("scheduled message sent", "type", "scheduler", "scheduled_id", msg.ID, "sender_id", msg.SenderID)
+			if err := s.queries.CompleteScheduledMessage(ctx, msg.ID); err != nil {
+				// Once the claim runs out it is sent again, which finds the stored message
+				slog.Error("failed to remove sent scheduled message", "type", "scheduler", "scheduled_id", msg.ID, "error", err)
+			}
+		case errors.Is(err, ErrUndeliverable):
+			slog.Warn("scheduled message can't be sent", "type", "scheduler", "scheduled_id", msg.ID, "sender_id", msg.SenderID, "error", err)
+			if err := s.queries.FailScheduledMessage(ctx, store.FailScheduledMessageParams{
+				Failure: sql.NullString{String: err.Error(), Valid: true},
+				ID:      msg.ID,
+			}); err != nil {
+				slog.Error("failed to mark scheduled message failed", "type", "scheduler", "scheduled_id", msg.ID, "error", err)
+			}
+		default:
+			slog.Error("failed to send scheduled message", "type", "scheduler", "scheduled_id", msg.ID, "sender_id", msg.SenderID, "error", err)
+			if err := s.queries.ReleaseScheduledMessage(ctx, msg.ID); err != nil {
+				slog.Error("failed to release scheduled message", "type", "scheduler", "scheduled_id", msg.ID, "error", err)
+			}
+		}
+	}
+}
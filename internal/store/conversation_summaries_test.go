@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+// summaryFor returns the summary row user has for the conversation with
+// otherUserID or groupID, failing if there is none.
+func summaryFor(t *testing.T, q *Queries, user User, otherUserID, groupID int64) ListConversationSummariesRow {
+	t.Helper()
+	rows, err := q.ListConversationSummaries(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("ListConversationSummaries: %v", err)
+	}
+	for _, row := range rows {
+		if (otherUserID != 0 && row.OtherUserID.Int64 == otherUserID) || (groupID != 0 && row.ConversationID.Int64 == groupID) {
+			return row
+		}
+	}
+	t.Fatalf("no summary for user %d with user %d or group %d in %+v", user.ID, otherUserID, groupID, rows)
+	return ListConversationSummariesRow{}
+}
+
+func TestDirectSummaries(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+
+	first := sendTestMessage(t, q, alice, bob, "hello")
+	second := sendTestMessage(t, q, alice, bob, "are you there?")
+
+	got := summaryFor(t, q, bob, alice.ID, 0)
+	if got.LastMessageID.Int64 != second.ID || got.LastMessage != "are you there?" || got.UnreadCount != 2 {
+		t.Errorf("bob's summary after two messages = %+v", got)
+	}
+	if got := summaryFor(t, q, alice, bob.ID, 0); got.UnreadCount != 0 || got.LastMessageID.Int64 != second.ID {
+		t.Errorf("alice's summary after sending = %+v", got)
+	}
+
+	if _, err := q.MarkConversationRead(ctx, MarkConversationReadParams{
+		UserID:                 bob.ID,
+		OtherUserID:            alice.ID,
+		LastDeliveredMessageID: first.ID,
+		LastReadMessageID:      first.ID,
+	}); err != nil {
+		t.Fatalf("MarkConversationRead: %v", err)
+	}
+	if got := summaryFor(t, q, bob, alice.ID, 0); got.UnreadCount != 1 {
+		t.Errorf("bob's unread count after reading the first message = %d, want 1", got.UnreadCount)
+	}
+
+	if _, err := q.UpdateMessageContent(ctx, UpdateMessageContentParams{
+		ID:              second.ID,
+		Content:         "still there?",
+		PreviousContent: second.Content,
+	}); err != nil {
+		t.Fatalf("UpdateMessageContent: %v", err)
+	}
+	if got := summaryFor(t, q, alice, bob.ID, 0); got.LastMessage != "still there?" {
+		t.Errorf("alice's preview after an edit = %q", got.LastMessage)
+	}
+
+	// Expiring the unread message falls back to the one before it
+	if err := q.DeleteMessage(ctx, second.ID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	got = summaryFor(t, q, bob, alice.ID, 0)
+	if got.LastMessageID.Int64 != first.ID || got.LastMessage != "hello" || got.UnreadCount != 0 {
+		t.Errorf("bob's summary after the newest message expired = %+v", got)
+	}
+
+	// The conversation leaves the list with its last message
+	if err := q.DeleteMessage(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	rows, err := q.ListConversationSummaries(ctx, bob.ID)
+	if err != nil {
+		t.Fatalf("ListConversationSummaries: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("bob's summaries with no messages left = %+v", rows)
+	}
+}
+
+func TestGroupSummaries(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+	carol := createTestUser(t, q, "carol")
+
+	group := createTestGroup(t, q, "family", alice, bob)
+	if got := summaryFor(t, q, bob, 0, group.ID); got.LastMessageID.Valid || got.UnreadCount != 0 {
+		t.Errorf("bob's summary of a new group = %+v", got)
+	}
+
+	sendTestGroupMessage(t, q, alice, group, "welcome")
+	sendTestGroupMessage(t, q, bob, group, "thanks")
+	if got := summaryFor(t, q, alice, 0, group.ID); got.LastMessage != "thanks" || got.UnreadCount != 1 {
+		t.Errorf("alice's summary = %+v", got)
+	}
+	if got := summaryFor(t, q, bob, 0, group.ID); got.UnreadCount != 1 {
+		t.Errorf("bob's unread count = %d, want 1", got.UnreadCount)
+	}
+
+	// Messages from before carol joined don't count for her
+	joinTestGroup(t, q, group, carol)
+	if got := summaryFor(t, q, carol, 0, group.ID); got.LastMessageID.Valid || got.UnreadCount != 0 {
+		t.Errorf("carol's summary on joining = %+v", got)
+	}
+	last := sendTestGroupMessage(t, q, alice, group, "hi carol")
+	if got := summaryFor(t, q, carol, 0, group.ID); got.LastMessageID.Int64 != last.ID || got.UnreadCount != 1 {
+		t.Errorf("carol's summary after a message = %+v", got)
+	}
+
+	if err := q.MarkGroupRead(ctx, MarkGroupReadParams{LastReadMessageID: last.ID, ConversationID: group.ID, UserID: bob.ID}); err != nil {
+		t.Fatalf("MarkGroupRead: %v", err)
+	}
+	if got := summaryFor(t, q, bob, 0, group.ID); got.UnreadCount != 0 {
+		t.Errorf("bob's unread count after reading = %d, want 0", got.UnreadCount)
+	}
+
+	// With the only message since she joined gone, carol's row falls back to
+	// when she joined rather than showing older messages
+	if err := q.DeleteMessage(ctx, last.ID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if got := summaryFor(t, q, carol, 0, group.ID); got.LastMessageID.Valid || got.UnreadCount != 0 {
+		t.Errorf("carol's summary after the message expired = %+v", got)
+	}
+	if got := summaryFor(t, q, alice, 0, group.ID); got.LastMessage != "thanks" {
+		t.Errorf("alice's preview after the message expired = %q, want %q", got.LastMessage, "thanks")
+	}
+
+	if _, err := q.RemoveConversationMember(ctx, RemoveConversationMemberParams{ConversationID: group.ID, UserID: carol.ID}); err != nil {
+		t.Fatalf("RemoveConversationMember: %v", err)
+	}
+	rows, err := q.ListConversationSummaries(ctx, carol.ID)
+	if err != nil {
+		t.Fatalf("ListConversationSummaries: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("carol's summaries after leaving = %+v", rows)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+	"testing"
+)
+
+// groupMessageIDs returns the IDs of the group messages user can see, newest first.
+func groupMessageIDs(t *testing.T, q *Queries, user User, group Conversation) []int64 {
+	t.Helper()
+	rows, err := q.GetGroupMessages(context.Background(), GetGroupMessagesParams{
+		UserID:         user.ID,
+		ConversationID: group.ID,
+		BeforeID:       math.MaxInt64,
+		Limit:          50,
+	})
+	if err != nil {
+		t.Fatalf("GetGroupMessages: %v", err)
+	}
+	var ids []int64
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+func TestGroupVisibilityByJoinTime(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+	carol := createTestUser(t, q, "carol")
+
+	group := createTestGroup(t, q, "family", alice, bob)
+	before := sendTestGroupMessage(t, q, alice, group, "before carol")
+	joinTestGroup(t, q, group, carol)
+
+	reply, err := q.CreateGroupMessage(ctx, CreateGroupMessageParams{
+		SenderID:       bob.ID,
+		ConversationID: sql.NullInt64{Int64: group.ID, Valid: true},
+		Content:        "replying",
+		ReplyToID:      sql.NullInt64{Int64: before.ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupMessage: %v", err)
+	}
+
+	if got := groupMessageIDs(t, q, alice, group); !slices.Equal(got, []int64{reply.ID, before.ID}) {
+		t.Errorf("alice sees %v, want [%d %d]", got, reply.ID, before.ID)
+	}
+	if got := groupMessageIDs(t, q, carol, group); !slices.Equal(got, []int64{reply.ID}) {
+		t.Errorf("carol sees %v, want [%d]", got, reply.ID)
+	}
+	if got := groupMessageIDs(t, q, createTestUser(t, q, "dave"), group); len(got) != 0 {
+		t.Errorf("a non-member sees %v", got)
+	}
+
+	// Quotes of messages from before she joined are hidden from carol too
+	rows, err := q.GetGroupMessages(ctx, GetGroupMessagesParams{UserID: carol.ID, ConversationID: group.ID, BeforeID: math.MaxInt64, Limit: 50})
+	if err != nil {
+		t.Fatalf("GetGroupMessages: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ReplyContent.Valid {
+		t.Errorf("carol's view of the reply = %+v", rows)
+	}
+
+	viewers, err := q.ListGroupMessageViewerIDs(ctx, ListGroupMessageViewerIDsParams{ConversationID: group.ID, MessageID: before.ID})
+	if err != nil {
+		t.Fatalf("ListGroupMessageViewerIDs: %v", err)
+	}
+	slices.Sort(viewers)
+	if !slices.Equal(viewers, []int64{alice.ID, bob.ID}) {
+		t.Errorf("viewers of the earlier message = %v, want [%d %d]", viewers, alice.ID, bob.ID)
+	}
+
+	// Leaving and joining again starts over from the new join
+	if _, err := q.RemoveConversationMember(ctx, RemoveConversationMemberParams{ConversationID: group.ID, UserID: carol.ID}); err != nil {
+		t.Fatalf("RemoveConversationMember: %v", err)
+	}
+	if got := groupMessageIDs(t, q, carol, group); len(got) != 0 {
+		t.Errorf("carol sees %v after leaving", got)
+	}
+	joinTestGroup(t, q, group, carol)
+	if got := groupMessageIDs(t, q, carol, group); len(got) != 0 {
+		t.Errorf("carol sees %v after joining again", got)
+	}
+	latest := sendTestGroupMessage(t, q, alice, group, "welcome back")
+	if got := groupMessageIDs(t, q, carol, group); !slices.Equal(got, []int64{latest.ID}) {
+		t.Errorf("carol sees %v, want [%d]", got, latest.ID)
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/dukerupert/wantok/internal/database"
+)
+
+// newTestQueries returns Queries for an empty in-memory database with the
+// schema applied.
+func newTestQueries(t *testing.T) *Queries {
+	t.Helper()
+	db, err := database.NewMemory()
+	if err != nil {
+		t.Fatalf("database.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db)
+}
+
+func createTestUser(t *testing.T, q *Queries, username string) User {
+	t.Helper()
+	u, err := q.CreateUser(context.Background(), CreateUserParams{
+		Username:     username,
+		DisplayName:  username,
+		PasswordHash: "x",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser %s: %v", username, err)
+	}
+	return u
+}
+
+func sendTestMessage(t *testing.T, q *Queries, from, to User, content string) Message {
+	t.Helper()
+	m, err := q.CreateMessage(context.Background(), CreateMessageParams{
+		SenderID:    from.ID,
+		RecipientID: sql.NullInt64{Int64: to.ID, Valid: true},
+		Content:     content,
+	})
+	if err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	return m
+}
+
+func createTestGroup(t *testing.T, q *Queries, name string, members ...User) Conversation {
+	t.Helper()
+	ctx := context.Background()
+	c, err := q.CreateConversation(ctx, CreateConversationParams{
+		Name:      name,
+		CreatedBy: sql.NullInt64{Int64: members[0].ID, Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateConversation: %v", err)
+	}
+	for _, m := range members {
+		joinTestGroup(t, q, c, m)
+	}
+	return c
+}
+
+func joinTestGroup(t *testing.T, q *Queries, c Conversation, u User) {
+	t.Helper()
+	err := q.AddConversationMember(context.Background(), AddConversationMemberParams{ConversationID: c.ID, UserID: u.ID})
+	if err != nil {
+		t.Fatalf("AddConversationMember: %v", err)
+	}
+}
+
+func sendTestGroupMessage(t *testing.T, q *Queries, from User, c Conversation, content string) Message {
+	t.Helper()
+	m, err := q.CreateGroupMessage(context.Background(), CreateGroupMessageParams{
+		SenderID:       from.ID,
+		ConversationID: sql.NullInt64{Int64: c.ID, Valid: true},
+		Content:        content,
+	})
+	if err != nil {
+		t.Fatalf("CreateGroupMessage: %v", err)
+	}
+	return m
+}
@@ -11,37 +11,234 @@ import (
 )
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (sender_id, recipient_id, content)
-VALUES (?, ?, ?)
-RETURNING id, sender_id, recipient_id, content, created_at
+INSERT INTO messages (sender_id, recipient_id, content, reply_to_id, client_id, expires_at)
+VALUES (
+    ?1,
+    ?2,
+    ?3,
+    ?4,
+    ?5,
+    (
+        SELECT datetime('now', printf('+%d seconds', s.disappear_seconds))
+        FROM conversation_settings s
+        WHERE s.conversation_id IS NULL
+          AND s.user_id = min(?1, ?2)
+          AND s.other_user_id = max(?1, ?2)
+          AND s.disappear_seconds IS NOT NULL
+    )
+)
+ON CONFLICT (sender_id, client_id) WHERE client_id IS NOT NULL DO NOTHING
+RETURNING id, sender_id, recipient_id, conversation_id, content, created_at, edited_at, deleted_at, reply_to_id, keep, expires_at, client_id, preview_url
 `
 
 type CreateMessageParams struct {
 	SenderID    int64
-	RecipientID int64
+	RecipientID sql.NullInt64
 	Content     string
+	ReplyToID   sql.NullInt64
+	ClientID    sql.NullString
 }
 
+// expires_at comes from the conversation's disappearing-message timer, if set.
+// Returns no row if the sender already used client_id; see GetSentMessage.
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
-	row := q.db.QueryRowContext(ctx, createMessage, arg.SenderID, arg.RecipientID, arg.Content)
+	row := q.db.QueryRowContext(ctx, createMessage,
+		arg.SenderID,
+		arg.RecipientID,
+		arg.Content,
+		arg.ReplyToID,
+		arg.ClientID,
+	)
 	var i Message
 	err := row.Scan(
 		&i.ID,
 		&i.SenderID,
 		&i.RecipientID,
+		&i.ConversationID,
 		&i.Content,
 		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.Keep,
+		&i.ExpiresAt,
+		&i.ClientID,
+		&i.PreviewUrl,
 	)
 	return i, err
 }
 
-const deleteOldMessages = `-- name: DeleteOldMessages :execresult
+const createMessageRevision = `-- name: CreateMessageRevision :exec
+INSERT INTO message_revisions (message_id, content)
+VALUES (?, ?)
+`
+
+type CreateMessageRevisionParams struct {
+	MessageID int64
+	Content   string
+}
+
+func (q *Queries) CreateMessageRevision(ctx context.Context, arg CreateMessageRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageRevision, arg.MessageID, arg.Content)
+	return err
+}
+
+const deleteExpiredMessages = `-- name: DeleteExpiredMessages :many
 DELETE FROM messages
-WHERE created_at < datetime('now', '-30 days')
+WHERE expires_at <= datetime('now')
+  AND keep = 0
+  AND NOT EXISTS (
+      SELECT 1 FROM conversation_settings s
+      WHERE s.keep = 1
+        AND (s.conversation_id = messages.conversation_id
+             OR (s.conversation_id IS NULL
+                 AND s.user_id = min(messages.sender_id, messages.recipient_id)
+                 AND s.other_user_id = max(messages.sender_id, messages.recipient_id)))
+  )
+RETURNING id, sender_id, recipient_id, conversation_id
 `
 
-func (q *Queries) DeleteOldMessages(ctx context.Context) (sql.Result, error) {
-	return q.db.ExecContext(ctx, deleteOldMessages)
+type DeleteExpiredMessagesRow struct {
+	ID             int64
+	SenderID       int64
+	RecipientID    sql.NullInt64
+	ConversationID sql.NullInt64
+}
+
+// Deletes messages whose disappearing-message timer has run out, unless they
+// or their conversation are on hold.
+func (q *Queries) DeleteExpiredMessages(ctx context.Context) ([]DeleteExpiredMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteExpiredMessages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteExpiredMessagesRow
+	for rows.Next() {
+		var i DeleteExpiredMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecipientID,
+			&i.ConversationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMessage = `-- name: DeleteMessage :exec
+DELETE FROM messages
+WHERE id = ?
+`
+
+func (q *Queries) DeleteMessage(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteMessage, id)
+	return err
+}
+
+const deleteMessageContent = `-- name: DeleteMessageContent :one
+UPDATE messages
+SET content = '', edited_at = NULL, deleted_at = datetime('now'), preview_url = NULL
+WHERE id = ?
+RETURNING id, sender_id, recipient_id, conversation_id, content, created_at, edited_at, deleted_at, reply_to_id, keep, expires_at, client_id, preview_url
+`
+
+// Leaves a tombstone: the row keeps its place but loses its text.
+func (q *Queries) DeleteMessageContent(ctx context.Context, id int64) (Message, error) {
+	row := q.db.QueryRowContext(ctx, deleteMessageContent, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.ConversationID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.Keep,
+		&i.ExpiresAt,
+		&i.ClientID,
+		&i.PreviewUrl,
+	)
+	return i, err
+}
+
+const deleteMessageRevisions = `-- name: DeleteMessageRevisions :exec
+DELETE FROM message_revisions
+WHERE message_id = ?
+`
+
+func (q *Queries) DeleteMessageRevisions(ctx context.Context, messageID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteMessageRevisions, messageID)
+	return err
+}
+
+const deleteOldMessages = `-- name: DeleteOldMessages :many
+DELETE FROM messages
+WHERE id IN (
+    SELECT m.id FROM messages m
+    LEFT JOIN conversation_settings s
+        ON s.conversation_id = m.conversation_id
+        OR (s.conversation_id IS NULL
+            AND s.user_id = min(m.sender_id, m.recipient_id)
+            AND s.other_user_id = max(m.sender_id, m.recipient_id))
+    WHERE m.keep = 0
+      AND COALESCE(s.keep, 0) = 0
+      AND COALESCE(s.retention_days, CAST(?1 AS INTEGER)) > 0
+      AND m.created_at < datetime('now', printf('-%d days', COALESCE(s.retention_days, CAST(?1 AS INTEGER))))
+      AND NOT EXISTS (SELECT 1 FROM message_pins pin WHERE pin.message_id = m.id)
+)
+RETURNING id, sender_id, recipient_id, conversation_id
+`
+
+type DeleteOldMessagesRow struct {
+	ID             int64
+	SenderID       int64
+	RecipientID    sql.NullInt64
+	ConversationID sql.NullInt64
+}
+
+// Deletes messages older than their conversation's retention period, or
+// default_days for conversations without one. A period of zero days keeps
+// messages forever, as do the keep flags on messages and conversations.
+// Pinned messages are kept until they are unpinned.
+func (q *Queries) DeleteOldMessages(ctx context.Context, defaultDays int64) ([]DeleteOldMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteOldMessages, defaultDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteOldMessagesRow
+	for rows.Next() {
+		var i DeleteOldMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecipientID,
+			&i.ConversationID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
 const getConversationMessages = `-- name: GetConversationMessages :many
@@ -51,42 +248,103 @@ SELECT
     m.recipient_id,
     m.content,
     m.created_at,
-    u.display_name AS sender_display_name
+    m.edited_at,
+    m.deleted_at,
+    m.reply_to_id,
+    m.expires_at,
+    u.display_name AS sender_display_name,
+    p.content AS reply_content,
+    p.deleted_at AS reply_deleted_at,
+    pu.display_name AS reply_sender_display_name,
+    a.id AS attachment_id,
+    a.filename AS attachment_filename,
+    a.content_type AS attachment_content_type,
+    a.size AS attachment_size,
+    a.width AS attachment_width,
+    a.height AS attachment_height,
+    a.thumbnail_hash AS attachment_thumbnail_hash,
+    lp.url AS preview_url,
+    lp.title AS preview_title,
+    lp.description AS preview_description,
+    lp.image_url AS preview_image_url,
+    lp.site_name AS preview_site_name,
+    pin.pinned_at,
+    CAST(st.message_id IS NOT NULL AS INTEGER) AS starred,
+    CAST(COALESCE((
+        SELECT json_group_array(json_object('emoji', r.emoji, 'user_ids', json(r.user_ids)))
+        FROM (
+            SELECT emoji, json_group_array(user_id) AS user_ids
+            FROM message_reactions
+            WHERE message_id = m.id
+            GROUP BY emoji
+            ORDER BY MIN(rowid)
+        ) r
+    ), '[]') AS TEXT) AS reactions
 FROM messages m
 JOIN users u ON m.sender_id = u.id
-WHERE (m.sender_id = ? AND m.recipient_id = ?)
-   OR (m.sender_id = ? AND m.recipient_id = ?)
-ORDER BY m.created_at DESC
-LIMIT ?
-OFFSET ?
+LEFT JOIN messages p ON p.id = m.reply_to_id
+LEFT JOIN users pu ON pu.id = p.sender_id
+LEFT JOIN attachments a ON a.message_id = m.id
+LEFT JOIN link_previews lp ON lp.url = m.preview_url AND lp.title <> ''
+LEFT JOIN message_pins pin ON pin.message_id = m.id
+LEFT JOIN message_stars st ON st.message_id = m.id AND st.user_id = ?1
+WHERE ((m.sender_id = ?1 AND m.recipient_id = ?2)
+    OR (m.sender_id = ?2 AND m.recipient_id = ?1))
+  AND m.id < ?3
+  AND m.id > ?4
+ORDER BY m.id DESC
+LIMIT ?5
 `
 
 type GetConversationMessagesParams struct {
-	SenderID      int64
-	RecipientID   int64
-	SenderID_2    int64
-	RecipientID_2 int64
-	Limit         int64
-	Offset        int64
+	UserID      int64
+	OtherUserID sql.NullInt64
+	BeforeID    int64
+	AfterID     int64
+	Limit       int64
 }
 
 type GetConversationMessagesRow struct {
-	ID                int64
-	SenderID          int64
-	RecipientID       int64
-	Content           string
-	CreatedAt         string
-	SenderDisplayName string
+	ID                      int64
+	SenderID                int64
+	RecipientID             sql.NullInt64
+	Content                 string
+	CreatedAt               string
+	EditedAt                sql.NullString
+	DeletedAt               sql.NullString
+	ReplyToID               sql.NullInt64
+	ExpiresAt               sql.NullString
+	SenderDisplayName       string
+	ReplyContent            sql.NullString
+	ReplyDeletedAt          sql.NullString
+	ReplySenderDisplayName  sql.NullString
+	AttachmentID            sql.NullInt64
+	AttachmentFilename      sql.NullString
+	AttachmentContentType   sql.NullString
+	AttachmentSize          sql.NullInt64
+	AttachmentWidth         sql.NullInt64
+	AttachmentHeight        sql.NullInt64
+	AttachmentThumbnailHash sql.NullString
+	PreviewUrl              sql.NullString
+	PreviewTitle            sql.NullString
+	PreviewDescription      sql.NullString
+	PreviewImageUrl         sql.NullString
+	PreviewSiteName         sql.NullString
+	PinnedAt                sql.NullString
+	Starred                 int64
+	Reactions               string
 }
 
+// Returns up to limit messages with ids between after_id and before_id, newest first.
+// reactions is a JSON array of {emoji, user_ids}, grouped by emoji in the order first used.
+// starred is 1 if user_id starred the message.
 func (q *Queries) GetConversationMessages(ctx context.Context, arg GetConversationMessagesParams) ([]GetConversationMessagesRow, error) {
 	rows, err := q.db.QueryContext(ctx, getConversationMessages,
-		arg.SenderID,
-		arg.RecipientID,
-		arg.SenderID_2,
-		arg.RecipientID_2,
+		arg.UserID,
+		arg.OtherUserID,
+		arg.BeforeID,
+		arg.AfterID,
 		arg.Limit,
-		arg.Offset,
 	)
 	if err != nil {
 		return nil, err
@@ -101,7 +359,29 @@ func (q *Queries) GetConversationMessages(ctx context.Context, arg GetConversati
 			&i.RecipientID,
 			&i.Content,
 			&i.CreatedAt,
+			&i.EditedAt,
+			&i.DeletedAt,
+			&i.ReplyToID,
+			&i.ExpiresAt,
 			&i.SenderDisplayName,
+			&i.ReplyContent,
+			&i.ReplyDeletedAt,
+			&i.ReplySenderDisplayName,
+			&i.AttachmentID,
+			&i.AttachmentFilename,
+			&i.AttachmentContentType,
+			&i.AttachmentSize,
+			&i.AttachmentWidth,
+			&i.AttachmentHeight,
+			&i.AttachmentThumbnailHash,
+			&i.PreviewUrl,
+			&i.PreviewTitle,
+			&i.PreviewDescription,
+			&i.PreviewImageUrl,
+			&i.PreviewSiteName,
+			&i.PinnedAt,
+			&i.Starred,
+			&i.Reactions,
 		); err != nil {
 			return nil, err
 		}
@@ -116,51 +396,156 @@ func (q *Queries) GetConversationMessages(ctx context.Context, arg GetConversati
 	return items, nil
 }
 
-const getRecentMessagePerUser = `-- name: GetRecentMessagePerUser :many
+const getMessage = `-- name: GetMessage :one
+SELECT id, sender_id, recipient_id, conversation_id, content, created_at, edited_at, deleted_at, reply_to_id, keep, expires_at, client_id, preview_url FROM messages WHERE id = ?
+`
+
+func (q *Queries) GetMessage(ctx context.Context, id int64) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.ConversationID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.Keep,
+		&i.ExpiresAt,
+		&i.ClientID,
+		&i.PreviewUrl,
+	)
+	return i, err
+}
+
+const getSentMessage = `-- name: GetSentMessage :one
 SELECT
-    m.id, m.sender_id, m.recipient_id, m.content, m.created_at,
-    u.display_name AS other_user_display_name
+    m.id,
+    m.sender_id,
+    m.recipient_id,
+    m.conversation_id,
+    m.content,
+    m.created_at,
+    m.edited_at,
+    m.deleted_at,
+    m.reply_to_id,
+    m.expires_at,
+    p.content AS reply_content,
+    p.deleted_at AS reply_deleted_at,
+    pu.display_name AS reply_sender_display_name,
+    a.id AS attachment_id,
+    a.filename AS attachment_filename,
+    a.content_type AS attachment_content_type,
+    a.size AS attachment_size,
+    a.width AS attachment_width,
+    a.height AS attachment_height,
+    a.thumbnail_hash AS attachment_thumbnail_hash,
+    lp.url AS preview_url,
+    lp.title AS preview_title,
+    lp.description AS preview_description,
+    lp.image_url AS preview_image_url,
+    lp.site_name AS preview_site_name
 FROM messages m
-JOIN users u ON u.id = CASE
-    WHEN m.sender_id = ? THEN m.recipient_id
-    ELSE m.sender_id
-END
-WHERE m.sender_id = ? OR m.recipient_id = ?
-ORDER BY m.created_at DESC
+LEFT JOIN messages p ON p.id = m.reply_to_id
+LEFT JOIN users pu ON pu.id = p.sender_id
+LEFT JOIN attachments a ON a.message_id = m.id
+LEFT JOIN link_previews lp ON lp.url = m.preview_url AND lp.title <> ''
+WHERE m.sender_id = ? AND m.client_id = ?
 `
 
-type GetRecentMessagePerUserParams struct {
-	SenderID    int64
-	SenderID_2  int64
-	RecipientID int64
+type GetSentMessageParams struct {
+	SenderID int64
+	ClientID sql.NullString
+}
+
+type GetSentMessageRow struct {
+	ID                      int64
+	SenderID                int64
+	RecipientID             sql.NullInt64
+	ConversationID          sql.NullInt64
+	Content                 string
+	CreatedAt               string
+	EditedAt                sql.NullString
+	DeletedAt               sql.NullString
+	ReplyToID               sql.NullInt64
+	ExpiresAt               sql.NullString
+	ReplyContent            sql.NullString
+	ReplyDeletedAt          sql.NullString
+	ReplySenderDisplayName  sql.NullString
+	AttachmentID            sql.NullInt64
+	AttachmentFilename      sql.NullString
+	AttachmentContentType   sql.NullString
+	AttachmentSize          sql.NullInt64
+	AttachmentWidth         sql.NullInt64
+	AttachmentHeight        sql.NullInt64
+	AttachmentThumbnailHash sql.NullString
+	PreviewUrl              sql.NullString
+	PreviewTitle            sql.NullString
+	PreviewDescription      sql.NullString
+	PreviewImageUrl         sql.NullString
+	PreviewSiteName         sql.NullString
+}
+
+// Finds the message a sender stored with a client-generated ID, for replying
+// to a retried send the same way as the original.
+func (q *Queries) GetSentMessage(ctx context.Context, arg GetSentMessageParams) (GetSentMessageRow, error) {
+	row := q.db.QueryRowContext(ctx, getSentMessage, arg.SenderID, arg.ClientID)
+	var i GetSentMessageRow
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.ConversationID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.ExpiresAt,
+		&i.ReplyContent,
+		&i.ReplyDeletedAt,
+		&i.ReplySenderDisplayName,
+		&i.AttachmentID,
+		&i.AttachmentFilename,
+		&i.AttachmentContentType,
+		&i.AttachmentSize,
+		&i.AttachmentWidth,
+		&i.AttachmentHeight,
+		&i.AttachmentThumbnailHash,
+		&i.PreviewUrl,
+		&i.PreviewTitle,
+		&i.PreviewDescription,
+		&i.PreviewImageUrl,
+		&i.PreviewSiteName,
+	)
+	return i, err
 }
 
-type GetRecentMessagePerUserRow struct {
-	ID                   int64
-	SenderID             int64
-	RecipientID          int64
-	Content              string
-	CreatedAt            string
-	OtherUserDisplayName string
+const listMessageRevisions = `-- name: ListMessageRevisions :many
+SELECT content, replaced_at
+FROM message_revisions
+WHERE message_id = ?
+ORDER BY id
+`
+
+type ListMessageRevisionsRow struct {
+	Content    string
+	ReplacedAt string
 }
 
-func (q *Queries) GetRecentMessagePerUser(ctx context.Context, arg GetRecentMessagePerUserParams) ([]GetRecentMessagePerUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getRecentMessagePerUser, arg.SenderID, arg.SenderID_2, arg.RecipientID)
+func (q *Queries) ListMessageRevisions(ctx context.Context, messageID int64) ([]ListMessageRevisionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listMessageRevisions, messageID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetRecentMessagePerUserRow
+	var items []ListMessageRevisionsRow
 	for rows.Next() {
-		var i GetRecentMessagePerUserRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.SenderID,
-			&i.RecipientID,
-			&i.Content,
-			&i.CreatedAt,
-			&i.OtherUserDisplayName,
-		); err != nil {
+		var i ListMessageRevisionsRow
+		if err := rows.Scan(&i.Content, &i.ReplacedAt); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -173,3 +558,77 @@ func (q *Queries) GetRecentMessagePerUser(ctx context.Context, arg GetRecentMess
 	}
 	return items, nil
 }
+
+const setMessageKeep = `-- name: SetMessageKeep :execresult
+UPDATE messages
+SET keep = ?
+WHERE id = ?
+`
+
+type SetMessageKeepParams struct {
+	Keep int64
+	ID   int64
+}
+
+func (q *Queries) SetMessageKeep(ctx context.Context, arg SetMessageKeepParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, setMessageKeep, arg.Keep, arg.ID)
+}
+
+const setMessagePreview = `-- name: SetMessagePreview :execresult
+UPDATE messages
+SET preview_url = ?1
+WHERE id = ?2
+  AND deleted_at IS NULL
+  AND content = ?3
+`
+
+type SetMessagePreviewParams struct {
+	PreviewUrl sql.NullString
+	ID         int64
+	Content    string
+}
+
+// Links a message to its fetched preview, unless it was deleted or edited
+// meanwhile: content must still be the text the link was found in.
+func (q *Queries) SetMessagePreview(ctx context.Context, arg SetMessagePreviewParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, setMessagePreview, arg.PreviewUrl, arg.ID, arg.Content)
+}
+
+const updateMessageContent = `-- name: UpdateMessageContent :one
+UPDATE messages
+SET content = ?1, edited_at = datetime('now'), preview_url = NULL
+WHERE id = ?2
+  AND content = ?3
+  AND deleted_at IS NULL
+RETURNING id, sender_id, recipient_id, conversation_id, content, created_at, edited_at, deleted_at, reply_to_id, keep, expires_at, client_id, preview_url
+`
+
+type UpdateMessageContentParams struct {
+	Content         string
+	ID              int64
+	PreviousContent string
+}
+
+// Clears the link preview, which is fetched again for the new text. Only
+// matches while the message still has previous_content and isn't deleted,
+// so an edit based on a stale read changes nothing.
+func (q *Queries) UpdateMessageContent(ctx context.Context, arg UpdateMessageContentParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, updateMessageContent, arg.Content, arg.ID, arg.PreviousContent)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.ConversationID,
+		&i.Content,
+		&i.CreatedAt,
+		&i.EditedAt,
+		&i.DeletedAt,
+		&i.ReplyToID,
+		&i.Keep,
+		&i.ExpiresAt,
+		&i.ClientID,
+		&i.PreviewUrl,
+	)
+	return i, err
+}
@@ -8,6 +8,81 @@ import (
 	"database/sql"
 )
 
+type Attachment struct {
+	ID            int64
+	MessageID     int64
+	Hash          string
+	ThumbnailHash sql.NullString
+	Filename      string
+	ContentType   string
+	Size          int64
+	Width         sql.NullInt64
+	Height        sql.NullInt64
+	CreatedAt     string
+}
+
+type Conversation struct {
+	ID        int64
+	Name      string
+	CreatedBy sql.NullInt64
+	CreatedAt string
+}
+
+type ConversationMember struct {
+	ConversationID       int64
+	UserID               int64
+	JoinedAt             string
+	JoinedAfterMessageID int64
+	LastReadMessageID    int64
+}
+
+type ConversationRead struct {
+	UserID                 int64
+	OtherUserID            int64
+	LastDeliveredMessageID int64
+	LastReadMessageID      int64
+	UpdatedAt              string
+}
+
+type ConversationSetting struct {
+	UserID           sql.NullInt64
+	OtherUserID      sql.NullInt64
+	ConversationID   sql.NullInt64
+	RetentionDays    sql.NullInt64
+	Keep             int64
+	UpdatedAt        string
+	DisappearSeconds sql.NullInt64
+}
+
+type ConversationSummary struct {
+	UserID               int64
+	OtherUserID          sql.NullInt64
+	ConversationID       sql.NullInt64
+	LastMessageID        sql.NullInt64
+	LastMessage          string
+	LastMessageDeletedAt sql.NullString
+	LastMessageAt        string
+	UnreadCount          int64
+	UpdatedAt            string
+}
+
+type Draft struct {
+	UserID         int64
+	OtherUserID    sql.NullInt64
+	ConversationID sql.NullInt64
+	Content        string
+	UpdatedAt      string
+}
+
+type HubNotification struct {
+	ID        int64
+	NodeID    string
+	UserID    int64
+	Seq       int64
+	Data      string
+	CreatedAt string
+}
+
 type Invitation struct {
 	Token     string
 	Email     string
@@ -16,6 +91,15 @@ type Invitation struct {
 	ExpiresAt string
 }
 
+type LinkPreview struct {
+	Url         string
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	FetchedAt   string
+}
+
 type MagicLink struct {
 	Token     string
 	UserID    int64
@@ -24,11 +108,62 @@ type MagicLink struct {
 }
 
 type Message struct {
-	ID          int64
-	SenderID    int64
-	RecipientID int64
-	Content     string
-	CreatedAt   string
+	ID             int64
+	SenderID       int64
+	RecipientID    sql.NullInt64
+	ConversationID sql.NullInt64
+	Content        string
+	CreatedAt      string
+	EditedAt       sql.NullString
+	DeletedAt      sql.NullString
+	ReplyToID      sql.NullInt64
+	Keep           int64
+	ExpiresAt      sql.NullString
+	ClientID       sql.NullString
+	PreviewUrl     sql.NullString
+}
+
+type MessagePin struct {
+	MessageID int64
+	PinnedBy  sql.NullInt64
+	PinnedAt  string
+}
+
+type MessageReaction struct {
+	MessageID int64
+	UserID    int64
+	Emoji     string
+	CreatedAt string
+}
+
+type MessageRevision struct {
+	ID         int64
+	MessageID  int64
+	Content    string
+	ReplacedAt string
+}
+
+type MessageStar struct {
+	UserID    int64
+	MessageID int64
+	CreatedAt string
+}
+
+type MessagesFt struct {
+	Content string
+}
+
+type ScheduledMessage struct {
+	ID             int64
+	SenderID       int64
+	RecipientID    sql.NullInt64
+	ConversationID sql.NullInt64
+	Content        string
+	SendAt         string
+	CreatedAt      string
+	ClaimedAt      sql.NullString
+	FailedAt       sql.NullString
+	Failure        sql.NullString
 }
 
 type Session struct {
@@ -46,4 +181,14 @@ type User struct {
 	IsAdmin      int64
 	CreatedAt    string
 	Email        sql.NullString
+	LastSeenAt   sql.NullString
+	ReadReceipts int64
+}
+
+type UserEvent struct {
+	UserID    int64
+	Seq       int64
+	Type      string
+	Payload   string
+	CreatedAt string
 }
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func scheduleTestMessage(t *testing.T, q *Queries, from, to User, sendAt string) ScheduledMessage {
+	t.Helper()
+	s, err := q.CreateScheduledMessage(context.Background(), CreateScheduledMessageParams{
+		SenderID:    from.ID,
+		RecipientID: sql.NullInt64{Int64: to.ID, Valid: true},
+		Content:     "later",
+		SendAt:      sendAt,
+	})
+	if err != nil {
+		t.Fatalf("CreateScheduledMessage: %v", err)
+	}
+	return s
+}
+
+// claimIDs claims the due messages and returns their IDs.
+func claimIDs(t *testing.T, q *Queries) []int64 {
+	t.Helper()
+	claimed, err := q.ClaimDueScheduledMessages(context.Background())
+	if err != nil {
+		t.Fatalf("ClaimDueScheduledMessages: %v", err)
+	}
+	var ids []int64
+	for _, s := range claimed {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestScheduledMessageClaims(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+
+	due := scheduleTestMessage(t, q, alice, bob, "2000-01-01 00:00:00")
+	scheduleTestMessage(t, q, alice, bob, "2999-01-01 00:00:00")
+
+	if got := claimIDs(t, q); len(got) != 1 || got[0] != due.ID {
+		t.Fatalf("first claim = %v, want [%d]", got, due.ID)
+	}
+	if got := claimIDs(t, q); len(got) != 0 {
+		t.Errorf("second claim took %v, which are already claimed", got)
+	}
+
+	// A claimed message is being sent, so its sender can no longer change it
+	listed, err := q.ListScheduledMessages(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("ListScheduledMessages: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID == due.ID {
+		t.Errorf("listed %+v, want only the future message", listed)
+	}
+	res, err := q.DeleteScheduledMessage(ctx, DeleteScheduledMessageParams{ID: due.ID, SenderID: alice.ID})
+	if err != nil {
+		t.Fatalf("DeleteScheduledMessage: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 0 {
+		t.Errorf("deleted a claimed message")
+	}
+
+	// Released messages are tried again
+	if err := q.ReleaseScheduledMessage(ctx, due.ID); err != nil {
+		t.Fatalf("ReleaseScheduledMessage: %v", err)
+	}
+	if got := claimIDs(t, q); len(got) != 1 || got[0] != due.ID {
+		t.Fatalf("claim after release = %v, want [%d]", got, due.ID)
+	}
+
+	// So are messages whose claim was left behind by a process that stopped
+	if _, err := q.db.ExecContext(ctx, "UPDATE scheduled_messages SET claimed_at = datetime('now', '-10 minutes') WHERE id = ?", due.ID); err != nil {
+		t.Fatalf("aging claim: %v", err)
+	}
+	if got := claimIDs(t, q); len(got) != 1 || got[0] != due.ID {
+		t.Fatalf("claim after the claim went stale = %v, want [%d]", got, due.ID)
+	}
+
+	if err := q.CompleteScheduledMessage(ctx, due.ID); err != nil {
+		t.Fatalf("CompleteScheduledMessage: %v", err)
+	}
+	if err := q.ReleaseScheduledMessage(ctx, due.ID); err != nil {
+		t.Fatalf("ReleaseScheduledMessage: %v", err)
+	}
+	if got := claimIDs(t, q); len(got) != 0 {
+		t.Errorf("claimed %v after the message was sent", got)
+	}
+}
+
+func TestFailedScheduledMessage(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+
+	due := scheduleTestMessage(t, q, alice, bob, "2000-01-01 00:00:00")
+	claimIDs(t, q)
+	if err := q.FailScheduledMessage(ctx, FailScheduledMessageParams{
+		Failure: sql.NullString{String: "recipient not found", Valid: true},
+		ID:      due.ID,
+	}); err != nil {
+		t.Fatalf("FailScheduledMessage: %v", err)
+	}
+	if got := claimIDs(t, q); len(got) != 0 {
+		t.Errorf("claimed failed messages %v", got)
+	}
+
+	// The sender sees the failure and can reschedule
+	got, err := q.GetScheduledMessage(ctx, GetScheduledMessageParams{ID: due.ID, SenderID: alice.ID})
+	if err != nil {
+		t.Fatalf("GetScheduledMessage: %v", err)
+	}
+	if !got.FailedAt.Valid || got.Failure.String != "recipient not found" {
+		t.Errorf("failed message = %+v", got)
+	}
+	if _, err := q.UpdateScheduledMessage(ctx, UpdateScheduledMessageParams{
+		Content:  got.Content,
+		SendAt:   got.SendAt,
+		ID:       due.ID,
+		SenderID: alice.ID,
+	}); err != nil {
+		t.Fatalf("UpdateScheduledMessage: %v", err)
+	}
+	if got := claimIDs(t, q); len(got) != 1 || got[0] != due.ID {
+		t.Errorf("claim after rescheduling = %v, want [%d]", got, due.ID)
+	}
+}
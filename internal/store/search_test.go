@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// searchIDs returns the IDs of the messages user finds with query.
+func searchIDs(t *testing.T, q *Queries, user User, query string) []int64 {
+	t.Helper()
+	rows, err := q.SearchMessages(context.Background(), SearchMessagesParams{UserID: user.ID, Query: query, Limit: 50})
+	if err != nil {
+		t.Fatalf("SearchMessages %q: %v", query, err)
+	}
+	var ids []int64
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids
+}
+
+func TestSearchMessages(t *testing.T) {
+	ctx := context.Background()
+	q := newTestQueries(t)
+	alice := createTestUser(t, q, "alice")
+	bob := createTestUser(t, q, "bob")
+	carol := createTestUser(t, q, "carol")
+
+	picnic := sendTestMessage(t, q, alice, bob, "Picnic on Saturday?")
+	other := sendTestMessage(t, q, alice, bob, "Bring the blanket")
+
+	if got := searchIDs(t, q, bob, "picnic"); !slices.Equal(got, []int64{picnic.ID}) {
+		t.Errorf("bob's search for picnic = %v, want [%d]", got, picnic.ID)
+	}
+	if got := searchIDs(t, q, carol, "picnic"); len(got) != 0 {
+		t.Errorf("carol found %v in a conversation she isn't in", got)
+	}
+
+	// Edits replace the indexed text
+	if _, err := q.UpdateMessageContent(ctx, UpdateMessageContentParams{
+		ID:              picnic.ID,
+		Content:         "Barbecue on Saturday?",
+		PreviousContent: picnic.Content,
+	}); err != nil {
+		t.Fatalf("UpdateMessageContent: %v", err)
+	}
+	if got := searchIDs(t, q, bob, "picnic"); len(got) != 0 {
+		t.Errorf("search for edited-out text = %v", got)
+	}
+	if got := searchIDs(t, q, bob, "barbecue"); !slices.Equal(got, []int64{picnic.ID}) {
+		t.Errorf("search for edited-in text = %v, want [%d]", got, picnic.ID)
+	}
+
+	// Unsent and expired messages can't be found
+	if _, err := q.DeleteMessageContent(ctx, picnic.ID); err != nil {
+		t.Fatalf("DeleteMessageContent: %v", err)
+	}
+	if got := searchIDs(t, q, bob, "barbecue"); len(got) != 0 {
+		t.Errorf("search found unsent messages %v", got)
+	}
+	if err := q.DeleteMessage(ctx, other.ID); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if got := searchIDs(t, q, bob, "blanket"); len(got) != 0 {
+		t.Errorf("search found expired messages %v", got)
+	}
+
+	// Group members only find what was sent since they joined
+	group := createTestGroup(t, q, "family", alice, bob)
+	before := sendTestGroupMessage(t, q, alice, group, "Dinner at six")
+	joinTestGroup(t, q, group, carol)
+	after := sendTestGroupMessage(t, q, bob, group, "Dinner moved to seven")
+
+	if got := searchIDs(t, q, bob, "dinner"); !slices.Equal(got, []int64{after.ID, before.ID}) {
+		t.Errorf("bob's search for dinner = %v, want [%d %d]", got, after.ID, before.ID)
+	}
+	if got := searchIDs(t, q, carol, "dinner"); !slices.Equal(got, []int64{after.ID}) {
+		t.Errorf("carol's search for dinner = %v, want [%d]", got, after.ID)
+	}
+}
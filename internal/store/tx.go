@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// errNoBeginTx is returned by InTx when the queries aren't backed by a
+// database that can begin transactions, such as when already in one.
+var errNoBeginTx = errors.New("store: can't begin a transaction")
+
+// InTx runs fn with queries bound to a new transaction, committing it if fn
+// returns nil and rolling it back otherwise. SQLite takes the write lock at
+// the first write, so fn should write before it reads anything it relies on.
+func (q *Queries) InTx(ctx context.Context, fn func(*Queries) error) error {
+	db, ok := q.db.(interface {
+		BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+	})
+	if !ok {
+		return errNoBeginTx
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(q.WithTx(tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -13,7 +13,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (username, display_name, password_hash, is_admin)
 VALUES (?, ?, ?, ?)
-RETURNING id, username, display_name, password_hash, is_admin, created_at, email
+RETURNING id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts
 `
 
 type CreateUserParams struct {
@@ -39,6 +39,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.Email,
+		&i.LastSeenAt,
+		&i.ReadReceipts,
 	)
 	return i, err
 }
@@ -46,7 +48,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 const createUserWithEmail = `-- name: CreateUserWithEmail :one
 INSERT INTO users (username, display_name, password_hash, email, is_admin)
 VALUES (?, ?, ?, ?, ?)
-RETURNING id, username, display_name, password_hash, is_admin, created_at, email
+RETURNING id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts
 `
 
 type CreateUserWithEmailParams struct {
@@ -74,6 +76,8 @@ func (q *Queries) CreateUserWithEmail(ctx context.Context, arg CreateUserWithEma
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.Email,
+		&i.LastSeenAt,
+		&i.ReadReceipts,
 	)
 	return i, err
 }
@@ -88,7 +92,7 @@ func (q *Queries) DeleteUser(ctx context.Context, id int64) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, display_name, password_hash, is_admin, created_at, email FROM users WHERE email = ?
+SELECT id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts FROM users WHERE email = ?
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (User, error) {
@@ -102,12 +106,14 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email sql.NullString) (Use
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.Email,
+		&i.LastSeenAt,
+		&i.ReadReceipts,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, display_name, password_hash, is_admin, created_at, email FROM users WHERE id = ?
+SELECT id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts FROM users WHERE id = ?
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
@@ -121,12 +127,14 @@ func (q *Queries) GetUserByID(ctx context.Context, id int64) (User, error) {
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.Email,
+		&i.LastSeenAt,
+		&i.ReadReceipts,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, username, display_name, password_hash, is_admin, created_at, email FROM users WHERE username = ?
+SELECT id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts FROM users WHERE username = ?
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -140,12 +148,14 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.IsAdmin,
 		&i.CreatedAt,
 		&i.Email,
+		&i.LastSeenAt,
+		&i.ReadReceipts,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, username, display_name, password_hash, is_admin, created_at, email FROM users ORDER BY display_name
+SELECT id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts FROM users ORDER BY display_name
 `
 
 func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
@@ -165,6 +175,8 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.IsAdmin,
 			&i.CreatedAt,
 			&i.Email,
+			&i.LastSeenAt,
+			&i.ReadReceipts,
 		); err != nil {
 			return nil, err
 		}
@@ -180,7 +192,7 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 }
 
 const listUsersExcept = `-- name: ListUsersExcept :many
-SELECT id, username, display_name, password_hash, is_admin, created_at, email FROM users WHERE id != ? ORDER BY display_name
+SELECT id, username, display_name, password_hash, is_admin, created_at, email, last_seen_at, read_receipts FROM users WHERE id != ? ORDER BY display_name
 `
 
 func (q *Queries) ListUsersExcept(ctx context.Context, id int64) ([]User, error) {
@@ -200,6 +212,8 @@ func (q *Queries) ListUsersExcept(ctx context.Context, id int64) ([]User, error)
 			&i.IsAdmin,
 			&i.CreatedAt,
 			&i.Email,
+			&i.LastSeenAt,
+			&i.ReadReceipts,
 		); err != nil {
 			return nil, err
 		}
@@ -266,3 +280,26 @@ func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams
 	_, err := q.db.ExecContext(ctx, updateUserEmail, arg.Email, arg.ID)
 	return err
 }
+
+const updateUserLastSeen = `-- name: UpdateUserLastSeen :exec
+UPDATE users SET last_seen_at = datetime('now') WHERE id = ?
+`
+
+func (q *Queries) UpdateUserLastSeen(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, updateUserLastSeen, id)
+	return err
+}
+
+const updateUserReadReceipts = `-- name: UpdateUserReadReceipts :exec
+UPDATE users SET read_receipts = ? WHERE id = ?
+`
+
+type UpdateUserReadReceiptsParams struct {
+	ReadReceipts int64
+	ID           int64
+}
+
+func (q *Queries) UpdateUserReadReceipts(ctx context.Context, arg UpdateUserReadReceiptsParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserReadReceipts, arg.ReadReceipts, arg.ID)
+	return err
+}
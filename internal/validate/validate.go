@@ -27,6 +27,10 @@ var (
 	ErrEmailEmpty         = errors.New("email is required")
 	ErrEmailTooLong       = errors.New("email must be at most 254 characters")
 	ErrEmailInvalid       = errors.New("invalid email address")
+	ErrGroupNameEmpty     = errors.New("group name is required")
+	ErrGroupNameTooLong   = errors.New("group name must be at most 64 characters")
+	ErrReactionEmpty      = errors.New("reaction is required")
+	ErrReactionInvalid    = errors.New("reaction must be a single emoji")
 )
 
 // Username validates a username.
@@ -89,6 +93,19 @@ func Message(s string) error {
 	return nil
 }
 
+// GroupName validates a group conversation name.
+// Must be 1-64 characters.
+func GroupName(s string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ErrGroupNameEmpty
+	}
+	if utf8.RuneCountInString(s) > 64 {
+		return ErrGroupNameTooLong
+	}
+	return nil
+}
+
 // Email validates an email address.
 // Must be a valid email format and at most 254 characters.
 func Email(s string) error {
@@ -104,3 +121,21 @@ func Email(s string) error {
 	}
 	return nil
 }
+
+// Reaction validates an emoji reaction.
+// Must be 1-10 characters, none of them ASCII, which allows emoji
+// joined into a sequence but rejects plain text.
+func Reaction(s string) error {
+	if s == "" {
+		return ErrReactionEmpty
+	}
+	if utf8.RuneCountInString(s) > 10 {
+		return ErrReactionInvalid
+	}
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			return ErrReactionInvalid
+		}
+	}
+	return nil
+}
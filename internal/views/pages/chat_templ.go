@@ -10,8 +10,11 @@ import templruntime "github.com/a-h/templ/runtime"
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/dukerupert/wantok/internal/components/badge"
 	"github.com/dukerupert/wantok/internal/components/button"
+	"github.com/dukerupert/wantok/internal/components/checkbox"
 	"github.com/dukerupert/wantok/internal/components/dialog"
 	"github.com/dukerupert/wantok/internal/components/input"
 	"github.com/dukerupert/wantok/internal/views/layouts"
@@ -19,11 +22,16 @@ import (
 )
 
 // ConversationListItem represents a conversation in the sidebar.
+// Group conversations set GroupID and use the group's name as DisplayName.
 type ConversationListItem struct {
 	UserID          int64
+	GroupID         int64
 	DisplayName     string
 	LastMessage     string
 	LastMessageTime string
+	Status          string // "online", "away" or "offline"
+	LastSeenAt      string
+	UnreadCount     int64
 }
 
 // MessageItem represents a single message in a conversation.
@@ -34,20 +42,135 @@ type MessageItem struct {
 	SenderName string
 	CreatedAt  string
 	IsSent     bool
+	Status     string // "sent", "delivered" or "read"; only for sent messages
+	EditedAt   string // Set once the sender has edited the message
+	Editable   bool   // Sent by the current user and still within the edit window
+	Deleted    bool   // Deleted by the sender; content is empty
+	ReplyTo    *partials.ReplyProps
+	Reactions  []partials.ReactionProps
+	Attachment *partials.AttachmentProps
+	Preview    *partials.PreviewProps
+	Pinned     bool
+	Starred    bool // By the current user
 }
 
 // ChatPageData holds data for the chat template.
 type ChatPageData struct {
-	Conversations   []ConversationListItem
-	ActiveUserID    int64
-	ActiveUserName  string
-	Messages        []MessageItem
-	CurrentUserID   int64
-	CurrentUserName string
-	IsAdmin         bool
+	Conversations      []ConversationListItem
+	ActiveUserID       int64
+	ActiveUserName     string
+	ActiveUserStatus   string // "online", "away" or "offline"
+	ActiveUserLastSeen string
+	ActiveGroupID      int64
+	ActiveGroupName    string
+	ActiveGroupMembers []string                // Display names, in join order
+	GroupCandidates    []partials.UserListItem // Users who can be added to the active group
+	Messages           []MessageItem
+	CurrentUserID      int64
+	CurrentUserName    string
+	IsAdmin            bool
+	LastEventSeq       int64     // Realtime events up to this sequence number are reflected in the page
+	EditingEnabled     bool      // Whether new messages can be edited after sending
+	FocusMessageID     int64     // Message to scroll to, when linked from a search result
+	OlderMessagesURL   string    // Loads the messages before the oldest shown; empty when there are none
+	Draft              string    // Unsent text saved for the active conversation
+	DisappearSeconds   int64     // Disappearing-message timer of the active conversation; zero when off
+	Pinned             []PinItem // Pinned messages in the active conversation, most recently pinned first
 }
 
-func Chat(data ChatPageData) templ.Component {
+// PinItem is a message in the pinned bar of the open conversation.
+type PinItem struct {
+	ID           int64
+	SenderName   string
+	Content      string
+	PinnedByName string
+	URL          string // Opens the chat page at the message
+}
+
+// HasActiveConversation reports whether a direct or group conversation is open.
+func (d ChatPageData) HasActiveConversation() bool {
+	return d.ActiveUserID > 0 || d.ActiveGroupID > 0
+}
+
+// conversationURL links to a sidebar conversation.
+func conversationURL(conv ConversationListItem) templ.SafeURL {
+	if conv.GroupID > 0 {
+		return templ.SafeURL(fmt.Sprintf("/?group=%d", conv.GroupID))
+	}
+	return templ.SafeURL(fmt.Sprintf("/?user=%d", conv.UserID))
+}
+
+// isActiveConversation reports whether a sidebar conversation is the one open.
+func isActiveConversation(conv ConversationListItem, data ChatPageData) bool {
+	if conv.GroupID > 0 {
+		return conv.GroupID == data.ActiveGroupID
+	}
+	return conv.UserID == data.ActiveUserID
+}
+
+// sendURL is where the message form posts for the open conversation.
+func sendURL(data ChatPageData) string {
+	if data.ActiveGroupID > 0 {
+		return fmt.Sprintf("/groups/%d/messages", data.ActiveGroupID)
+	}
+	return fmt.Sprintf("/conversations/%d/messages", data.ActiveUserID)
+}
+
+// groupSenderName labels received messages with their sender in group conversations.
+func groupSenderName(msg MessageItem, data ChatPageData) string {
+	if data.ActiveGroupID == 0 {
+		return ""
+	}
+	return msg.SenderName
+}
+
+// timerURL is where the disappearing-message timer of the open conversation is set.
+func timerURL(data ChatPageData) string {
+	if data.ActiveGroupID > 0 {
+		return fmt.Sprintf("/groups/%d/timer", data.ActiveGroupID)
+	}
+	return fmt.Sprintf("/conversations/%d/timer", data.ActiveUserID)
+}
+
+// timerOption is a choice in the disappearing-message timer menu.
+type timerOption struct {
+	Seconds int64
+	Label   string
+}
+
+// timerOptions lists the timer presets, plus the current timer if it was set
+// to something else through the API.
+func timerOptions(current int64) []timerOption {
+	options := []timerOption{
+		{0, "Off"},
+		{5 * 60, "5 minutes"},
+		{60 * 60, "1 hour"},
+		{24 * 60 * 60, "1 day"},
+		{7 * 24 * 60 * 60, "1 week"},
+	}
+	for _, option := range options {
+		if option.Seconds == current {
+			return options
+		}
+	}
+	return append(options, timerOption{current, fmt.Sprintf("%d seconds", current)})
+}
+
+// presenceLabel describes a user's presence for display.
+func presenceLabel(status, lastSeen string) string {
+	switch status {
+	case "online", "away":
+		return status
+	}
+	if lastSeen != "" {
+		return "last seen " + lastSeen
+	}
+	return "offline"
+}
+
+// pinnedBar lists the open conversation's pinned messages under its header.
+// Hidden while nothing is pinned.
+func pinnedBar(pins []PinItem) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -68,7 +191,234 @@ func Chat(data ChatPageData) templ.Component {
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Var2 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		var templ_7745c5c3_Var2 = []any{"border-b px-4 py-2 text-xs space-y-1 overflow-y-auto", templ.KV("hidden", len(pins) == 0)}
+		templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var2...)
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div id=\"pinned-bar\" class=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var3 string
+		templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var2).String())
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "\" style=\"max-height: 6rem;\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		for _, pin := range pins {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<a href=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var4 templ.SafeURL
+			templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(pin.URL))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 168, Col: 35}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "\" class=\"block truncate hover:underline\" title=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var5 string
+			templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(pinnedTitle(pin.PinnedByName))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 168, Col: 114}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "\" data-pinned-id=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var6 string
+			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(pin.ID))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 168, Col: 152}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "\">📌 <span class=\"font-medium\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var7 string
+			templ_7745c5c3_Var7, templ_7745c5c3_Err = templ.JoinStringErrs(pin.SenderName)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 169, Col: 51}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var7))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, ":</span> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var8 string
+			templ_7745c5c3_Var8, templ_7745c5c3_Err = templ.JoinStringErrs(pinnedSnippet(pin.Content))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 169, Col: 90}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var8))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</a>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</div>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+// pinnedTitle says who pinned a message in the pinned bar.
+func pinnedTitle(pinnedByName string) string {
+	if pinnedByName == "" {
+		return "Pinned"
+	}
+	return "Pinned by " + pinnedByName
+}
+
+// pinnedSnippet stands in for the text of a pinned message with only an attachment.
+func pinnedSnippet(content string) string {
+	if content == "" {
+		return "Attachment"
+	}
+	return content
+}
+
+// timerSelect sets how long after sending messages in the open conversation disappear.
+func timerSelect(data ChatPageData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var9 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var9 == nil {
+			templ_7745c5c3_Var9 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "<select id=\"disappear-timer\" name=\"seconds\" title=\"Disappearing messages\" aria-label=\"Disappearing messages\" class=\"h-8 rounded-md border border-input bg-background px-2 text-xs\" hx-post=\"")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		var templ_7745c5c3_Var10 string
+		templ_7745c5c3_Var10, templ_7745c5c3_Err = templ.JoinStringErrs(timerURL(data))
+		if templ_7745c5c3_Err != nil {
+			return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 199, Col: 26}
+		}
+		_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var10))
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "\" hx-trigger=\"change\" hx-swap=\"none\">")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		for _, option := range timerOptions(data.DisappearSeconds) {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, "<option value=\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var11 string
+			templ_7745c5c3_Var11, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(option.Seconds))
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 204, Col: 45}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var11))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "\"")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if option.Seconds == data.DisappearSeconds {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, " selected")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, ">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var12 string
+			templ_7745c5c3_Var12, templ_7745c5c3_Err = templ.JoinStringErrs(option.Label)
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 204, Col: 116}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var12))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, "</option>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "</select>")
+		if templ_7745c5c3_Err != nil {
+			return templ_7745c5c3_Err
+		}
+		return nil
+	})
+}
+
+func Chat(data ChatPageData) templ.Component {
+	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
+			return templ_7745c5c3_CtxErr
+		}
+		templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+		if !templ_7745c5c3_IsBuffer {
+			defer func() {
+				templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err == nil {
+					templ_7745c5c3_Err = templ_7745c5c3_BufErr
+				}
+			}()
+		}
+		ctx = templ.InitializeContext(ctx)
+		templ_7745c5c3_Var13 := templ.GetChildren(ctx)
+		if templ_7745c5c3_Var13 == nil {
+			templ_7745c5c3_Var13 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		templ_7745c5c3_Var14 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 			templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 			templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 			if !templ_7745c5c3_IsBuffer {
@@ -80,44 +430,44 @@ func Chat(data ChatPageData) templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"h-screen flex flex-col\"><!-- Header --><header class=\"bg-card border-b px-4 py-3 flex justify-between items-center\"><div class=\"flex items-center gap-3\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<div class=\"h-screen flex flex-col\"><!-- Header --><header class=\"bg-card border-b px-4 py-3 flex justify-between items-center\"><div class=\"flex items-center gap-3\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			if data.ActiveUserID > 0 {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<!-- Back button on mobile when in conversation --> <a href=\"/\" class=\"md:hidden p-2 -ml-2 text-muted-foreground hover:text-foreground\" aria-label=\"Back to conversations\"><svg xmlns=\"http://www.w3.org/2000/svg\" class=\"h-6 w-6\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M15 19l-7-7 7-7\"></path></svg></a>")
+			if data.HasActiveConversation() {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "<!-- Back button on mobile when in conversation --> <a href=\"/\" class=\"md:hidden p-2 -ml-2 text-muted-foreground hover:text-foreground\" aria-label=\"Back to conversations\"><svg xmlns=\"http://www.w3.org/2000/svg\" class=\"h-6 w-6\" fill=\"none\" viewBox=\"0 0 24 24\" stroke=\"currentColor\"><path stroke-linecap=\"round\" stroke-linejoin=\"round\" stroke-width=\"2\" d=\"M15 19l-7-7 7-7\"></path></svg></a>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<h1 class=\"text-xl font-bold\">Wantok</h1></div><div class=\"flex items-center gap-2 sm:gap-4\"><span class=\"text-muted-foreground text-sm sm:text-base truncate max-w-[100px] sm:max-w-none\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "<h1 class=\"text-xl font-bold\">Wantok</h1></div><div class=\"flex items-center gap-2 sm:gap-4\"><span class=\"text-muted-foreground text-sm sm:text-base truncate max-w-[100px] sm:max-w-none\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var3 string
-			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUserName)
+			var templ_7745c5c3_Var15 string
+			templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinStringErrs(data.CurrentUserName)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 59, Col: 121}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 226, Col: 121}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</span> ")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "</span> <a href=\"/preferences\" class=\"text-primary hover:text-primary/80 text-sm sm:text-base\">Settings</a> ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
 			if data.IsAdmin {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "<a href=\"/admin\" class=\"text-primary hover:text-primary/80 text-sm sm:text-base\">Admin</a>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "<a href=\"/admin\" class=\"text-primary hover:text-primary/80 text-sm sm:text-base\">Admin</a>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<form action=\"/auth/logout\" method=\"POST\" class=\"inline\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "<form action=\"/auth/logout\" method=\"POST\" class=\"inline\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Var4 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_Var16 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 				templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 				templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 				if !templ_7745c5c3_IsBuffer {
@@ -129,7 +479,7 @@ func Chat(data ChatPageData) templ.Component {
 					}()
 				}
 				ctx = templ.InitializeContext(ctx)
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "Logout")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "Logout")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -139,37 +489,37 @@ func Chat(data ChatPageData) templ.Component {
 				Type:    button.TypeSubmit,
 				Variant: button.VariantGhost,
 				Size:    button.SizeSm,
-			}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var4), templ_7745c5c3_Buffer)
+			}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var16), templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</form></div></header><div class=\"flex-1 flex overflow-hidden\"><!-- Sidebar -->")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "</form></div></header><div class=\"flex-1 flex overflow-hidden\"><!-- Sidebar -->")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var5 = []any{"w-full md:w-80 bg-muted/30 border-r flex flex-col", templ.KV("hidden md:flex", data.ActiveUserID > 0)}
-			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var5...)
+			var templ_7745c5c3_Var17 = []any{"w-full md:w-80 bg-muted/30 border-r flex flex-col", templ.KV("hidden md:flex", data.HasActiveConversation())}
+			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var17...)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "<aside class=\"")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "<aside class=\"")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var6 string
-			templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var5).String())
+			var templ_7745c5c3_Var18 string
+			templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var17).String())
 			if templ_7745c5c3_Err != nil {
 				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "\"><!-- New Conversation Button --><div class=\"p-4 border-b\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "\"><!-- New Conversation Button --><div class=\"p-4 border-b\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Var7 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+			templ_7745c5c3_Var19 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 				templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 				templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 				if !templ_7745c5c3_IsBuffer {
@@ -181,7 +531,7 @@ func Chat(data ChatPageData) templ.Component {
 					}()
 				}
 				ctx = templ.InitializeContext(ctx)
-				templ_7745c5c3_Var8 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+				templ_7745c5c3_Var20 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 					templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 					templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 					if !templ_7745c5c3_IsBuffer {
@@ -193,7 +543,7 @@ func Chat(data ChatPageData) templ.Component {
 						}()
 					}
 					ctx = templ.InitializeContext(ctx)
-					templ_7745c5c3_Var9 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+					templ_7745c5c3_Var21 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 						if !templ_7745c5c3_IsBuffer {
@@ -205,7 +555,7 @@ func Chat(data ChatPageData) templ.Component {
 							}()
 						}
 						ctx = templ.InitializeContext(ctx)
-						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "New Conversation")
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "New Conversation")
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
@@ -213,21 +563,21 @@ func Chat(data ChatPageData) templ.Component {
 					})
 					templ_7745c5c3_Err = button.Button(button.Props{
 						FullWidth: true,
-					}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var9), templ_7745c5c3_Buffer)
+					}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var21), templ_7745c5c3_Buffer)
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 					return nil
 				})
-				templ_7745c5c3_Err = dialog.Trigger().Render(templ.WithChildren(ctx, templ_7745c5c3_Var8), templ_7745c5c3_Buffer)
+				templ_7745c5c3_Err = dialog.Trigger().Render(templ.WithChildren(ctx, templ_7745c5c3_Var20), templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 12, " ")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, " ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Var10 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+				templ_7745c5c3_Var22 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 					templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 					templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 					if !templ_7745c5c3_IsBuffer {
@@ -239,7 +589,7 @@ func Chat(data ChatPageData) templ.Component {
 						}()
 					}
 					ctx = templ.InitializeContext(ctx)
-					templ_7745c5c3_Var11 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+					templ_7745c5c3_Var23 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 						if !templ_7745c5c3_IsBuffer {
@@ -251,7 +601,7 @@ func Chat(data ChatPageData) templ.Component {
 							}()
 						}
 						ctx = templ.InitializeContext(ctx)
-						templ_7745c5c3_Var12 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_Var24 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 							if !templ_7745c5c3_IsBuffer {
@@ -263,21 +613,21 @@ func Chat(data ChatPageData) templ.Component {
 								}()
 							}
 							ctx = templ.InitializeContext(ctx)
-							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 13, "Start New Conversation")
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "Start New Conversation")
 							if templ_7745c5c3_Err != nil {
 								return templ_7745c5c3_Err
 							}
 							return nil
 						})
-						templ_7745c5c3_Err = dialog.Title().Render(templ.WithChildren(ctx, templ_7745c5c3_Var12), templ_7745c5c3_Buffer)
+						templ_7745c5c3_Err = dialog.Title().Render(templ.WithChildren(ctx, templ_7745c5c3_Var24), templ_7745c5c3_Buffer)
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
-						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 14, " ")
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, " ")
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
-						templ_7745c5c3_Var13 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_Var25 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 							if !templ_7745c5c3_IsBuffer {
@@ -289,202 +639,959 @@ func Chat(data ChatPageData) templ.Component {
 								}()
 							}
 							ctx = templ.InitializeContext(ctx)
-							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 15, "Select a user to start chatting")
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "Select a user to start chatting")
 							if templ_7745c5c3_Err != nil {
 								return templ_7745c5c3_Err
 							}
 							return nil
 						})
-						templ_7745c5c3_Err = dialog.Description().Render(templ.WithChildren(ctx, templ_7745c5c3_Var13), templ_7745c5c3_Buffer)
+						templ_7745c5c3_Err = dialog.Description().Render(templ.WithChildren(ctx, templ_7745c5c3_Var25), templ_7745c5c3_Buffer)
 						if templ_7745c5c3_Err != nil {
 							return templ_7745c5c3_Err
 						}
 						return nil
 					})
-					templ_7745c5c3_Err = dialog.Header().Render(templ.WithChildren(ctx, templ_7745c5c3_Var11), templ_7745c5c3_Buffer)
+					templ_7745c5c3_Err = dialog.Header().Render(templ.WithChildren(ctx, templ_7745c5c3_Var23), templ_7745c5c3_Buffer)
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 16, " <div id=\"user-list\" class=\"max-h-[300px] overflow-y-auto -mx-2\" hx-get=\"/users\" hx-trigger=\"intersect once\" hx-swap=\"innerHTML\"><p class=\"text-muted-foreground text-center py-4\">Loading users...</p></div>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, " <div id=\"user-list\" class=\"max-h-[300px] overflow-y-auto -mx-2\" hx-get=\"/users\" hx-trigger=\"intersect once\" hx-swap=\"innerHTML\"><p class=\"text-muted-foreground text-center py-4\">Loading users...</p></div>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 					return nil
 				})
-				templ_7745c5c3_Err = dialog.Content().Render(templ.WithChildren(ctx, templ_7745c5c3_Var10), templ_7745c5c3_Buffer)
+				templ_7745c5c3_Err = dialog.Content().Render(templ.WithChildren(ctx, templ_7745c5c3_Var22), templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				return nil
 			})
-			templ_7745c5c3_Err = dialog.Dialog(dialog.Props{ID: "user-picker"}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var7), templ_7745c5c3_Buffer)
-			if templ_7745c5c3_Err != nil {
-				return templ_7745c5c3_Err
-			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 17, "</div><!-- Conversation List --><div class=\"flex-1 overflow-y-auto\">")
+			templ_7745c5c3_Err = dialog.Dialog(dialog.Props{ID: "user-picker"}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var19), templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			if len(data.Conversations) > 0 {
-				for _, conv := range data.Conversations {
-					var templ_7745c5c3_Var14 = []any{"block p-4 border-b hover:bg-accent/50", templ.KV("bg-primary/10", conv.UserID == data.ActiveUserID)}
-					templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var14...)
-					if templ_7745c5c3_Err != nil {
-						return templ_7745c5c3_Err
-					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 18, "<a href=\"")
-					if templ_7745c5c3_Err != nil {
-						return templ_7745c5c3_Err
-					}
-					var templ_7745c5c3_Var15 templ.SafeURL
-					templ_7745c5c3_Var15, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(fmt.Sprintf("/?user=%d", conv.UserID)))
-					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 107, Col: 68}
+			templ_7745c5c3_Var26 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+				templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+				templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+				if !templ_7745c5c3_IsBuffer {
+					defer func() {
+						templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+						if templ_7745c5c3_Err == nil {
+							templ_7745c5c3_Err = templ_7745c5c3_BufErr
+						}
+					}()
+				}
+				ctx = templ.InitializeContext(ctx)
+				templ_7745c5c3_Var27 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+					templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+					templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+					if !templ_7745c5c3_IsBuffer {
+						defer func() {
+							templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err == nil {
+								templ_7745c5c3_Err = templ_7745c5c3_BufErr
+							}
+						}()
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var15))
+					ctx = templ.InitializeContext(ctx)
+					templ_7745c5c3_Var28 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "New Group")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = button.Button(button.Props{
+						Variant:   button.VariantGhost,
+						FullWidth: true,
+						Class:     "mt-2",
+					}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var28), templ_7745c5c3_Buffer)
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 19, "\" class=\"")
-					if templ_7745c5c3_Err != nil {
-						return templ_7745c5c3_Err
+					return nil
+				})
+				templ_7745c5c3_Err = dialog.Trigger().Render(templ.WithChildren(ctx, templ_7745c5c3_Var27), templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, " ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Var29 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+					templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+					templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+					if !templ_7745c5c3_IsBuffer {
+						defer func() {
+							templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err == nil {
+								templ_7745c5c3_Err = templ_7745c5c3_BufErr
+							}
+						}()
 					}
-					var templ_7745c5c3_Var16 string
-					templ_7745c5c3_Var16, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var14).String())
+					ctx = templ.InitializeContext(ctx)
+					templ_7745c5c3_Var30 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						templ_7745c5c3_Var31 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+							if !templ_7745c5c3_IsBuffer {
+								defer func() {
+									templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+									if templ_7745c5c3_Err == nil {
+										templ_7745c5c3_Err = templ_7745c5c3_BufErr
+									}
+								}()
+							}
+							ctx = templ.InitializeContext(ctx)
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, "Start New Group")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							return nil
+						})
+						templ_7745c5c3_Err = dialog.Title().Render(templ.WithChildren(ctx, templ_7745c5c3_Var31), templ_7745c5c3_Buffer)
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, " ")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Var32 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+							if !templ_7745c5c3_IsBuffer {
+								defer func() {
+									templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+									if templ_7745c5c3_Err == nil {
+										templ_7745c5c3_Err = templ_7745c5c3_BufErr
+									}
+								}()
+							}
+							ctx = templ.InitializeContext(ctx)
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 38, "Name the group and choose who to add")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							return nil
+						})
+						templ_7745c5c3_Err = dialog.Description().Render(templ.WithChildren(ctx, templ_7745c5c3_Var32), templ_7745c5c3_Buffer)
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = dialog.Header().Render(templ.WithChildren(ctx, templ_7745c5c3_Var30), templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 39, " <form action=\"/groups\" method=\"POST\" class=\"space-y-4\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = input.Input(input.Props{
+						Name:        "name",
+						Placeholder: "Group name",
+						Attributes:  templ.Attributes{"required": true, "autocomplete": "off"},
+					}).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 40, "<div class=\"max-h-[300px] overflow-y-auto -mx-2\" hx-get=\"/users?select=members\" hx-trigger=\"intersect once\" hx-swap=\"innerHTML\"><p class=\"text-muted-foreground text-center py-4\">Loading users...</p></div>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Var33 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 41, "Create Group")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = button.Button(button.Props{
+						Type:      button.TypeSubmit,
+						FullWidth: true,
+					}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var33), templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 42, "</form>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					return nil
+				})
+				templ_7745c5c3_Err = dialog.Content().Render(templ.WithChildren(ctx, templ_7745c5c3_Var29), templ_7745c5c3_Buffer)
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				return nil
+			})
+			templ_7745c5c3_Err = dialog.Dialog(dialog.Props{ID: "group-creator"}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var26), templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 43, "</div><!-- Message Search --><div class=\"px-4 py-2 border-b\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = input.Input(input.Props{
+				Type:        input.TypeSearch,
+				Name:        "q",
+				Placeholder: "Search messages",
+				Attributes: templ.Attributes{
+					"autocomplete": "off",
+					"hx-get":       "/search",
+					"hx-trigger":   "input changed delay:300ms, search",
+					"hx-target":    "#search-results",
+				},
+			}).Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 44, "</div><!-- Conversation List --><div class=\"flex-1 overflow-y-auto\"><div id=\"search-results\"></div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if len(data.Conversations) > 0 {
+				for _, conv := range data.Conversations {
+					var templ_7745c5c3_Var34 = []any{"block p-4 border-b hover:bg-accent/50", templ.KV("bg-primary/10", isActiveConversation(conv, data))}
+					templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var34...)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 45, "<a href=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var35 templ.SafeURL
+					templ_7745c5c3_Var35, templ_7745c5c3_Err = templ.JoinURLErrs(conversationURL(conv))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 327, Col: 37}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var35))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 46, "\" class=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var36 string
+					templ_7745c5c3_Var36, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var34).String())
 					if templ_7745c5c3_Err != nil {
 						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var16))
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var36))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 47, "\"><div class=\"flex justify-between items-center gap-2\"><span class=\"font-medium\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var37 string
+					templ_7745c5c3_Var37, templ_7745c5c3_Err = templ.JoinStringErrs(conv.DisplayName)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 331, Col: 54}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var37))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 48, "</span> ")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					if conv.GroupID > 0 {
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 49, "<span class=\"text-xs text-muted-foreground\">group</span>")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+					} else {
+						var templ_7745c5c3_Var38 = []any{"text-xs", templ.KV("text-green-700", conv.Status == "online"), templ.KV("text-muted-foreground", conv.Status != "online")}
+						templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var38...)
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 50, "<span class=\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						var templ_7745c5c3_Var39 string
+						templ_7745c5c3_Var39, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var38).String())
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var39))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 51, "\" data-presence-user=\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						var templ_7745c5c3_Var40 string
+						templ_7745c5c3_Var40, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(conv.UserID))
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 335, Col: 194}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var40))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 52, "\">")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						var templ_7745c5c3_Var41 string
+						templ_7745c5c3_Var41, templ_7745c5c3_Err = templ.JoinStringErrs(presenceLabel(conv.Status, conv.LastSeenAt))
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 335, Col: 242}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var41))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 53, "</span>")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 54, "</div><div class=\"flex justify-between items-center gap-2\"><span class=\"text-sm text-muted-foreground truncate\">")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 20, "\"><div class=\"font-medium\">")
+					var templ_7745c5c3_Var42 string
+					templ_7745c5c3_Var42, templ_7745c5c3_Err = templ.JoinStringErrs(conv.LastMessage)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 339, Col: 81}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var42))
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					var templ_7745c5c3_Var17 string
-					templ_7745c5c3_Var17, templ_7745c5c3_Err = templ.JoinStringErrs(conv.DisplayName)
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 55, "</span> ")
 					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 110, Col: 52}
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var43 = []any{templ.KV("hidden", conv.UnreadCount == 0)}
+					templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var43...)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var17))
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 56, "<span class=\"")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 21, "</div><div class=\"text-sm text-muted-foreground truncate\">")
+					var templ_7745c5c3_Var44 string
+					templ_7745c5c3_Var44, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var43).String())
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var44))
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					var templ_7745c5c3_Var18 string
-					templ_7745c5c3_Var18, templ_7745c5c3_Err = templ.JoinStringErrs(conv.LastMessage)
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 57, "\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					if conv.GroupID > 0 {
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 58, " data-unread-group=\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						var templ_7745c5c3_Var45 string
+						templ_7745c5c3_Var45, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(conv.GroupID))
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 343, Col: 56}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var45))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 59, "\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+					} else {
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 60, " data-unread-user=\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						var templ_7745c5c3_Var46 string
+						templ_7745c5c3_Var46, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(conv.UserID))
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 345, Col: 54}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var46))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 61, "\"")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 62, ">")
 					if templ_7745c5c3_Err != nil {
-						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 111, Col: 79}
+						return templ_7745c5c3_Err
 					}
-					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var18))
+					templ_7745c5c3_Var47 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						var templ_7745c5c3_Var48 string
+						templ_7745c5c3_Var48, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(conv.UnreadCount))
+						if templ_7745c5c3_Err != nil {
+							return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 349, Col: 42}
+						}
+						_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var48))
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = badge.Badge().Render(templ.WithChildren(ctx, templ_7745c5c3_Var47), templ_7745c5c3_Buffer)
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 22, "</div></a>")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 63, "</span></div></a>")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				}
 			} else {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 23, "<p class=\"p-4 text-muted-foreground text-sm\">No conversations yet</p>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 64, "<p class=\"p-4 text-muted-foreground text-sm\">No conversations yet</p>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 24, "</div></aside><!-- Main Chat Area -->")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 65, "</div></aside><!-- Main Chat Area -->")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var19 = []any{"flex-1 flex flex-col bg-background", templ.KV("hidden md:flex", data.ActiveUserID == 0)}
-			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var19...)
+			var templ_7745c5c3_Var49 = []any{"flex-1 flex flex-col bg-background", templ.KV("hidden md:flex", !data.HasActiveConversation())}
+			templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var49...)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 25, "<main class=\"")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 66, "<main class=\"")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			var templ_7745c5c3_Var20 string
-			templ_7745c5c3_Var20, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var19).String())
+			var templ_7745c5c3_Var50 string
+			templ_7745c5c3_Var50, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var49).String())
 			if templ_7745c5c3_Err != nil {
 				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
 			}
-			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var20))
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var50))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 26, "\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 67, "\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			if data.ActiveUserID > 0 {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 27, "<!-- Conversation Header --> <div class=\"border-b px-4 py-3\"><h2 class=\"font-semibold\">")
+			if data.HasActiveConversation() {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 68, "<!-- Conversation Header --> ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var21 string
-				templ_7745c5c3_Var21, templ_7745c5c3_Err = templ.JoinStringErrs(data.ActiveUserName)
+				if data.ActiveGroupID > 0 {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 69, "<div class=\"border-b px-4 py-3 flex justify-between items-center gap-2\"><div><h2 class=\"font-semibold\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var51 string
+					templ_7745c5c3_Var51, templ_7745c5c3_Err = templ.JoinStringErrs(data.ActiveGroupName)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 367, Col: 57}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var51))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 70, "</h2><p class=\"text-xs text-muted-foreground\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var52 string
+					templ_7745c5c3_Var52, templ_7745c5c3_Err = templ.JoinStringErrs(strings.Join(data.ActiveGroupMembers, ", "))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 368, Col: 95}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var52))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 71, "</p></div><div class=\"flex items-center gap-2\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = timerSelect(data).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Var53 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						templ_7745c5c3_Var54 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+							if !templ_7745c5c3_IsBuffer {
+								defer func() {
+									templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+									if templ_7745c5c3_Err == nil {
+										templ_7745c5c3_Err = templ_7745c5c3_BufErr
+									}
+								}()
+							}
+							ctx = templ.InitializeContext(ctx)
+							templ_7745c5c3_Var55 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+								templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+								templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+								if !templ_7745c5c3_IsBuffer {
+									defer func() {
+										templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+										if templ_7745c5c3_Err == nil {
+											templ_7745c5c3_Err = templ_7745c5c3_BufErr
+										}
+									}()
+								}
+								ctx = templ.InitializeContext(ctx)
+								templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 72, "Add")
+								if templ_7745c5c3_Err != nil {
+									return templ_7745c5c3_Err
+								}
+								return nil
+							})
+							templ_7745c5c3_Err = button.Button(button.Props{
+								Variant: button.VariantGhost,
+								Size:    button.SizeSm,
+							}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var55), templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							return nil
+						})
+						templ_7745c5c3_Err = dialog.Trigger().Render(templ.WithChildren(ctx, templ_7745c5c3_Var54), templ_7745c5c3_Buffer)
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 73, " ")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						templ_7745c5c3_Var56 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+							templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+							templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+							if !templ_7745c5c3_IsBuffer {
+								defer func() {
+									templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+									if templ_7745c5c3_Err == nil {
+										templ_7745c5c3_Err = templ_7745c5c3_BufErr
+									}
+								}()
+							}
+							ctx = templ.InitializeContext(ctx)
+							templ_7745c5c3_Var57 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+								templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+								templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+								if !templ_7745c5c3_IsBuffer {
+									defer func() {
+										templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+										if templ_7745c5c3_Err == nil {
+											templ_7745c5c3_Err = templ_7745c5c3_BufErr
+										}
+									}()
+								}
+								ctx = templ.InitializeContext(ctx)
+								templ_7745c5c3_Var58 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+									templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+									templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+									if !templ_7745c5c3_IsBuffer {
+										defer func() {
+											templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+											if templ_7745c5c3_Err == nil {
+												templ_7745c5c3_Err = templ_7745c5c3_BufErr
+											}
+										}()
+									}
+									ctx = templ.InitializeContext(ctx)
+									templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 74, "Add to ")
+									if templ_7745c5c3_Err != nil {
+										return templ_7745c5c3_Err
+									}
+									var templ_7745c5c3_Var59 string
+									templ_7745c5c3_Var59, templ_7745c5c3_Err = templ.JoinStringErrs(data.ActiveGroupName)
+									if templ_7745c5c3_Err != nil {
+										return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 384, Col: 42}
+									}
+									_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var59))
+									if templ_7745c5c3_Err != nil {
+										return templ_7745c5c3_Err
+									}
+									return nil
+								})
+								templ_7745c5c3_Err = dialog.Title().Render(templ.WithChildren(ctx, templ_7745c5c3_Var58), templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err != nil {
+									return templ_7745c5c3_Err
+								}
+								templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 75, " ")
+								if templ_7745c5c3_Err != nil {
+									return templ_7745c5c3_Err
+								}
+								templ_7745c5c3_Var60 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+									templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+									templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+									if !templ_7745c5c3_IsBuffer {
+										defer func() {
+											templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+											if templ_7745c5c3_Err == nil {
+												templ_7745c5c3_Err = templ_7745c5c3_BufErr
+											}
+										}()
+									}
+									ctx = templ.InitializeContext(ctx)
+									templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 76, "New members see messages sent after they join")
+									if templ_7745c5c3_Err != nil {
+										return templ_7745c5c3_Err
+									}
+									return nil
+								})
+								templ_7745c5c3_Err = dialog.Description().Render(templ.WithChildren(ctx, templ_7745c5c3_Var60), templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err != nil {
+									return templ_7745c5c3_Err
+								}
+								return nil
+							})
+							templ_7745c5c3_Err = dialog.Header().Render(templ.WithChildren(ctx, templ_7745c5c3_Var57), templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 77, " <form action=\"")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							var templ_7745c5c3_Var61 templ.SafeURL
+							templ_7745c5c3_Var61, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(fmt.Sprintf("/groups/%d/members", data.ActiveGroupID)))
+							if templ_7745c5c3_Err != nil {
+								return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 390, Col: 94}
+							}
+							_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var61))
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 78, "\" method=\"POST\" class=\"space-y-4\"><div class=\"max-h-[300px] overflow-y-auto -mx-2\">")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Err = partials.MemberPicker(data.GroupCandidates).Render(ctx, templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 79, "</div>")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Var62 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+								templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+								templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+								if !templ_7745c5c3_IsBuffer {
+									defer func() {
+										templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+										if templ_7745c5c3_Err == nil {
+											templ_7745c5c3_Err = templ_7745c5c3_BufErr
+										}
+									}()
+								}
+								ctx = templ.InitializeContext(ctx)
+								templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 80, "Add Members")
+								if templ_7745c5c3_Err != nil {
+									return templ_7745c5c3_Err
+								}
+								return nil
+							})
+							templ_7745c5c3_Err = button.Button(button.Props{
+								Type:      button.TypeSubmit,
+								FullWidth: true,
+							}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var62), templ_7745c5c3_Buffer)
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 81, "</form>")
+							if templ_7745c5c3_Err != nil {
+								return templ_7745c5c3_Err
+							}
+							return nil
+						})
+						templ_7745c5c3_Err = dialog.Content().Render(templ.WithChildren(ctx, templ_7745c5c3_Var56), templ_7745c5c3_Buffer)
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = dialog.Dialog(dialog.Props{ID: "group-members"}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var53), templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 82, "<form action=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var63 templ.SafeURL
+					templ_7745c5c3_Var63, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(fmt.Sprintf("/groups/%d/leave", data.ActiveGroupID)))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 403, Col: 90}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var63))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 83, "\" method=\"POST\" class=\"inline\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Var64 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+						templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
+						templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
+						if !templ_7745c5c3_IsBuffer {
+							defer func() {
+								templ_7745c5c3_BufErr := templruntime.ReleaseBuffer(templ_7745c5c3_Buffer)
+								if templ_7745c5c3_Err == nil {
+									templ_7745c5c3_Err = templ_7745c5c3_BufErr
+								}
+							}()
+						}
+						ctx = templ.InitializeContext(ctx)
+						templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 84, "Leave")
+						if templ_7745c5c3_Err != nil {
+							return templ_7745c5c3_Err
+						}
+						return nil
+					})
+					templ_7745c5c3_Err = button.Button(button.Props{
+						Type:    button.TypeSubmit,
+						Variant: button.VariantGhost,
+						Size:    button.SizeSm,
+					}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var64), templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 85, "</form></div></div>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				} else {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 86, "<div class=\"border-b px-4 py-3 flex justify-between items-center gap-2\"><div><h2 class=\"font-semibold\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var65 string
+					templ_7745c5c3_Var65, templ_7745c5c3_Err = templ.JoinStringErrs(data.ActiveUserName)
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 417, Col: 56}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var65))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 87, "</h2>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var66 = []any{"text-xs", templ.KV("text-green-700", data.ActiveUserStatus == "online"), templ.KV("text-muted-foreground", data.ActiveUserStatus != "online")}
+					templ_7745c5c3_Err = templ.RenderCSSItems(ctx, templ_7745c5c3_Buffer, templ_7745c5c3_Var66...)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 88, "<p class=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var67 string
+					templ_7745c5c3_Var67, templ_7745c5c3_Err = templ.JoinStringErrs(templ.CSSClasses(templ_7745c5c3_Var66).String())
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 1, Col: 0}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var67))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 89, "\" data-presence-user=\"")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var68 string
+					templ_7745c5c3_Var68, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprint(data.ActiveUserID))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 418, Col: 215}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var68))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 90, "\">")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					var templ_7745c5c3_Var69 string
+					templ_7745c5c3_Var69, templ_7745c5c3_Err = templ.JoinStringErrs(presenceLabel(data.ActiveUserStatus, data.ActiveUserLastSeen))
+					if templ_7745c5c3_Err != nil {
+						return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 418, Col: 281}
+					}
+					_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var69))
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 91, "</p><p id=\"typing-indicator\" class=\"text-xs text-muted-foreground hidden\">typing…</p></div>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = timerSelect(data).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 92, "</div>")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 93, " ")
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 124, Col: 54}
+					return templ_7745c5c3_Err
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var21))
+				templ_7745c5c3_Err = pinnedBar(data.Pinned).Render(ctx, templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 28, "</h2></div><!-- Messages --> <div id=\"messages\" class=\"flex-1 overflow-y-auto p-4 flex flex-col-reverse gap-2\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 94, " <!-- Messages --> <div id=\"messages\" class=\"flex-1 overflow-y-auto p-4 flex flex-col-reverse gap-2\">")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				for _, msg := range data.Messages {
-					templ_7745c5c3_Err = partials.Message(msg.ID, msg.Content, msg.CreatedAt, msg.IsSent).Render(ctx, templ_7745c5c3_Buffer)
+					templ_7745c5c3_Err = partials.Message(partials.MessageProps{
+						ID:         msg.ID,
+						Content:    msg.Content,
+						CreatedAt:  msg.CreatedAt,
+						IsSent:     msg.IsSent,
+						Status:     msg.Status,
+						SenderName: groupSenderName(msg, data),
+						EditedAt:   msg.EditedAt,
+						Editable:   msg.Editable,
+						Deleted:    msg.Deleted,
+						ReplyTo:    msg.ReplyTo,
+						Reactions:  msg.Reactions,
+						Attachment: msg.Attachment,
+						Preview:    msg.Preview,
+						Pinned:     msg.Pinned,
+						Starred:    msg.Starred,
+					}).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				if data.OlderMessagesURL != "" {
+					templ_7745c5c3_Err = partials.OlderMessages(data.OlderMessagesURL).Render(ctx, templ_7745c5c3_Buffer)
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 29, "</div><!-- Message Input --> <form action=\"")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 95, "</div><!-- Reply being composed --> <div id=\"reply-banner\" class=\"hidden border-t px-4 py-2 text-xs\"><div class=\"flex items-center justify-between gap-2\"><p class=\"truncate min-w-0\">Replying to: <span id=\"reply-banner-snippet\"></span></p><button type=\"button\" class=\"hover:underline\" id=\"reply-cancel\">Cancel</button></div></div><!-- Message Input --> <form id=\"message-form\" action=\"")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var22 templ.SafeURL
-				templ_7745c5c3_Var22, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(fmt.Sprintf("/conversations/%d/messages", data.ActiveUserID)))
+				var templ_7745c5c3_Var70 templ.SafeURL
+				templ_7745c5c3_Var70, templ_7745c5c3_Err = templ.JoinURLErrs(templ.SafeURL(sendURL(data)))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 134, Col: 91}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 460, Col: 44}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var22))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var70))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 30, "\" method=\"POST\" hx-post=\"")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 96, "\" method=\"POST\" hx-post=\"")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var23 string
-				templ_7745c5c3_Var23, templ_7745c5c3_Err = templ.JoinStringErrs(fmt.Sprintf("/conversations/%d/messages", data.ActiveUserID))
+				var templ_7745c5c3_Var71 string
+				templ_7745c5c3_Var71, templ_7745c5c3_Err = templ.JoinStringErrs(sendURL(data))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 136, Col: 77}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/views/pages/chat.templ`, Line: 462, Col: 30}
 				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var23))
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var71))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 31, "\" hx-target=\"#messages\" hx-swap=\"afterbegin\" hx-on::after-request=\"this.reset()\" class=\"border-t p-3 sm:p-4 flex gap-2\">")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 97, "\" hx-target=\"#messages\" hx-swap=\"afterbegin\" hx-encoding=\"multipart/form-data\" enctype=\"multipart/form-data\" hx-on::after-request=\"if (event.detail.successful) this.reset()\" class=\"border-t p-3 sm:p-4 flex items-center gap-2\"><input type=\"hidden\" name=\"reply_to_id\" id=\"reply-to-id\"> <label class=\"cursor-pointer\" title=\"Attach a photo or file\">📎 <input type=\"file\" name=\"file\" id=\"message-file\" class=\"sr-only\" accept=\"image/jpeg,image/png,image/gif,application/pdf,text/plain,audio/*,video/mp4,video/webm,.zip,.docx,.xlsx,.pptx\"></label> <span id=\"message-file-name\" class=\"hidden text-xs truncate min-w-0\"></span>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				templ_7745c5c3_Err = input.Input(input.Props{
 					Name:        "content",
 					Placeholder: "Type a message...",
+					Value:       data.Draft,
 					Class:       "flex-1",
 					Attributes:  templ.Attributes{"required": true, "autocomplete": "off"},
 				}).Render(ctx, templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Var24 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
+				templ_7745c5c3_Var72 := templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 					templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 					templ_7745c5c3_Buffer, templ_7745c5c3_IsBuffer := templruntime.GetBuffer(templ_7745c5c3_W)
 					if !templ_7745c5c3_IsBuffer {
@@ -496,7 +1603,7 @@ func Chat(data ChatPageData) templ.Component {
 						}()
 					}
 					ctx = templ.InitializeContext(ctx)
-					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 32, "Send")
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 98, "Send")
 					if templ_7745c5c3_Err != nil {
 						return templ_7745c5c3_Err
 					}
@@ -504,29 +1611,29 @@ func Chat(data ChatPageData) templ.Component {
 				})
 				templ_7745c5c3_Err = button.Button(button.Props{
 					Type: button.TypeSubmit,
-				}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var24), templ_7745c5c3_Buffer)
+				}).Render(templ.WithChildren(ctx, templ_7745c5c3_Var72), templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 33, "</form>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 99, "</form>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			} else {
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 34, "<!-- No Conversation Selected --> <div class=\"hidden md:flex flex-1 items-center justify-center text-muted-foreground\"><p>Select a conversation or start a new one</p></div>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 100, "<!-- No Conversation Selected --> <div class=\"hidden md:flex flex-1 items-center justify-center text-muted-foreground\"><p>Select a conversation or start a new one</p></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 35, "</main></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 101, "</main></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = chatScript(data.CurrentUserID, data.ActiveUserID).Render(ctx, templ_7745c5c3_Buffer)
+			templ_7745c5c3_Err = chatScript(data.CurrentUserID, data.ActiveUserID, data.ActiveGroupID, data.LastEventSeq, data.EditingEnabled, data.FocusMessageID).Render(ctx, templ_7745c5c3_Buffer)
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 36, " ")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 102, " ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -534,7 +1641,15 @@ func Chat(data ChatPageData) templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 37, " ")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 103, " ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = checkbox.Script().Render(ctx, templ_7745c5c3_Buffer)
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 104, " ")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -544,7 +1659,7 @@ func Chat(data ChatPageData) templ.Component {
 			}
 			return nil
 		})
-		templ_7745c5c3_Err = layouts.BaseWithScripts("Wantok").Render(templ.WithChildren(ctx, templ_7745c5c3_Var2), templ_7745c5c3_Buffer)
+		templ_7745c5c3_Err = layouts.BaseWithScripts("Wantok").Render(templ.WithChildren(ctx, templ_7745c5c3_Var14), templ_7745c5c3_Buffer)
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -552,21 +1667,106 @@ func Chat(data ChatPageData) templ.Component {
 	})
 }
 
-func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
+func chatScript(currentUserID, activeUserID, activeGroupID, lastEventSeq int64, editingEnabled bool, focusMessageID int64) templ.ComponentScript {
 	return templ.ComponentScript{
-		Name: `__templ_chatScript_e116`,
-		Function: `function __templ_chatScript_e116(currentUserID, activeUserID){// WebSocket connection for real-time messaging
+		Name: `__templ_chatScript_2c97`,
+		Function: `function __templ_chatScript_2c97(currentUserID, activeUserID, activeGroupID, lastEventSeq, editingEnabled, focusMessageID){// WebSocket connection for real-time messaging, with a Server-Sent Events fallback
 	(function() {
 		const currentUser = currentUserID;
 		const activeUser = activeUserID;
+		const activeGroup = activeGroupID;
 		let ws = null;
+		let wsOpened = false;
+		// Event stream used when the WebSocket cannot connect; frames are POSTed to streamId
+		let events = null;
+		let streamId = null;
+		let frameQueue = Promise.resolve();
 		let reconnectAttempts = 0;
 		const maxReconnectAttempts = 10;
+		let nextFrameId = 0;
+		const pending = {};
+		// Highest contiguous event sequence number applied to this page
+		let lastSeq = lastEventSeq;
+		let resumeRequestedAt = 0;
+		let ackTimer = null;
+		// Identifies the message being sent so a retry isn't stored twice. Kept
+		// until the send succeeds or the text changes.
+		let sendClientId = null;
+		let typingSentAt = 0;
+		let typingStopTimer = null;
+		let typingHideTimer = null;
+		// Drafts are saved a second after typing pauses; savedDraft is the last text saved
+		const draftURL = activeGroup > 0 ? '/groups/' + activeGroup + '/draft' : '/conversations/' + activeUser + '/draft';
+		let draftTimer = null;
+		let savedDraft = '';
 
+		// Also escapes quotes, so the result can be used in attribute values
 		function escapeHtml(text) {
 			const div = document.createElement('div');
 			div.textContent = text;
-			return div.innerHTML;
+			return div.innerHTML.replaceAll('"', '&quot;').replaceAll("'", '&#39;');
+		}
+
+		function isConnected() {
+			if (events) return streamId !== null;
+			return ws !== null && ws.readyState === WebSocket.OPEN;
+		}
+
+		// Sends a frame over whichever transport is connected. Returns false if none is.
+		function sendFrame(frame) {
+			if (!isConnected()) return false;
+			const data = JSON.stringify(frame);
+			if (!events) {
+				ws.send(data);
+				return true;
+			}
+			// Chained so the server sees frames in the order they were sent
+			const url = '/events/' + streamId;
+			frameQueue = frameQueue.then(function() {
+				return fetch(url, { method: 'POST', headers: { 'Content-Type': 'application/json' }, body: data });
+			}).catch(function(e) {
+				console.error('Failed to send frame:', e);
+			});
+			return true;
+		}
+
+		function newClientId() {
+			if (window.crypto && crypto.randomUUID) return crypto.randomUUID();
+			return Date.now().toString(36) + '-' + Math.random().toString(36).slice(2);
+		}
+
+		// Shows a message sent over the realtime connection until the server stores it
+		function showPending(payload) {
+			const messagesContainer = document.getElementById('messages');
+			if (!messagesContainer) return;
+			const el = createMessageElement({ content: payload.content, is_sent: true, status: 'sending', created_at: '' });
+			el.removeAttribute('data-message-id');
+			el.setAttribute('data-client-id', payload.client_id);
+			el.classList.add('opacity-60');
+			el.querySelectorAll('button').forEach(function(button) { button.remove(); });
+			messagesContainer.insertBefore(el, messagesContainer.firstChild);
+		}
+
+		// Swaps the pending bubble of a message this page sent for the stored
+		// message. Returns false if there is no such bubble.
+		function reconcilePending(msg) {
+			if (!msg.client_id) return false;
+			const el = document.querySelector('[data-client-id="' + msg.client_id + '"]');
+			if (!el) return false;
+			if (messageExists(msg.id)) {
+				el.remove();
+			} else {
+				el.replaceWith(createMessageElement(msg));
+			}
+			return true;
+		}
+
+		// Sends that weren't acknowledged before the connection dropped. The
+		// server answers with the original message if it was stored.
+		function resendPending() {
+			Object.keys(pending).forEach(function(id) {
+				sendFrame(pending[id]);
+			});
 		}
 
 		function messageExists(id) {
@@ -582,14 +1782,685 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 			const div = document.createElement('div');
 			div.className = 'flex ' + justifyClass;
 			div.setAttribute('data-message-id', msg.id);
-			div.innerHTML = '<div class="max-w-[85%] sm:max-w-xs lg:max-w-md px-4 py-2 rounded-lg ' + bgClass + '"><p class="break-words">' + escapeHtml(msg.content) + '</p><p class="text-xs mt-1 ' + timeClass + '">' + escapeHtml(msg.created_at) + '</p></div>';
+			let senderHtml = '';
+			if (!isSent && msg.conversation_id) {
+				senderHtml = '<p class="text-xs font-medium text-muted-foreground">' + escapeHtml(msg.sender_name) + '</p>';
+			}
+			let editedHtml = '';
+			if (msg.edited_at) {
+				editedHtml = ' <button type="button" class="hover:underline" title="Edited ' + escapeHtml(msg.edited_at) + '" data-edit-history>· edited</button>';
+			}
+			let statusHtml = '';
+			if (isSent && msg.status) {
+				statusHtml = ' <span data-message-status="' + escapeHtml(msg.status) + '">· ' + statusLabel(msg.status) + '</span>';
+			}
+			let editHtml = '';
+			if (isSent && !msg.deleted && editingEnabled) {
+				editHtml = ' <button type="button" class="hover:underline" data-edit-message>· Edit</button>';
+			}
+			if (!msg.deleted) {
+				editHtml += ' <button type="button" class="hover:underline" data-reply-message>· Reply</button>';
+				editHtml += ' <button type="button" class="hover:underline" data-react-picker>· React</button>';
+				editHtml += ' <button type="button" class="hover:underline" data-pin-message="' + !!msg.pinned_at + '">· ' + pinLabel(!!msg.pinned_at) + '</button>';
+				editHtml += ' <button type="button" class="hover:underline" data-star-message="' + !!msg.starred + '">· ' + starLabel(!!msg.starred) + '</button>';
+			}
+			if (isSent && !msg.deleted) {
+				editHtml += ' <button type="button" class="hover:underline" data-delete-message>· Delete</button>';
+			}
+			// content_html is formatted and sanitized by the server
+			const body = msg.content_html || escapeHtml(msg.content);
+			let contentHtml = '<div class="break-words" data-message-content data-message-text="' + escapeHtml(msg.content) + '">' + body + '</div>';
+			if (msg.attachment) {
+				contentHtml = attachmentHtml(msg.attachment) + contentHtml;
+			}
+			if (msg.preview) {
+				contentHtml += previewHtml(msg.preview);
+			}
+			if (msg.deleted) {
+				contentHtml = '<p class="italic opacity-70">Message deleted</p>';
+			}
+			if (msg.reply_to) {
+				contentHtml = replyQuoteHtml(msg.reply_to) + contentHtml;
+			}
+			if (msg.reactions && msg.reactions.length > 0) {
+				contentHtml += '<div class="flex gap-1 mt-1" data-reactions>' + reactionsHtml(msg.reactions) + '</div>';
+			}
+			div.innerHTML = '<div class="max-w-[85%] sm:max-w-xs lg:max-w-md px-4 py-2 rounded-lg ' + bgClass + '">' + senderHtml + contentHtml + '<p class="text-xs mt-1 ' + timeClass + '">' + escapeHtml(msg.created_at) + editedHtml + statusHtml + editHtml + '</p></div>';
 			return div;
 		}
 
+		function replySnippet(text) {
+			if (!text) return 'Attachment';
+			return text.length > 100 ? text.slice(0, 97) + '...' : text;
+		}
+
+		function formatSize(size) {
+			if (size >= 1048576) return (size / 1048576).toFixed(1) + ' MB';
+			if (size >= 1024) return Math.floor(size / 1024) + ' KB';
+			return size + ' bytes';
+		}
+
+		function attachmentHtml(file) {
+			let inner;
+			if (file.thumbnail_url) {
+				inner = '<a href="' + escapeHtml(file.url) + '" target="_blank" rel="noopener"><img src="' + escapeHtml(file.thumbnail_url) + '" alt="' + escapeHtml(file.name) + '" class="block rounded" style="max-width: 100%; height: auto;" loading="lazy"></a>';
+			} else {
+				inner = '<a href="' + escapeHtml(file.url) + '" class="underline break-words" download="' + escapeHtml(file.name) + '">📎 ' + escapeHtml(file.name) + '</a>';
+				inner += ' <span class="text-xs opacity-70">(' + formatSize(file.size) + ')</span>';
+			}
+			return '<div class="mb-1" data-attachment>' + inner + '</div>';
+		}
+
+		function isWebUrl(url) {
+			return typeof url === 'string' && (url.startsWith('https:') || url.startsWith('http:'));
+		}
+
+		function previewHtml(preview) {
+			// Only link out to web pages
+			if (!isWebUrl(preview.url)) return '';
+			let inner = '';
+			if (isWebUrl(preview.image_url)) {
+				inner += '<img src="' + escapeHtml(preview.image_url) + '" alt="" class="block rounded mb-1" style="max-width: 100%; max-height: 10rem;" loading="lazy" referrerpolicy="no-referrer">';
+			}
+			if (preview.site_name) {
+				inner += '<p class="opacity-70">' + escapeHtml(preview.site_name) + '</p>';
+			}
+			inner += '<p class="font-medium">' + escapeHtml(preview.title) + '</p>';
+			if (preview.description) {
+				inner += '<p class="opacity-70">' + escapeHtml(preview.description) + '</p>';
+			}
+			return '<a href="' + escapeHtml(preview.url) + '" target="_blank" rel="noopener noreferrer" class="block border-l-2 pl-2 mt-1 text-xs" data-link-preview>' + inner + '</a>';
+		}
+
+		// Attaches a link preview fetched after the message was sent
+		function handleMessageUpdated(update) {
+			const el = document.querySelector('[data-message-id="' + update.id + '"]');
+			if (!el) return;
+			const content = el.querySelector('[data-message-content]');
+			if (!content) return;
+			const existing = el.querySelector('[data-link-preview]');
+			if (existing) existing.remove();
+			if (update.preview) {
+				content.insertAdjacentHTML('afterend', previewHtml(update.preview));
+			}
+		}
+
+		// Messages with a file may be sent without text
+		function updateAttachment() {
+			const input = document.getElementById('message-file');
+			if (!input) return;
+			const name = document.getElementById('message-file-name');
+			const file = input.files.length > 0 ? input.files[0] : null;
+			name.textContent = file ? file.name : '';
+			name.classList.toggle('hidden', !file);
+			document.querySelector('#message-form [name="content"]').required = !file;
+		}
+
+		function replyQuoteHtml(reply) {
+			let inner = '<p class="italic">Original message unavailable</p>';
+			if (!reply.unavailable) {
+				inner = '<p class="font-medium">' + escapeHtml(reply.sender_name) + '</p>';
+				if (reply.deleted) {
+					inner += '<p class="italic" data-reply-snippet>Message deleted</p>';
+				} else {
+					inner += '<p class="truncate" data-reply-snippet>' + escapeHtml(reply.snippet) + '</p>';
+				}
+			}
+			return '<div class="border-l px-2 mb-1 text-xs opacity-70" data-reply-to="' + reply.id + '">' + inner + '</div>';
+		}
+
+		// Replaces an edited or deleted message in place, keeping its delivery status
+		function replaceMessage(msg) {
+			// Replies quoting the message show its new content
+			document.querySelectorAll('[data-reply-to="' + msg.id + '"] [data-reply-snippet]').forEach(function(el) {
+				if (msg.deleted) {
+					el.className = 'italic';
+					el.textContent = 'Message deleted';
+				} else {
+					el.textContent = replySnippet(msg.content);
+				}
+			});
+
+			const existing = document.querySelector('[data-message-id="' + msg.id + '"]');
+			if (!existing) return;
+			const status = existing.querySelector('[data-message-status]');
+			if (status) msg.status = status.getAttribute('data-message-status');
+			const content = existing.querySelector('[data-message-content]');
+			const unchanged = content && content.getAttribute('data-message-text') === msg.content;
+			const element = createMessageElement(msg);
+			// Edits don't carry reactions, attachments or previews; deleting a message clears them.
+			// Changing the text clears the preview until one for the new text arrives.
+			const preview = existing.querySelector('[data-link-preview]');
+			if (preview && !msg.deleted && unchanged) {
+				element.querySelector('[data-message-content]').after(preview);
+			}
+			const reactions = existing.querySelector('[data-reactions]');
+			if (reactions && !msg.deleted) {
+				element.querySelector('[data-message-content]').after(reactions);
+			}
+			const attachment = existing.querySelector('[data-attachment]');
+			if (attachment && !msg.deleted) {
+				element.querySelector('[data-message-content]').before(attachment);
+			}
+			// Edits don't carry pin or star state either
+			['[data-pin-message]', '[data-star-message]'].forEach(function(selector) {
+				const button = existing.querySelector(selector);
+				const replacement = element.querySelector(selector);
+				if (button && replacement) replacement.replaceWith(button);
+			});
+			existing.replaceWith(element);
+			if (msg.deleted) removePinned(msg.id);
+		}
+
+		function pinLabel(pinned) {
+			return pinned ? 'Unpin' : 'Pin';
+		}
+
+		function starLabel(starred) {
+			return starred ? '★ Starred' : '☆ Star';
+		}
+
+		function pinnedHtml(pin) {
+			const title = pin.pinned_by_name ? 'Pinned by ' + pin.pinned_by_name : 'Pinned';
+			return '<a href="' + escapeHtml(pin.url) + '" class="block truncate hover:underline" title="' + escapeHtml(title) + '" data-pinned-id="' + pin.id + '">📌 <span class="font-medium">' + escapeHtml(pin.sender_name) + ':</span> ' + escapeHtml(pin.content || 'Attachment') + '</a>';
+		}
+
+		function removePinned(id) {
+			const bar = document.getElementById('pinned-bar');
+			const item = bar && bar.querySelector('[data-pinned-id="' + id + '"]');
+			if (!item) return;
+			item.remove();
+			bar.classList.toggle('hidden', bar.children.length === 0);
+		}
+
+		// A message was pinned or unpinned by anyone in its conversation
+		function handlePinned(pin) {
+			const pinned = !!pin.pinned_at && !pin.deleted;
+			const button = document.querySelector('[data-message-id="' + pin.id + '"] [data-pin-message]');
+			if (button) {
+				button.setAttribute('data-pin-message', String(pinned));
+				button.textContent = '· ' + pinLabel(pinned);
+			}
+
+			const matches = pin.conversation_id
+				? pin.conversation_id === activeGroup
+				: activeGroup === 0 && pin.user_id === activeUser;
+			const bar = document.getElementById('pinned-bar');
+			if (!matches || !bar) return;
+			removePinned(pin.id);
+			if (pinned) {
+				bar.insertAdjacentHTML('afterbegin', pinnedHtml(pin));
+				bar.classList.remove('hidden');
+			}
+		}
+
+		// This user starred or unstarred a message, possibly on another device
+		function handleStarred(star) {
+			const button = document.querySelector('[data-message-id="' + star.id + '"] [data-star-message]');
+			if (!button) return;
+			button.setAttribute('data-star-message', String(star.starred));
+			button.textContent = '· ' + starLabel(star.starred);
+		}
+
+		function togglePin(el) {
+			const pinned = el.querySelector('[data-pin-message]').getAttribute('data-pin-message') === 'true';
+			fetch('/messages/' + el.getAttribute('data-message-id') + '/pin', { method: pinned ? 'DELETE' : 'POST' }).then(function(resp) {
+				if (!resp.ok) return resp.text().then(function(text) { alert(text); });
+				return resp.json().then(handlePinned);
+			}).catch(function(e) {
+				console.error('Failed to update pin:', e);
+			});
+		}
+
+		function toggleStar(el) {
+			const starred = el.querySelector('[data-star-message]').getAttribute('data-star-message') === 'true';
+			fetch('/messages/' + el.getAttribute('data-message-id') + '/star', { method: starred ? 'DELETE' : 'POST' }).then(function(resp) {
+				if (!resp.ok) return resp.text().then(function(text) { alert(text); });
+				return resp.json().then(handleStarred);
+			}).catch(function(e) {
+				console.error('Failed to update star:', e);
+			});
+		}
+
+		const reactionPalette = ['👍', '❤️', '😂', '😮', '😢', '🙏'];
+
+		function reactionsHtml(reactions) {
+			return reactions.map(function(r) {
+				const weight = r.reacted ? ' font-semibold' : '';
+				return '<button type="button" class="text-xs px-2 rounded-full border' + weight + '" data-react="' + escapeHtml(r.emoji) + '">' + escapeHtml(r.emoji) + ' ' + r.count + '</button>';
+			}).join('');
+		}
+
+		function handleReaction(payload) {
+			const el = document.querySelector('[data-message-id="' + payload.message_id + '"]');
+			if (!el) return;
+			let container = el.querySelector('[data-reactions]');
+			if (payload.reactions.length === 0) {
+				if (container) container.remove();
+				return;
+			}
+			if (!container) {
+				container = document.createElement('div');
+				container.className = 'flex gap-1 mt-1';
+				container.setAttribute('data-reactions', '');
+				el.querySelector('[data-message-content]').after(container);
+			}
+			container.innerHTML = reactionsHtml(payload.reactions);
+		}
+
+		function toggleReaction(el, emoji) {
+			fetch('/messages/' + el.getAttribute('data-message-id') + '/reactions', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+				body: new URLSearchParams({ emoji: emoji })
+			}).then(function(resp) {
+				if (!resp.ok) return resp.text().then(function(text) { alert(text); });
+				return resp.json().then(handleReaction);
+			}).catch(function(e) {
+				console.error('Failed to update reaction:', e);
+			});
+		}
+
+		function toggleReactionPicker(el) {
+			const existing = el.querySelector('[data-reaction-picker]');
+			if (existing) {
+				existing.remove();
+				return;
+			}
+			const picker = document.createElement('div');
+			picker.className = 'flex gap-1 mt-1';
+			picker.setAttribute('data-reaction-picker', '');
+			picker.innerHTML = reactionPalette.map(function(emoji) {
+				return '<button type="button" class="px-2 rounded-full border" data-react="' + emoji + '">' + emoji + '</button>';
+			}).join('');
+			el.querySelector('[data-message-content]').after(picker);
+		}
+
+		function editMessage(el) {
+			const id = el.getAttribute('data-message-id');
+			const current = el.querySelector('[data-message-content]').getAttribute('data-message-text');
+			const content = prompt('Edit message', current);
+			if (content === null || content.trim() === '' || content === current) return;
+			fetch('/messages/' + id, {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+				body: new URLSearchParams({ content: content })
+			}).then(function(resp) {
+				if (!resp.ok) return resp.text().then(function(text) { alert(text); });
+				return resp.json().then(replaceMessage);
+			}).catch(function(e) {
+				console.error('Failed to edit message:', e);
+			});
+		}
+
+		function startReply(el) {
+			const content = el.querySelector('[data-message-content]');
+			if (!content) return;
+			document.getElementById('reply-to-id').value = el.getAttribute('data-message-id');
+			document.getElementById('reply-banner-snippet').textContent = replySnippet(content.getAttribute('data-message-text'));
+			document.getElementById('reply-banner').classList.remove('hidden');
+			document.querySelector('#message-form [name="content"]').focus();
+		}
+
+		function clearReply() {
+			const replyTo = document.getElementById('reply-to-id');
+			if (!replyTo) return;
+			replyTo.value = '';
+			document.getElementById('reply-banner').classList.add('hidden');
+		}
+
+		function deleteMessage(el) {
+			if (!confirm('Delete this message for everyone?')) return;
+			const conversation = activeGroup > 0 ? '/groups/' + activeGroup : '/conversations/' + activeUser;
+			fetch(conversation + '/messages/' + el.getAttribute('data-message-id'), { method: 'DELETE' }).then(function(resp) {
+				if (!resp.ok) return resp.text().then(function(text) { alert(text); });
+			}).catch(function(e) {
+				console.error('Failed to delete message:', e);
+			});
+		}
+
+		// Shows or hides the earlier versions of an edited message
+		function toggleHistory(el) {
+			const existing = el.querySelector('[data-edit-history-list]');
+			if (existing) {
+				existing.remove();
+				return;
+			}
+			fetch('/messages/' + el.getAttribute('data-message-id') + '/history').then(function(resp) {
+				if (!resp.ok) throw new Error(resp.statusText);
+				return resp.json();
+			}).then(function(revisions) {
+				const list = document.createElement('div');
+				list.className = 'text-xs mt-1 space-y-1 opacity-70';
+				list.setAttribute('data-edit-history-list', '');
+				revisions.forEach(function(rev) {
+					const p = document.createElement('p');
+					p.className = 'break-words';
+					p.textContent = rev.replaced_at + ': ' + rev.content;
+					list.appendChild(p);
+				});
+				el.querySelector('[data-message-content]').after(list);
+			}).catch(function(e) {
+				console.error('Failed to load message history:', e);
+			});
+		}
+
+		function statusLabel(status) {
+			if (status === 'read') return 'Read';
+			if (status === 'delivered') return 'Delivered';
+			if (status === 'sending') return 'Sending…';
+			return 'Sent';
+		}
+
+		// Newest message from the active user not yet reported as read
+		let unreadFromActive = 0;
+
+		function sendReceipt(type, userID, messageID) {
+			sendFrame({ type: type, payload: { user_id: userID, message_id: messageID } });
+		}
+
+		function reportRead() {
+			if (unreadFromActive === 0 || document.hidden) return;
+			if (activeGroup > 0) {
+				sendFrame({ type: 'read', payload: { conversation_id: activeGroup, message_id: unreadFromActive } });
+			} else {
+				sendReceipt('read', activeUser, unreadFromActive);
+			}
+			unreadFromActive = 0;
+		}
+
+		function handleGroupMessage(msg) {
+			if (msg.conversation_id !== activeGroup) {
+				// Reload so the sidebar shows the new message
+				if (msg.sender_id !== currentUser) {
+					setTimeout(function() { window.location.reload(); }, 250);
+				}
+				return;
+			}
+
+			const messagesContainer = document.getElementById('messages');
+			if (messagesContainer && !reconcilePending(msg) && !messageExists(msg.id)) {
+				messagesContainer.insertBefore(createMessageElement(msg), messagesContainer.firstChild);
+			}
+			if (msg.sender_id !== currentUser) {
+				unreadFromActive = msg.id;
+				reportRead();
+			}
+		}
+
+		// Messages past their conversation's retention period or disappearing timer are gone for good
+		function handleExpired(expired) {
+			expired.message_ids.forEach(function(id) {
+				const el = document.querySelector('[data-message-id="' + id + '"]');
+				if (el) el.remove();
+				removePinned(id);
+			});
+		}
+
+		// A participant changed the open conversation's disappearing-message timer
+		function handleTimer(timer) {
+			const matches = timer.conversation_id
+				? timer.conversation_id === activeGroup
+				: activeGroup === 0 && timer.user_id === activeUser;
+			const select = document.getElementById('disappear-timer');
+			if (!matches || !select) return;
+			const value = String(timer.seconds);
+			if (!select.querySelector('option[value="' + value + '"]')) {
+				const option = document.createElement('option');
+				option.value = value;
+				option.textContent = value + ' seconds';
+				select.appendChild(option);
+			}
+			select.value = value;
+		}
+
+		function handleGroup(group) {
+			// Removed from the open group on another device
+			if (group.conversation_id === activeGroup && group.member_ids.indexOf(currentUser) === -1) {
+				window.location.href = '/';
+				return;
+			}
+			setTimeout(function() { window.location.reload(); }, 250);
+		}
+
+		function handleRead(read) {
+			// Another of our devices read a conversation
+			if (read.reader_id === currentUser) {
+				const badge = document.querySelector('[data-unread-user="' + read.other_user_id + '"]');
+				if (badge) badge.classList.add('hidden');
+				return;
+			}
+			if (read.reader_id !== activeUser) return;
+
+			document.querySelectorAll('[data-message-status]').forEach(function(el) {
+				const id = parseInt(el.closest('[data-message-id]').getAttribute('data-message-id'), 10);
+				let status = 'sent';
+				if (id <= read.last_read_message_id) {
+					status = 'read';
+				} else if (id <= read.last_delivered_message_id) {
+					status = 'delivered';
+				}
+				if (status === 'sent' || el.getAttribute('data-message-status') === 'read') return;
+				el.setAttribute('data-message-status', status);
+				el.textContent = '· ' + statusLabel(status);
+			});
+		}
+
+		function requestResume() {
+			if (!isConnected()) return;
+			if (Date.now() - resumeRequestedAt < 2000) return;
+			resumeRequestedAt = Date.now();
+			sendFrame({ type: 'resume', payload: { last_seq: lastSeq } });
+		}
+
+		function scheduleAck() {
+			clearTimeout(ackTimer);
+			ackTimer = setTimeout(function() {
+				sendFrame({ type: 'ack', payload: { seq: lastSeq } });
+			}, 1000);
+		}
+
+		// Applies stored events in sequence order. Duplicates are dropped and
+		// gaps trigger a replay from the last applied event.
+		function handleEvent(data) {
+			if (data.type === 'reset') {
+				window.location.reload();
+				return;
+			}
+			if (!data.seq) {
+				handleMessage(data);
+				return;
+			}
+			if (data.seq <= lastSeq) return;
+			if (data.seq > lastSeq + 1) {
+				requestResume();
+				return;
+			}
+			lastSeq = data.seq;
+			scheduleAck();
+			handleMessage(data);
+		}
+
+		function handleAck(data) {
+			const frame = pending[data.id];
+			if (!frame) return;
+			delete pending[data.id];
+
+			if (data.type === 'error') {
+				const el = document.querySelector('[data-client-id="' + frame.payload.client_id + '"]');
+				if (el) el.remove();
+				// The form was cleared when sending; give the text back to fix and resend
+				const input = messageInput();
+				if (input && input.value === '') {
+					input.value = frame.payload.content;
+				}
+				alert(data.payload.error);
+				return;
+			}
+
+			const msg = data.payload;
+			const messagesContainer = document.getElementById('messages');
+			if (messagesContainer && !reconcilePending(msg) && !messageExists(msg.id)) {
+				messagesContainer.insertBefore(createMessageElement(msg), messagesContainer.firstChild);
+			}
+		}
+
+		function setTypingIndicator(visible) {
+			const indicator = document.getElementById('typing-indicator');
+			if (!indicator) return;
+			indicator.classList.toggle('hidden', !visible);
+			clearTimeout(typingHideTimer);
+			// Safety net in case the stop event is lost while reconnecting
+			if (visible) {
+				typingHideTimer = setTimeout(function() { setTypingIndicator(false); }, 10000);
+			}
+		}
+
+		function sendTyping(typing) {
+			if (activeUser === 0) return;
+			sendFrame({
+				type: 'typing',
+				payload: { recipient_id: activeUser, typing: typing }
+			});
+		}
+
+		function presenceLabel(status, lastSeen) {
+			if (status === 'online' || status === 'away') return status;
+			if (lastSeen) return 'last seen ' + lastSeen;
+			return 'offline';
+		}
+
+		function handlePresence(presence) {
+			const label = presenceLabel(presence.status, presence.last_seen_at);
+			document.querySelectorAll('[data-presence-user="' + presence.user_id + '"]').forEach(function(el) {
+				el.textContent = label;
+				el.classList.toggle('text-green-700', presence.status === 'online');
+				el.classList.toggle('text-muted-foreground', presence.status !== 'online');
+			});
+		}
+
+		// Tell the server whether this tab is in active use, so idle devices show as away
+		let lastActivity = Date.now();
+		function sendHeartbeat() {
+			const active = !document.hidden && (Date.now() - lastActivity) < 5 * 60 * 1000;
+			sendFrame({ type: 'heartbeat', payload: { active: active } });
+		}
+
+		function messageInput() {
+			const form = document.getElementById('message-form');
+			return form ? form.querySelector('[name="content"]') : null;
+		}
+
+		function saveDraft(beacon) {
+			clearTimeout(draftTimer);
+			draftTimer = null;
+			const input = messageInput();
+			if (!input || input.value === savedDraft) return;
+			savedDraft = input.value;
+			const body = new URLSearchParams({ content: input.value });
+			// A beacon still goes out while the page is being closed
+			if (beacon && navigator.sendBeacon(draftURL, body)) return;
+			fetch(draftURL, { method: 'POST', body: body }).catch(function() {});
+		}
+
+		function cancelDraft() {
+			clearTimeout(draftTimer);
+			draftTimer = null;
+			// The server clears the draft once the message is sent
+			savedDraft = '';
+		}
+
+		function handleDraft(draft) {
+			const matches = draft.conversation_id
+				? draft.conversation_id === activeGroup
+				: activeGroup === 0 && draft.recipient_id === activeUser;
+			const input = messageInput();
+			if (!matches || !input) return;
+			savedDraft = draft.content;
+			// Don't overwrite what is being typed on this device
+			if (document.activeElement !== input) {
+				input.value = draft.content;
+			}
+		}
+
+		function handleInput() {
+			sendClientId = null;
+			clearTimeout(draftTimer);
+			draftTimer = setTimeout(saveDraft, 1000);
+			const now = Date.now();
+			if (now - typingSentAt > 3000) {
+				typingSentAt = now;
+				sendTyping(true);
+			}
+			clearTimeout(typingStopTimer);
+			typingStopTimer = setTimeout(function() {
+				typingSentAt = 0;
+				sendTyping(false);
+			}, 3000);
+		}
+
 		function handleMessage(data) {
+			if (data.type === 'ack' || data.type === 'error') {
+				handleAck(data);
+				return;
+			}
+			if (data.type === 'presence') {
+				handlePresence(data.payload);
+				return;
+			}
+			if (data.type === 'read') {
+				handleRead(data.payload);
+				return;
+			}
+			if (data.type === 'group') {
+				handleGroup(data.payload);
+				return;
+			}
+			if (data.type === 'edited' || data.type === 'deleted') {
+				replaceMessage(data.payload);
+				return;
+			}
+			if (data.type === 'message_updated') {
+				handleMessageUpdated(data.payload);
+				return;
+			}
+			if (data.type === 'reaction') {
+				handleReaction(data.payload);
+				return;
+			}
+			if (data.type === 'pinned') {
+				handlePinned(data.payload);
+				return;
+			}
+			if (data.type === 'starred') {
+				handleStarred(data.payload);
+				return;
+			}
+			if (data.type === 'expired') {
+				handleExpired(data.payload);
+				return;
+			}
+			if (data.type === 'draft') {
+				handleDraft(data.payload);
+				return;
+			}
+			if (data.type === 'timer') {
+				handleTimer(data.payload);
+				return;
+			}
+			if (data.type === 'typing') {
+				if (data.payload.user_id === activeUser) {
+					setTypingIndicator(data.payload.typing);
+				}
+				return;
+			}
 			if (data.type !== 'message') return;
 
 			const msg = data.payload;
+			if (msg.conversation_id) {
+				handleGroupMessage(msg);
+				return;
+			}
 
 			// Skip if message already exists in DOM
 			if (messageExists(msg.id)) {
@@ -598,6 +2469,16 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 
 			const messagesContainer = document.getElementById('messages');
 
+			if (msg.sender_id === activeUser) {
+				setTypingIndicator(false);
+				unreadFromActive = msg.id;
+				if (document.hidden) {
+					sendReceipt('delivered', activeUser, msg.id);
+				} else {
+					reportRead();
+				}
+			}
+
 			// Determine if this message belongs to the active conversation
 			const isFromActiveConversation = (
 				(msg.sender_id === activeUser) ||
@@ -606,7 +2487,7 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 
 			// Append message if in the correct conversation
 			if (messagesContainer && isFromActiveConversation) {
-				if (msg.sender_id === activeUser || msg.is_sent) {
+				if (msg.sender_id === activeUser || (msg.is_sent && !reconcilePending(msg))) {
 					const element = createMessageElement(msg);
 					messagesContainer.insertBefore(element, messagesContainer.firstChild);
 				}
@@ -614,7 +2495,8 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 
 			// Reload for new messages from other conversations
 			if (!isFromActiveConversation && msg.sender_id !== currentUser) {
-				window.location.reload();
+				sendReceipt('delivered', msg.sender_id, msg.id);
+				setTimeout(function() { window.location.reload(); }, 250);
 			}
 		}
 
@@ -624,20 +2506,39 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 
 			ws.onopen = function() {
 				console.log('WebSocket connected');
+				wsOpened = true;
 				reconnectAttempts = 0;
+				resumeRequestedAt = 0;
+				requestResume();
+				resendPending();
 			};
 
 			ws.onmessage = function(event) {
 				try {
 					const data = JSON.parse(event.data);
-					handleMessage(data);
+					handleEvent(data);
 				} catch (e) {
 					console.error('Failed to parse WebSocket message:', e);
 				}
 			};
 
-			ws.onclose = function() {
+			ws.onclose = function(event) {
 				console.log('WebSocket disconnected');
+				if (!wsOpened) {
+					// The upgrade never succeeded, e.g. a proxy strips it
+					ws = null;
+					connectEvents();
+					return;
+				}
+				// Server restarting: come back when it says, without using up attempts
+				const restart = event.code === 1012 && /retry in (\d+) s/.exec(event.reason);
+				if (restart) {
+					reconnectAttempts = 0;
+					const delay = parseInt(restart[1], 10) * 1000 + Math.random() * 1000;
+					console.log('Server restarting, reconnecting in', delay, 'ms...');
+					setTimeout(connect, delay);
+					return;
+				}
 				if (reconnectAttempts < maxReconnectAttempts) {
 					reconnectAttempts++;
 					const delay = Math.min(1000 * Math.pow(2, reconnectAttempts), 30000);
@@ -651,11 +2552,177 @@ func chatScript(currentUserID, activeUserID int64) templ.ComponentScript {
 			};
 		}
 
+		function connectEvents() {
+			console.log('WebSocket unavailable, falling back to Server-Sent Events');
+			events = new EventSource('/events');
+
+			events.onmessage = function(event) {
+				try {
+					const data = JSON.parse(event.data);
+					if (data.type === 'stream') {
+						// A new stream, including after EventSource reconnects on its own
+						streamId = data.payload.stream_id;
+						resumeRequestedAt = 0;
+						requestResume();
+						resendPending();
+						return;
+					}
+					handleEvent(data);
+				} catch (e) {
+					console.error('Failed to parse event:', e);
+				}
+			};
+
+			events.onerror = function() {
+				console.log('Event stream disconnected');
+				streamId = null;
+			};
+		}
+
+		// Retried sends reuse the message's ID so the server doesn't store it twice
+		document.addEventListener('htmx:configRequest', function(event) {
+			if (!event.detail.elt || event.detail.elt.id !== 'message-form') return;
+			if (!sendClientId) sendClientId = newClientId();
+			event.detail.parameters.client_id = sendClientId;
+		});
+
+		// Send over the realtime connection when available; otherwise let HTMX POST the form
+		document.addEventListener('htmx:beforeRequest', function(event) {
+			const form = event.detail.elt;
+			if (!form || form.id !== 'message-form') return;
+			cancelDraft();
+			if (!isConnected()) return;
+			// Files are only uploaded over HTTP
+			if (form.querySelector('[name="file"]').files.length > 0) return;
+
+			event.preventDefault();
+			clearTimeout(typingStopTimer);
+			typingSentAt = 0;
+			const content = form.querySelector('[name="content"]').value;
+			const id = String(++nextFrameId);
+			const target = activeGroup > 0 ? { conversation_id: activeGroup } : { recipient_id: activeUser };
+			target.content = content;
+			target.client_id = sendClientId;
+			sendClientId = null;
+			const replyTo = parseInt(form.querySelector('[name="reply_to_id"]').value, 10);
+			if (replyTo > 0) target.reply_to_id = replyTo;
+			const frame = {
+				type: 'send',
+				id: id,
+				payload: target
+			};
+			// Kept until acknowledged so it can be resent after reconnecting
+			pending[id] = frame;
+			showPending(target);
+			sendFrame(frame);
+			form.reset();
+			clearReply();
+		});
+
+		// Sent without the realtime connection, or with a file
+		document.addEventListener('htmx:afterRequest', function(event) {
+			if (!event.detail.elt || event.detail.elt.id !== 'message-form') return;
+			if (event.detail.failed && event.detail.xhr.status >= 400 && event.detail.xhr.status < 500) {
+				alert(event.detail.xhr.responseText);
+			}
+			if (!event.detail.successful) return;
+			sendClientId = null;
+			// A retry returns the original message, which may already be shown
+			const sent = document.querySelector('#messages > [data-message-id]');
+			if (sent && document.querySelectorAll('[data-message-id="' + sent.getAttribute('data-message-id') + '"]').length > 1) {
+				sent.remove();
+			}
+			clearReply();
+		});
+
+		const replyCancel = document.getElementById('reply-cancel');
+		if (replyCancel) {
+			replyCancel.addEventListener('click', clearReply);
+		}
+
+		const messagesList = document.getElementById('messages');
+		if (messagesList) {
+			messagesList.addEventListener('click', function(event) {
+				const message = event.target.closest('[data-message-id]');
+				if (!message) return;
+				const react = event.target.closest('[data-react]');
+				if (react) {
+					toggleReaction(message, react.getAttribute('data-react'));
+					const picker = message.querySelector('[data-reaction-picker]');
+					if (picker) picker.remove();
+				} else if (event.target.closest('[data-react-picker]')) {
+					toggleReactionPicker(message);
+				} else if (event.target.closest('[data-reply-message]')) {
+					startReply(message);
+				} else if (event.target.closest('[data-edit-message]')) {
+					editMessage(message);
+				} else if (event.target.closest('[data-pin-message]')) {
+					togglePin(message);
+				} else if (event.target.closest('[data-star-message]')) {
+					toggleStar(message);
+				} else if (event.target.closest('[data-delete-message]')) {
+					deleteMessage(message);
+				} else if (event.target.closest('[data-edit-history]')) {
+					toggleHistory(message);
+				}
+			});
+		}
+
+		// Pinned messages already loaded are scrolled to; others open the page at them
+		const pinnedBar = document.getElementById('pinned-bar');
+		if (pinnedBar) {
+			pinnedBar.addEventListener('click', function(event) {
+				const link = event.target.closest('[data-pinned-id]');
+				if (!link) return;
+				const target = document.querySelector('[data-message-id="' + link.getAttribute('data-pinned-id') + '"] > div');
+				if (!target) return;
+				event.preventDefault();
+				target.scrollIntoView({ block: 'center' });
+			});
+		}
+
+		const messageForm = document.getElementById('message-form');
+		if (messageForm) {
+			const contentInput = messageForm.querySelector('[name="content"]');
+			// Keep a saved draft out of the value the form resets to after sending
+			savedDraft = contentInput.value;
+			contentInput.defaultValue = '';
+			contentInput.value = savedDraft;
+			contentInput.addEventListener('input', handleInput);
+			messageForm.querySelector('[name="file"]').addEventListener('change', updateAttachment);
+			// Runs before the form's fields are cleared
+			messageForm.addEventListener('reset', function() { setTimeout(updateAttachment, 0); });
+		}
+
+		['keydown', 'pointerdown', 'scroll'].forEach(function(name) {
+			document.addEventListener(name, function() { lastActivity = Date.now(); }, { passive: true });
+		});
+		document.addEventListener('visibilitychange', function() {
+			if (!document.hidden) lastActivity = Date.now();
+			if (document.hidden && draftTimer) saveDraft(true);
+			sendHeartbeat();
+			reportRead();
+		});
+		setInterval(sendHeartbeat, 30000);
+		window.addEventListener('pagehide', function() {
+			if (draftTimer) saveDraft(true);
+		});
+
+		// Scroll to the message linked from a search result and briefly outline it
+		if (focusMessageID > 0) {
+			const focused = document.querySelector('[data-message-id="' + focusMessageID + '"] > div');
+			if (focused) {
+				focused.scrollIntoView({ block: 'center' });
+				focused.style.outline = '2px solid currentColor';
+				setTimeout(function() { focused.style.outline = ''; }, 3000);
+			}
+		}
+
 		connect();
 	})();
 }`,
-		Call:       templ.SafeScript(`__templ_chatScript_e116`, currentUserID, activeUserID),
-		CallInline: templ.SafeScriptInline(`__templ_chatScript_e116`, currentUserID, activeUserID),
+		Call:       templ.SafeScript(`__templ_chatScript_2c97`, currentUserID, activeUserID, activeGroupID, lastEventSeq, editingEnabled, focusMessageID),
+		CallInline: templ.SafeScriptInline(`__templ_chatScript_2c97`, currentUserID, activeUserID, activeGroupID, lastEventSeq, editingEnabled, focusMessageID),
 	}
 }
 